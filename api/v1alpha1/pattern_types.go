@@ -25,6 +25,32 @@ const (
 	// NodeMaintenanceFinalizer is a finalizer for a NodeMaintenance CR deletion
 	PatternFinalizer string = "foregroundDeletePattern"
 	PruneAnnotation  string = "patterns.gitops.hybrid-cloud-patterns.io/prune"
+	// ResyncMirrorAnnotation requests a sync of the in-cluster git repository from OriginRepo.
+	// Any new value (e.g. a timestamp) triggers a new sync
+	ResyncMirrorAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/resync-mirror"
+	// RotateGitServerPasswordAnnotation requests a new admin password for the in-cluster git server.
+	// Any new value (e.g. a timestamp) triggers a new rotation
+	RotateGitServerPasswordAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/rotate-git-server-password"
+	// ForceDeletionAnnotation requests the current deletion phase to be forced instead of waiting for it
+	// to complete or time out. Any new value (e.g. a timestamp) forces the phase the pattern is in.
+	// Forcing the hub child applications phase also removes the finalizers of the applications left
+	ForceDeletionAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/force-deletion"
+	// DeletionPreviewAnnotation set to "true" publishes in status.deletionPreview what deleting the pattern removes
+	DeletionPreviewAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/deletion-preview"
+	// PausedAnnotation set to "true" stops the operator from changing anything but the status of the pattern,
+	// so that hand edits to what it manages are left alone. Removing it resumes the reconciliation. A paused
+	// pattern that is deleted is still finalized
+	PausedAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/paused"
+	// SyncRequestedAnnotation requests a sync of the app of apps, as the manual sync policy never syncs
+	// on its own. Any new value (e.g. a timestamp) triggers a new sync, whose outcome is in status.sync
+	SyncRequestedAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/sync-requested"
+	// SyncOptionsAnnotation holds the comma separated options of the requested syncs: "prune" deletes the
+	// resources that are no longer in git, "dry-run" only reports what would change and "children" syncs the
+	// child applications of the app of apps too
+	SyncOptionsAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/sync-options"
+	// RefreshAnnotation set to "normal" or "hard" refreshes the app of apps, and its children with the
+	// "children" sync option, once. It is removed when the refresh has been requested, like its argo counterpart
+	RefreshAnnotation string = "patterns.gitops.hybrid-cloud-patterns.io/refresh"
 )
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
@@ -85,10 +111,61 @@ type PatternSpec struct {
 	// Comma separated capabilities to enable certain experimental features
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=10,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	ExperimentalCapabilities string `json:"experimentalCapabilities,omitempty"`
+
+	// How the pattern is removed when it is deleted with the prune annotation
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Deletion *DeletionConfig `json:"deletion,omitempty"`
+}
+
+// ManagedClusterPolicy selects the ManagedClusters detached from the hub when the pattern is deleted
+type ManagedClusterPolicy string
+
+const (
+	// Detach the clusters the pattern created or placed in one of its managedClusterGroups
+	DetachOwnedManagedClusters ManagedClusterPolicy = "DetachOwned"
+	// Detach every cluster except the hub
+	DetachAllManagedClusters ManagedClusterPolicy = "DetachAll"
+	// Leave all the clusters attached
+	KeepAllManagedClusters ManagedClusterPolicy = "KeepAll"
+	// Detach the clusters matching ManagedClusterSelector
+	SelectedManagedClusters ManagedClusterPolicy = "Selector"
+)
+
+// +kubebuilder:validation:XValidation:rule="self.managedClusterPolicy != 'Selector' || has(self.managedClusterSelector)",message="managedClusterSelector is required by the Selector policy"
+type DeletionConfig struct {
+	// Which ManagedClusters are deleted, and thus detached from the hub, before the hub applications are removed.
+	// DetachOwned only detaches the clusters labeled as owned by the pattern, DetachAll every cluster but the hub,
+	// KeepAll none of them and Selector the ones matching ManagedClusterSelector. Default: DetachOwned
+	// +kubebuilder:validation:Enum=DetachOwned;DetachAll;KeepAll;Selector
+	// +kubebuilder:default:=DetachOwned
+	ManagedClusterPolicy ManagedClusterPolicy `json:"managedClusterPolicy,omitempty"`
+
+	// Label selector of the ManagedClusters detached with the Selector policy
+	ManagedClusterSelector *metav1.LabelSelector `json:"managedClusterSelector,omitempty"`
+
+	// Resources of the hub applications that are left in place, typically because they hold data
+	Retain *RetainConfig `json:"retain,omitempty"`
 }
 
+// RetainConfig selects the resources argo orphans when the pattern is deleted. They are annotated with
+// the Prune=false and Delete=false sync options before the applications are removed. Only ConfigMaps,
+// Namespaces, PersistentVolumeClaims, Secrets and StatefulSets can be retained
+type RetainConfig struct {
+	// Namespaces kept along with the resources of a retainable kind the applications manage in them
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Kinds of resources kept in every namespace
+	Kinds []RetainKind `json:"kinds,omitempty"`
+}
+
+// RetainKind is the kind of a resource the retain policy can leave in place
+// +kubebuilder:validation:Enum=ConfigMap;Namespace;PersistentVolumeClaim;Secret;StatefulSet
+type RetainKind string
+
 type GitConfig struct {
-	// (EXPERIMENTAL) Enable in-cluster git server (avoids the need of forking the upstream repository)
+	// (EXPERIMENTAL) Enable in-cluster git server (avoids the need of forking the upstream repository).
+	// OriginRepo, or TargetRepo without it, is imported into the in-cluster git server and deployed from there,
+	// see status.effectiveTargetRepo. Always implied when OriginRepo is set
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=11,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
 	// +kubebuilder:default:=false
 	InClusterGitServer *bool `json:"inClusterGitServer,omitempty"`
@@ -116,13 +193,38 @@ type GitConfig struct {
 
 	// Optional. K8s secret name where the info for connecting to git can be found. The supported secrets are modeled after the
 	// private repositories in argo (https://argo-cd.readthedocs.io/en/stable/operator-manual/declarative-setup/#repositories)
-	// currently ssh and username+password are supported
+	// currently ssh, username+password, GitHub App, bearerToken, tlsClientCertData+tlsClientCertKey, gcpServiceAccountKey
+	// and useAzureWorkloadIdentity are supported
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=18,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	TokenSecret string `json:"tokenSecret,omitempty"`
 
 	// Optional. K8s secret namespace where the token for connecting to git can be found
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=19,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	TokenSecretNamespace string `json:"tokenSecretNamespace,omitempty"`
+
+	// Optional. How the in-cluster git server keeps its copy of OriginRepo up to date
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:gitSpec.inClusterGitServer:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InClusterGitMirror InClusterGitMirror `json:"inClusterGitMirror,omitempty"`
+}
+
+type InClusterGitMirror struct {
+	// Optional. Interval at which the in-cluster repository pulls OriginRepo (e.g. "8h0m0s"). When set the
+	// in-cluster repository is a read-only pull mirror. When empty OriginRepo is imported once and can be
+	// edited in the in-cluster git server
+	PullInterval string `json:"pullInterval,omitempty"`
+
+	// Optional. Git repo (typically a fork of OriginRepo) the in-cluster repository is pushed to, so that
+	// local edits are kept outside of the cluster
+	PushRepo string `json:"pushRepo,omitempty"`
+
+	// Optional. Interval at which the in-cluster repository is pushed to PushRepo. Default: 8h0m0s
+	PushInterval string `json:"pushInterval,omitempty"`
+
+	// Optional. K8s secret with the username and password used to push to PushRepo
+	PushSecret string `json:"pushSecret,omitempty"`
+
+	// Optional. K8s secret namespace of PushSecret. Defaults to the namespace of the pattern
+	PushSecretNamespace string `json:"pushSecretNamespace,omitempty"`
 }
 
 type MultiSourceConfig struct {
@@ -132,6 +234,7 @@ type MultiSourceConfig struct {
 	Enabled *bool `json:"enabled,omitempty"`
 
 	// The helm chart url to fetch the helm charts from in order to deploy the pattern. Defaults to https://charts.validatedpatterns.io/
+	// Use an oci:// url for charts stored in an OCI registry
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=21,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	HelmRepoUrl string `json:"helmRepoUrl,omitempty"`
 
@@ -148,12 +251,159 @@ type MultiSourceConfig struct {
 	// Defaults to 'main'. (Only used when developing the clustergroup helm chart)
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=24,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	ClusterGroupChartGitRevision string `json:"clusterGroupChartGitRevision,omitempty"`
+
+	// Optional. K8s secret name with the credentials for HelmRepoUrl. It is copied to the argo namespaces as a helm
+	// repository secret, so it can contain the same fields as the argo helm repositories (username+password,
+	// tlsClientCertData+tlsClientCertKey and enableOCI for OCI registries)
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=25,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HelmRepoCredentialsSecret string `json:"helmRepoCredentialsSecret,omitempty"`
+
+	// Optional. K8s secret namespace where the helm repository credentials can be found. Defaults to the namespace of the Pattern
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=26,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HelmRepoCredentialsSecretNamespace string `json:"helmRepoCredentialsSecretNamespace,omitempty"`
+
+	// Optional. oci:// url of an OCI artifact containing the pattern. When set the pattern values are sourced from this
+	// artifact instead of the git TargetRepo. Authenticates with HelmRepoCredentialsSecret
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=27,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PatternOCIUrl string `json:"patternOCIUrl,omitempty"`
+
+	// Tag or semver range of the pattern OCI artifact. Defaults to "latest"
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,order=28,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:multiSourceConfig.enabled:true","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PatternOCIVersion string `json:"patternOCIVersion,omitempty"`
 }
 
 type GitOpsConfig struct {
 	// Require manual intervention before Argo will sync new content. Default: False
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	ManualSync bool `json:"manualSync,omitempty"`
+
+	// Delete the resources that are no longer in git during the automated syncs. Default: False
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Prune bool `json:"prune,omitempty"`
+
+	// Revert the changes made outside of git during the automated syncs. Default: True
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SelfHeal *bool `json:"selfHeal,omitempty"`
+
+	// Let the automated syncs prune every resource of an application. Default: False
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	AllowEmpty bool `json:"allowEmpty,omitempty"`
+
+	// Options of the automated and manual syncs
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SyncOptions GitOpsSyncOptions `json:"syncOptions,omitempty"`
+
+	// How failed syncs are retried. Default: 20 retries without backoff settings
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Retry *GitOpsRetry `json:"retry,omitempty"`
+
+	// Differences between git and the cluster that argo ignores when comparing the pattern applications
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	IgnoreDifferences []GitOpsIgnoreDifference `json:"ignoreDifferences,omitempty"`
+
+	// Windows during which argo may, or may not, sync the app of apps and its child applications. They become
+	// the argo sync windows of an AppProject dedicated to the pattern
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// Optional. Pins the applications to the last git revision they were healthy at when a new revision of
+	// TargetRevision leaves them degraded, until TargetRevision moves again
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Rollback *RollbackPolicy `json:"rollback,omitempty"`
+}
+
+type RollbackPolicy struct {
+	// Roll back automatically. Default: False
+	Enabled bool `json:"enabled,omitempty"`
+
+	// How long the applications stay degraded on a new revision before it is rolled back. Default: 10m
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	DegradedPeriod string `json:"degradedPeriod,omitempty"`
+}
+
+type MaintenanceWindow struct {
+	// Whether the window allows or denies the syncs. Outside of the allow windows no automated sync happens
+	// +kubebuilder:validation:Enum=allow;deny
+	// +kubebuilder:default:=allow
+	Kind string `json:"kind,omitempty"`
+
+	// Start of the window, in cron format (e.g. "0 22 * * 6")
+	Schedule string `json:"schedule"`
+
+	// How long the window stays open (e.g. 2h, 90m)
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	Duration string `json:"duration"`
+
+	// Time zone of the schedule (e.g. Europe/Rome). Default: UTC
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Let the manual syncs, like the ones of the sync-requested annotation, through a deny window
+	ManualSync bool `json:"manualSync,omitempty"`
+
+	// Optional. Shown in argo next to the window, a change ticket for instance
+	// +kubebuilder:validation:MaxLength=255
+	Description string `json:"description,omitempty"`
+}
+
+// GitOpsSyncOptions are the argo sync options set on the pattern applications
+type GitOpsSyncOptions struct {
+	// Apply the resources with server-side apply (ServerSideApply=true)
+	ServerSideApply bool `json:"serverSideApply,omitempty"`
+
+	// Create the destination namespace of the applications when missing (CreateNamespace=true)
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// Leave the ignored differences alone when syncing too, not only when comparing (RespectIgnoreDifferences=true)
+	RespectIgnoreDifferences bool `json:"respectIgnoreDifferences,omitempty"`
+}
+
+// GitOpsRetry is how argo retries the failed syncs of the pattern applications, it becomes
+// spec.syncPolicy.retry of the applications
+type GitOpsRetry struct {
+	// Number of retries of a failed sync, negative values retry forever. Default: 20
+	Limit *int64 `json:"limit,omitempty"`
+
+	// Retry with the latest revision instead of the one that failed
+	Refresh bool `json:"refresh,omitempty"`
+
+	// Optional. Backoff between the retries, argo defaults apply when unset
+	Backoff *GitOpsBackoff `json:"backoff,omitempty"`
+}
+
+// GitOpsBackoff is the growing wait between the retries of a failed sync
+type GitOpsBackoff struct {
+	// Wait before the first retry, in seconds or as a duration (e.g. 5s, 2m)
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+	Duration string `json:"duration,omitempty"`
+
+	// Factor the wait is multiplied by after each retry
+	// +kubebuilder:validation:Minimum=1
+	Factor *int64 `json:"factor,omitempty"`
+
+	// Longest wait between two retries, in seconds or as a duration (e.g. 3m)
+	// +kubebuilder:validation:Pattern=`^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+// GitOpsIgnoreDifference selects resources and the fields of them that argo ignores, see
+// https://argo-cd.readthedocs.io/en/stable/user-guide/diffing/
+type GitOpsIgnoreDifference struct {
+	Group string `json:"group,omitempty"`
+
+	Kind string `json:"kind"`
+
+	Name string `json:"name,omitempty"`
+
+	Namespace string `json:"namespace,omitempty"`
+
+	// JSON pointers to the ignored fields (e.g. /spec/replicas)
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+
+	// jq path expressions matching the ignored fields
+	JQPathExpressions []string `json:"jqPathExpressions,omitempty"`
+
+	// Managers whose changes to the fields take precedence over git
+	ManagedFieldsManagers []string `json:"managedFieldsManagers,omitempty"`
 }
 
 // PatternApplicationInfo defines the Applications
@@ -165,6 +415,66 @@ type PatternApplicationInfo struct {
 	Namespace       string `json:"namespace,omitempty"`
 	AppSyncStatus   string `json:"syncStatus,omitempty"`
 	AppHealthStatus string `json:"healthStatus,omitempty"`
+	// Managed cluster the application deploys to, empty when it deploys to the local cluster
+	Cluster string `json:"cluster,omitempty"`
+	// Git revision (or chart version) the application is synced to
+	Revision string `json:"revision,omitempty"`
+	// Start and completion time of the last sync operation
+	LastSyncStartedAt  *metav1.Time `json:"lastSyncStartedAt,omitempty"`
+	LastSyncFinishedAt *metav1.Time `json:"lastSyncFinishedAt,omitempty"`
+	// Phase of the last sync operation: Running, Terminating, Failed, Error or Succeeded
+	OperationPhase string `json:"operationPhase,omitempty"`
+	// Message of the last sync operation, typically the error that made it fail
+	OperationMessage string `json:"operationMessage,omitempty"`
+	// Number of times the last sync operation was retried
+	RetryCount int64 `json:"retryCount,omitempty"`
+	// Resources of the application that are not healthy
+	UnhealthyResources []PatternApplicationResource `json:"unhealthyResources,omitempty"`
+}
+
+// PatternApplicationResource is a resource of an application that is not healthy
+type PatternApplicationResource struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Health    string `json:"health,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// PatternClusterStatus summarizes the argo applications running on a managed cluster
+type PatternClusterStatus struct {
+	// Name of the managed cluster
+	Name string `json:"name"`
+	// Sync and health of the clusterGroup application of the cluster
+	ClusterGroupSyncStatus   string `json:"clusterGroupSyncStatus,omitempty"`
+	ClusterGroupHealthStatus string `json:"clusterGroupHealthStatus,omitempty"`
+	// Overall sync and worst health of all the applications of the cluster
+	SyncStatus   string `json:"syncStatus,omitempty"`
+	HealthStatus string `json:"healthStatus,omitempty"`
+	// Number of applications on the cluster
+	Applications int `json:"applications,omitempty"`
+	// Number of applications that are not synced
+	OutOfSync int `json:"outOfSync,omitempty"`
+	// Number of applications that are not healthy
+	Unhealthy int `json:"unhealthy,omitempty"`
+}
+
+// PatternManagedCluster describes a cluster managed by the ACM hub and where the pattern places it
+type PatternManagedCluster struct {
+	// Name of the managed cluster
+	Name string `json:"name"`
+	// managedClusterGroup of the pattern whose clusterSelector matches the cluster, empty when none does
+	ClusterGroup string `json:"clusterGroup,omitempty"`
+	// Labels of the cluster used by the clusterSelectors of the managedClusterGroups
+	Labels map[string]string `json:"labels,omitempty"`
+	// Status of the ManagedClusterConditionAvailable condition: True, False or Unknown
+	Available string `json:"available,omitempty"`
+	// OpenShift version of the cluster
+	OpenShiftVersion string `json:"openshiftVersion,omitempty"`
+	// True when one of the applications of the pattern created or imported the cluster. Owned clusters
+	// are labeled with validatedpatterns.io/pattern and detached by the DetachOwned policy
+	Owned bool `json:"owned,omitempty"`
 }
 
 // PatternStatus defines the observed state of Pattern
@@ -199,6 +509,25 @@ type PatternStatus struct {
 	Conditions []PatternCondition `json:"conditions,omitempty"`
 	//+operator-sdk:csv:customerresourcedefinitions:type=status
 	Applications []PatternApplicationInfo `json:"applications,omitempty"`
+	// Worst health reported by the applications of the pattern
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ApplicationsHealth string `json:"applicationsHealth,omitempty"`
+	// Synced when all the applications of the pattern are synced, OutOfSync when any of them is not
+	// and Unknown otherwise
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ApplicationsSync string `json:"applicationsSync,omitempty"`
+	// Summary of the applications the pattern deploys on each managed cluster, as reported by ACM search
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Clusters []PatternClusterStatus `json:"clusters,omitempty"`
+	// Last time the managed clusters were queried for their applications
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ClustersLastUpdated *metav1.Time `json:"clustersLastUpdated,omitempty"`
+	// Clusters imported in the ACM hub and the managedClusterGroup they are bound to
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ManagedClusters []PatternManagedCluster `json:"managedClusters,omitempty"`
+	// Version of ACM running on the hub, the managed clusters run the agents of the same version
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ACMVersion string `json:"acmVersion,omitempty"`
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +kubebuilder:default:=0
 	AnalyticsSent int `json:"analyticsSent,omitempty"`
@@ -211,6 +540,178 @@ type PatternStatus struct {
 	// Values: "" (not deleting), "DeleteSpokeChildApps" (Phase 1: Delete child applications from spoke clusters), "DeleteSpoke" (Phase 2: Delete app of apps from spoke),
 	// 				 "DeleteHubChildApps" (Phase 3: Delete applications from hub), "DeleteHub" (Phase 4: Delete app of apps from hub)
 	DeletionPhase PatternDeletionPhase `json:"deletionPhase,omitempty"`
+	// Progress of the current deletion phase
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Deletion *PatternDeletionStatus `json:"deletion,omitempty"`
+	// What deleting the pattern removes, published when the deletion preview annotation is set
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	DeletionPreview *PatternDeletionPreview `json:"deletionPreview,omitempty"`
+	// Outcome of the last sync requested with the sync-requested annotation
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Sync *PatternSyncStatus `json:"sync,omitempty"`
+	// Whether the maintenance windows let argo sync now and when that changes
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	MaintenanceWindow *PatternMaintenanceWindowStatus `json:"maintenanceWindow,omitempty"`
+	// Revisions tracked by the rollback policy and the revision the applications are rolled back to
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Rollback *PatternRollbackStatus `json:"rollback,omitempty"`
+	// The clustergroup chart version that HelmRepoUrl currently resolves to
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ClusterGroupChartVersion string `json:"clusterGroupChartVersion,omitempty"`
+	// The tag of the pattern OCI artifact that PatternOCIVersion currently resolves to
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PatternOCIVersion string `json:"patternOCIVersion,omitempty"`
+	// State of the repository in the in-cluster git server
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GitServer *GitServerStatus `json:"gitServer,omitempty"`
+	// Git repository the pattern is deployed from. This is the in-cluster copy of OriginRepo
+	// when the in-cluster git server is used and empty otherwise, in which case TargetRepo is used
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	EffectiveTargetRepo string `json:"effectiveTargetRepo,omitempty"`
+}
+
+// PatternDeletionStatus reports what the current deletion phase is waiting on
+type PatternDeletionStatus struct {
+	// Time the current deletion phase started
+	PhaseStartTime *metav1.Time `json:"phaseStartTime,omitempty"`
+	// Time after which the current deletion phase moves on, unset when it never times out
+	PhaseDeadline *metav1.Time `json:"phaseDeadline,omitempty"`
+	// Applications (namespace/name in cluster) or ManagedClusters the current phase is waiting to be removed
+	WaitingOn []string `json:"waitingOn,omitempty"`
+	// Human readable details about the current phase
+	Message string `json:"message,omitempty"`
+	// Last value of the force deletion annotation that was handled
+	LastForceRequest string `json:"lastForceRequest,omitempty"`
+	// Resources left in place by the retain policy, as Kind namespace/name
+	Retained []string `json:"retained,omitempty"`
+	// Operator-wide resources, as Kind namespace/name, that the removal of the last pattern left in place
+	// or is still tearing down when deletion.operatorTeardown is set in the operator config
+	SharedResources []string `json:"sharedResources,omitempty"`
+}
+
+// PatternSyncStatus reports on a sync requested with the sync-requested annotation
+type PatternSyncStatus struct {
+	// Value of the sync-requested annotation that was handled
+	Request string `json:"request"`
+	// Sync options the sync was requested with
+	Prune    bool `json:"prune,omitempty"`
+	DryRun   bool `json:"dryRun,omitempty"`
+	Children bool `json:"children,omitempty"`
+	// Pending until argo starts the sync, then Running, Succeeded, Failed or Error as reported by argo.
+	// The worst phase of the applications when the children are synced too
+	Phase string `json:"phase,omitempty"`
+	// Human readable details about the sync
+	Message string `json:"message,omitempty"`
+	// Time the sync was requested
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// Time the sync of the last application finished
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// Revision the app of apps was synced to
+	Revision string `json:"revision,omitempty"`
+	// Outcome for each application that was synced
+	Applications []PatternApplicationSync `json:"applications,omitempty"`
+}
+
+// PatternApplicationSync is the outcome of the sync of one application
+type PatternApplicationSync struct {
+	// Name of the application, as namespace/name
+	Name string `json:"name"`
+	// Phase of the sync of the application
+	Phase string `json:"phase,omitempty"`
+	// Message argo reported for the sync of the application
+	Message string `json:"message,omitempty"`
+}
+
+// PatternMaintenanceWindowStatus reports on the maintenance windows of the pattern
+type PatternMaintenanceWindowStatus struct {
+	// True while the windows let argo sync the app of apps automatically
+	Open bool `json:"open"`
+	// AppProject holding the argo sync windows, as namespace/name
+	Project string `json:"project,omitempty"`
+	// Start of the next window the syncs are allowed in, unset while open
+	NextWindowStart *metav1.Time `json:"nextWindowStart,omitempty"`
+	// End of the current, or next, window the syncs are allowed in
+	NextWindowEnd *metav1.Time `json:"nextWindowEnd,omitempty"`
+	// Human readable details, such as why the windows could not be evaluated
+	Message string `json:"message,omitempty"`
+}
+
+// PatternRollbackStatus reports on the rollback policy of the pattern
+type PatternRollbackStatus struct {
+	// Last revision of TargetRevision all the applications were Synced and Healthy at
+	LastHealthyRevision string `json:"lastHealthyRevision,omitempty"`
+	// Time the applications were first seen healthy at LastHealthyRevision
+	LastHealthyTime *metav1.Time `json:"lastHealthyTime,omitempty"`
+	// Revision of TargetRevision the applications are degraded at
+	DegradedRevision string `json:"degradedRevision,omitempty"`
+	// Time the applications were first seen degraded at DegradedRevision
+	DegradedSince *metav1.Time `json:"degradedSince,omitempty"`
+	// Revision the applications are pinned to while rolled back, empty when they follow TargetRevision
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+	// Time of the rollback
+	PinnedAt *metav1.Time `json:"pinnedAt,omitempty"`
+}
+
+// PatternDeletionPreview lists what deleting the pattern removes
+type PatternDeletionPreview struct {
+	// True when the prune annotation is set. Otherwise deleting the pattern leaves everything in place
+	Prune bool `json:"prune"`
+	// Last time the preview changed
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+	// Child applications of the pattern on the hub, as namespace/name
+	Applications []string `json:"applications,omitempty"`
+	// Applications of the pattern on the managed clusters, as namespace/name in cluster
+	SpokeApplications []string `json:"spokeApplications,omitempty"`
+	// ManagedClusters that are deleted, which detaches them from the hub
+	ManagedClusters []string `json:"managedClusters,omitempty"`
+	// Namespaces managed by the applications of the hub
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Resources left in place by the retain policy, as Kind namespace/name
+	Retained []string `json:"retained,omitempty"`
+	// Errors hit while computing the preview, in which case it may be incomplete
+	Message string `json:"message,omitempty"`
+}
+
+// Phases of the import of OriginRepo into the in-cluster git server
+const (
+	GitServerMigrationRunning   string = "Running"
+	GitServerMigrationSucceeded string = "Succeeded"
+	GitServerMigrationFailed    string = "Failed"
+)
+
+type GitServerStatus struct {
+	// True once the argo application deploying the git server is synced
+	ApplicationSynced bool `json:"applicationSynced,omitempty"`
+	// True once the route of the git server exists
+	RouteAvailable bool `json:"routeAvailable,omitempty"`
+	// True once the API of the git server answers
+	APIReachable bool `json:"apiReachable,omitempty"`
+	// True once OriginRepo has been imported into the git server
+	RepositoryMigrated bool `json:"repositoryMigrated,omitempty"`
+	// URL of the imported repository
+	RepositoryURL string `json:"repositoryURL,omitempty"`
+	// Phase of the import of OriginRepo: Running, Succeeded or Failed
+	MigrationPhase string `json:"migrationPhase,omitempty"`
+	// Time the last import of OriginRepo started
+	MigrationStartTime *metav1.Time `json:"migrationStartTime,omitempty"`
+	// True when the in-cluster repository is a pull mirror of OriginRepo
+	Mirror bool `json:"mirror,omitempty"`
+	// Last time the in-cluster repository was synced from OriginRepo
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Commit of the in-cluster repository after the last sync from OriginRepo
+	LastSyncCommit string `json:"lastSyncCommit,omitempty"`
+	// Current commit of OriginRepo
+	OriginCommit string `json:"originCommit,omitempty"`
+	// Current commit of the in-cluster repository
+	Commit string `json:"commit,omitempty"`
+	// True when both the in-cluster repository and OriginRepo have changes the other one does not have
+	Diverged bool `json:"diverged,omitempty"`
+	// Last value of the resync annotation that was handled
+	LastResyncRequest string `json:"lastResyncRequest,omitempty"`
+	// Last value of the password rotation annotation that was handled
+	LastPasswordRotation string `json:"lastPasswordRotation,omitempty"`
+	// Human readable details about the state of the in-cluster repository
+	Message string `json:"message,omitempty"`
 }
 
 // See: https://book.kubebuilder.io/reference/markers/crd.html
@@ -218,6 +719,8 @@ type PatternStatus struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=patt
+// +kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.applicationsHealth`
+// +kubebuilder:printcolumn:name="Sync",type=string,JSONPath=`.status.applicationsSync`
 // +kubebuilder:printcolumn:name="Step",type=string,JSONPath=`.status.lastStep`,priority=1
 // +kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.lastError`,priority=2
 // +operator-sdk:csv:customresourcedefinitions:resources={{"Pattern","v1alpha1","patterns"}}
@@ -264,7 +767,12 @@ const (
 	Degraded     PatternConditionType = "Degraded"
 	Progressing  PatternConditionType = "Progressing"
 	Missing      PatternConditionType = "Missing"
-	Suspended    PatternConditionType = "Suspended"
+	// True while the reconciliation is paused by the paused annotation or the operator config
+	Suspended PatternConditionType = "Suspended"
+	// True when the clusterGroup application of any managed cluster is degraded
+	SpokeDegraded PatternConditionType = "SpokeDegraded"
+	// True while the applications are pinned to the last healthy revision by the rollback policy
+	RolledBack PatternConditionType = "RolledBack"
 )
 
 type PatternDeletionPhase string
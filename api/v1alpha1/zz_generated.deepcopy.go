@@ -21,9 +21,35 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletionConfig) DeepCopyInto(out *DeletionConfig) {
+	*out = *in
+	if in.ManagedClusterSelector != nil {
+		in, out := &in.ManagedClusterSelector, &out.ManagedClusterSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retain != nil {
+		in, out := &in.Retain, &out.Retain
+		*out = new(RetainConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletionConfig.
+func (in *DeletionConfig) DeepCopy() *DeletionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitConfig) DeepCopyInto(out *GitConfig) {
 	*out = *in
@@ -32,6 +58,7 @@ func (in *GitConfig) DeepCopyInto(out *GitConfig) {
 		*out = new(bool)
 		**out = **in
 	}
+	out.InClusterGitMirror = in.InClusterGitMirror
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitConfig.
@@ -44,9 +71,57 @@ func (in *GitConfig) DeepCopy() *GitConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitOpsBackoff) DeepCopyInto(out *GitOpsBackoff) {
+	*out = *in
+	if in.Factor != nil {
+		in, out := &in.Factor, &out.Factor
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsBackoff.
+func (in *GitOpsBackoff) DeepCopy() *GitOpsBackoff {
+	if in == nil {
+		return nil
+	}
+	out := new(GitOpsBackoff)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitOpsConfig) DeepCopyInto(out *GitOpsConfig) {
 	*out = *in
+	if in.SelfHeal != nil {
+		in, out := &in.SelfHeal, &out.SelfHeal
+		*out = new(bool)
+		**out = **in
+	}
+	out.SyncOptions = in.SyncOptions
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(GitOpsRetry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IgnoreDifferences != nil {
+		in, out := &in.IgnoreDifferences, &out.IgnoreDifferences
+		*out = make([]GitOpsIgnoreDifference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(RollbackPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsConfig.
@@ -59,6 +134,129 @@ func (in *GitOpsConfig) DeepCopy() *GitOpsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitOpsIgnoreDifference) DeepCopyInto(out *GitOpsIgnoreDifference) {
+	*out = *in
+	if in.JSONPointers != nil {
+		in, out := &in.JSONPointers, &out.JSONPointers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.JQPathExpressions != nil {
+		in, out := &in.JQPathExpressions, &out.JQPathExpressions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ManagedFieldsManagers != nil {
+		in, out := &in.ManagedFieldsManagers, &out.ManagedFieldsManagers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsIgnoreDifference.
+func (in *GitOpsIgnoreDifference) DeepCopy() *GitOpsIgnoreDifference {
+	if in == nil {
+		return nil
+	}
+	out := new(GitOpsIgnoreDifference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitOpsRetry) DeepCopyInto(out *GitOpsRetry) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(GitOpsBackoff)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsRetry.
+func (in *GitOpsRetry) DeepCopy() *GitOpsRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(GitOpsRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitOpsSyncOptions) DeepCopyInto(out *GitOpsSyncOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitOpsSyncOptions.
+func (in *GitOpsSyncOptions) DeepCopy() *GitOpsSyncOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(GitOpsSyncOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitServerStatus) DeepCopyInto(out *GitServerStatus) {
+	*out = *in
+	if in.MigrationStartTime != nil {
+		in, out := &in.MigrationStartTime, &out.MigrationStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitServerStatus.
+func (in *GitServerStatus) DeepCopy() *GitServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GitServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InClusterGitMirror) DeepCopyInto(out *InClusterGitMirror) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InClusterGitMirror.
+func (in *InClusterGitMirror) DeepCopy() *InClusterGitMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(InClusterGitMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MultiSourceConfig) DeepCopyInto(out *MultiSourceConfig) {
 	*out = *in
@@ -109,6 +307,19 @@ func (in *Pattern) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatternApplicationInfo) DeepCopyInto(out *PatternApplicationInfo) {
 	*out = *in
+	if in.LastSyncStartedAt != nil {
+		in, out := &in.LastSyncStartedAt, &out.LastSyncStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSyncFinishedAt != nil {
+		in, out := &in.LastSyncFinishedAt, &out.LastSyncFinishedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.UnhealthyResources != nil {
+		in, out := &in.UnhealthyResources, &out.UnhealthyResources
+		*out = make([]PatternApplicationResource, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternApplicationInfo.
@@ -121,6 +332,51 @@ func (in *PatternApplicationInfo) DeepCopy() *PatternApplicationInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternApplicationResource) DeepCopyInto(out *PatternApplicationResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternApplicationResource.
+func (in *PatternApplicationResource) DeepCopy() *PatternApplicationResource {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternApplicationResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternApplicationSync) DeepCopyInto(out *PatternApplicationSync) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternApplicationSync.
+func (in *PatternApplicationSync) DeepCopy() *PatternApplicationSync {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternApplicationSync)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternClusterStatus) DeepCopyInto(out *PatternClusterStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternClusterStatus.
+func (in *PatternClusterStatus) DeepCopy() *PatternClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatternCondition) DeepCopyInto(out *PatternCondition) {
 	*out = *in
@@ -138,6 +394,88 @@ func (in *PatternCondition) DeepCopy() *PatternCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternDeletionPreview) DeepCopyInto(out *PatternDeletionPreview) {
+	*out = *in
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SpokeApplications != nil {
+		in, out := &in.SpokeApplications, &out.SpokeApplications
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ManagedClusters != nil {
+		in, out := &in.ManagedClusters, &out.ManagedClusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retained != nil {
+		in, out := &in.Retained, &out.Retained
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternDeletionPreview.
+func (in *PatternDeletionPreview) DeepCopy() *PatternDeletionPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternDeletionPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternDeletionStatus) DeepCopyInto(out *PatternDeletionStatus) {
+	*out = *in
+	if in.PhaseStartTime != nil {
+		in, out := &in.PhaseStartTime, &out.PhaseStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PhaseDeadline != nil {
+		in, out := &in.PhaseDeadline, &out.PhaseDeadline
+		*out = (*in).DeepCopy()
+	}
+	if in.WaitingOn != nil {
+		in, out := &in.WaitingOn, &out.WaitingOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retained != nil {
+		in, out := &in.Retained, &out.Retained
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SharedResources != nil {
+		in, out := &in.SharedResources, &out.SharedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternDeletionStatus.
+func (in *PatternDeletionStatus) DeepCopy() *PatternDeletionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternDeletionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatternList) DeepCopyInto(out *PatternList) {
 	*out = *in
@@ -170,6 +508,51 @@ func (in *PatternList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternMaintenanceWindowStatus) DeepCopyInto(out *PatternMaintenanceWindowStatus) {
+	*out = *in
+	if in.NextWindowStart != nil {
+		in, out := &in.NextWindowStart, &out.NextWindowStart
+		*out = (*in).DeepCopy()
+	}
+	if in.NextWindowEnd != nil {
+		in, out := &in.NextWindowEnd, &out.NextWindowEnd
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternMaintenanceWindowStatus.
+func (in *PatternMaintenanceWindowStatus) DeepCopy() *PatternMaintenanceWindowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternMaintenanceWindowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternManagedCluster) DeepCopyInto(out *PatternManagedCluster) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternManagedCluster.
+func (in *PatternManagedCluster) DeepCopy() *PatternManagedCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternManagedCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatternParameter) DeepCopyInto(out *PatternParameter) {
 	*out = *in
@@ -185,6 +568,33 @@ func (in *PatternParameter) DeepCopy() *PatternParameter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternRollbackStatus) DeepCopyInto(out *PatternRollbackStatus) {
+	*out = *in
+	if in.LastHealthyTime != nil {
+		in, out := &in.LastHealthyTime, &out.LastHealthyTime
+		*out = (*in).DeepCopy()
+	}
+	if in.DegradedSince != nil {
+		in, out := &in.DegradedSince, &out.DegradedSince
+		*out = (*in).DeepCopy()
+	}
+	if in.PinnedAt != nil {
+		in, out := &in.PinnedAt, &out.PinnedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternRollbackStatus.
+func (in *PatternRollbackStatus) DeepCopy() *PatternRollbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternRollbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PatternSpec) DeepCopyInto(out *PatternSpec) {
 	*out = *in
@@ -193,7 +603,7 @@ func (in *PatternSpec) DeepCopyInto(out *PatternSpec) {
 	if in.GitOpsConfig != nil {
 		in, out := &in.GitOpsConfig, &out.GitOpsConfig
 		*out = new(GitOpsConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.ExtraParameters != nil {
 		in, out := &in.ExtraParameters, &out.ExtraParameters
@@ -205,6 +615,11 @@ func (in *PatternSpec) DeepCopyInto(out *PatternSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Deletion != nil {
+		in, out := &in.Deletion, &out.Deletion
+		*out = new(DeletionConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternSpec.
@@ -230,8 +645,56 @@ func (in *PatternStatus) DeepCopyInto(out *PatternStatus) {
 	if in.Applications != nil {
 		in, out := &in.Applications, &out.Applications
 		*out = make([]PatternApplicationInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]PatternClusterStatus, len(*in))
 		copy(*out, *in)
 	}
+	if in.ClustersLastUpdated != nil {
+		in, out := &in.ClustersLastUpdated, &out.ClustersLastUpdated
+		*out = (*in).DeepCopy()
+	}
+	if in.ManagedClusters != nil {
+		in, out := &in.ManagedClusters, &out.ManagedClusters
+		*out = make([]PatternManagedCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Deletion != nil {
+		in, out := &in.Deletion, &out.Deletion
+		*out = new(PatternDeletionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletionPreview != nil {
+		in, out := &in.DeletionPreview, &out.DeletionPreview
+		*out = new(PatternDeletionPreview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(PatternSyncStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(PatternMaintenanceWindowStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(PatternRollbackStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitServer != nil {
+		in, out := &in.GitServer, &out.GitServer
+		*out = new(GitServerStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternStatus.
@@ -243,3 +706,71 @@ func (in *PatternStatus) DeepCopy() *PatternStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatternSyncStatus) DeepCopyInto(out *PatternSyncStatus) {
+	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishedAt != nil {
+		in, out := &in.FinishedAt, &out.FinishedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Applications != nil {
+		in, out := &in.Applications, &out.Applications
+		*out = make([]PatternApplicationSync, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatternSyncStatus.
+func (in *PatternSyncStatus) DeepCopy() *PatternSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PatternSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetainConfig) DeepCopyInto(out *RetainConfig) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]RetainKind, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetainConfig.
+func (in *RetainConfig) DeepCopy() *RetainConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetainConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbackPolicy) DeepCopyInto(out *RollbackPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbackPolicy.
+func (in *RollbackPolicy) DeepCopy() *RollbackPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbackPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
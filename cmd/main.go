@@ -30,16 +30,21 @@ import (
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	argov1beta1api "github.com/argoproj-labs/argocd-operator/api/v1beta1"
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	gitopsv1alpha1 "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
 	controllers "github.com/hybrid-cloud-patterns/patterns-operator/internal/controller"
 	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/console"
@@ -60,6 +65,8 @@ func init() {
 	utilruntime.Must(gitopsv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(consolev1.AddToScheme(scheme))
 	utilruntime.Must(operatorv1.AddToScheme(scheme))
+	// Needed at manager creation time for the Application cache selector below
+	utilruntime.Must(argoapi.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -82,8 +89,20 @@ func main() {
 
 	setupLog.Info("detected operator namespace", "namespace", controllers.DetectOperatorNamespace())
 
+	// Only cache the argo applications created for a pattern, there can be many others
+	patternApplications, err := labels.NewRequirement(controllers.PatternApplicationLabel, selection.Exists, nil)
+	if err != nil {
+		setupLog.Error(err, "unable to build the application label selector")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
+		Scheme: scheme,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&argoapi.Application{}: {Label: labels.NewSelector().Add(*patternApplications)},
+			},
+		},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "f2850479.hybrid-cloud-patterns.io",
@@ -134,6 +153,13 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Pattern")
 		os.Exit(1)
 	}
+	if err = (&controllers.ApplicationStatusReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PatternApplications")
+		os.Exit(1)
+	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err = (&gitopsv1alpha1.PatternValidator{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Pattern")
@@ -19,19 +19,32 @@ package controllers
 // https://github.com/stolostron/cm-cli/blob/64e944330f6ca20c559abcd382d7712f10cb904f/pkg/cmd/cmd.go#L75
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
 )
 
 func haveACMHub(r *PatternReconciler) bool {
+	return isManagedACMHub(r.dynamicClient)
+}
+
+// isManagedACMHub returns true when the cluster runs an ACM hub deployed by a pattern
+func isManagedACMHub(dynamicClient dynamic.Interface) bool {
 	gvrMCH := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "multiclusterhubs"}
 
-	mch, err := r.dynamicClient.Resource(gvrMCH).Namespace("open-cluster-management").Get(context.Background(), "multiclusterhub", metav1.GetOptions{})
+	mch, err := dynamicClient.Resource(gvrMCH).Namespace("open-cluster-management").Get(context.Background(), "multiclusterhub", metav1.GetOptions{})
 	if err != nil {
 		log.Printf("Error obtaining hub: %s\n", err)
 		return false
@@ -43,9 +56,85 @@ func haveACMHub(r *PatternReconciler) bool {
 	)
 }
 
-// listManagedClusters lists all ManagedCluster resources (excluding local-cluster)
-// Returns a list of cluster names and an error
-func (r *PatternReconciler) listManagedClusters(ctx context.Context) ([]string, error) {
+// managedClusterPolicy returns the ManagedClusterPolicy of p, DetachOwned when unset
+func managedClusterPolicy(p *api.Pattern) api.ManagedClusterPolicy {
+	if p.Spec.Deletion == nil || p.Spec.Deletion.ManagedClusterPolicy == "" {
+		return api.DetachOwnedManagedClusters
+	}
+	return p.Spec.Deletion.ManagedClusterPolicy
+}
+
+// patternApplicationNames returns the names argo tracks the applications of p under: the app of apps and
+// the applications labeled with the pattern. Applications outside of the argo namespace are tracked as
+// <namespace>_<name>
+func patternApplicationNames(p *api.Pattern) map[string]bool {
+	names := map[string]bool{applicationName(p): true}
+	for _, app := range p.Status.Applications {
+		names[app.Name] = true
+		names[app.Namespace+"_"+app.Name] = true
+	}
+	return names
+}
+
+// createdByPattern returns true when the ManagedCluster was created, or imported, by one of the argo
+// applications of p
+func createdByPattern(mc *unstructured.Unstructured, p *api.Pattern) bool {
+	trackingID, tracked := mc.GetAnnotations()["argocd.argoproj.io/tracking-id"]
+	if !tracked {
+		return false
+	}
+	appName, _, _ := strings.Cut(trackingID, ":")
+	return patternApplicationNames(p)[appName]
+}
+
+// isManagedClusterOwned returns true when the ManagedCluster is labeled as owned by p or was created
+// by one of its argo applications
+func isManagedClusterOwned(mc *unstructured.Unstructured, p *api.Pattern) bool {
+	return mc.GetLabels()[PatternApplicationLabel] == p.Name || createdByPattern(mc, p)
+}
+
+// labelOwnedManagedClusters records on the ManagedClusters created by the applications of p that p owns
+// them, so that they stay owned once the applications are gone
+func (r *PatternReconciler) labelOwnedManagedClusters(ctx context.Context, p *api.Pattern) error {
+	gvrMC := schema.GroupVersionResource{
+		Group:    "cluster.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "managedclusters",
+	}
+
+	mcList, err := r.dynamicClient.Resource(gvrMC).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list ManagedClusters: %w", err)
+	}
+	for i := range mcList.Items {
+		item := &mcList.Items[i]
+		if item.GetName() == "local-cluster" || item.GetLabels()[PatternApplicationLabel] == p.Name || !createdByPattern(item, p) {
+			continue
+		}
+		patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, PatternApplicationLabel, p.Name)
+		if _, err := r.dynamicClient.Resource(gvrMC).Patch(ctx, item.GetName(), types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("failed to label ManagedCluster %q: %w", item.GetName(), err)
+		}
+		log.Printf("Labeled ManagedCluster %s as owned by pattern %s", item.GetName(), p.Name)
+	}
+	return nil
+}
+
+// managedClustersToDetach returns the ManagedCluster resources (excluding local-cluster) that the
+// ManagedClusterPolicy of p detaches when the pattern is deleted
+func (r *PatternReconciler) managedClustersToDetach(ctx context.Context, p *api.Pattern) ([]unstructured.Unstructured, error) {
+	policy := managedClusterPolicy(p)
+	if policy == api.KeepAllManagedClusters {
+		return nil, nil
+	}
+	selector := labels.Nothing()
+	if policy == api.SelectedManagedClusters && p.Spec.Deletion.ManagedClusterSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(p.Spec.Deletion.ManagedClusterSelector); err != nil {
+			return nil, fmt.Errorf("invalid managedClusterSelector: %w", err)
+		}
+	}
+
 	gvrMC := schema.GroupVersionResource{
 		Group:    "cluster.open-cluster-management.io",
 		Version:  "v1",
@@ -58,40 +147,61 @@ func (r *PatternReconciler) listManagedClusters(ctx context.Context) ([]string,
 		return nil, fmt.Errorf("failed to list ManagedClusters: %w", err)
 	}
 
-	var clusterNames []string
+	var clusters []unstructured.Unstructured
 	for _, item := range mcList.Items {
-		name := item.GetName()
 		// Exclude local-cluster (hub cluster)
-		if name != "local-cluster" {
-			clusterNames = append(clusterNames, name)
+		if item.GetName() == "local-cluster" {
+			continue
 		}
+		switch policy {
+		case api.DetachOwnedManagedClusters:
+			if !isManagedClusterOwned(&item, p) {
+				continue
+			}
+		case api.SelectedManagedClusters:
+			if !selector.Matches(labels.Set(item.GetLabels())) {
+				continue
+			}
+		}
+		clusters = append(clusters, item)
+	}
+
+	return clusters, nil
+}
+
+// listManagedClusters lists the ManagedCluster resources the pattern detaches when it is deleted
+// Returns a list of cluster names and an error
+func (r *PatternReconciler) listManagedClusters(ctx context.Context, p *api.Pattern) ([]string, error) {
+	clusters, err := r.managedClustersToDetach(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterNames []string
+	for i := range clusters {
+		clusterNames = append(clusterNames, clusters[i].GetName())
 	}
 
 	return clusterNames, nil
 }
 
-// deleteManagedClusters deletes all ManagedCluster resources (excluding local-cluster)
+// deleteManagedClusters deletes the ManagedCluster resources the pattern detaches when it is deleted
 // Returns the number of clusters deleted and an error
-func (r *PatternReconciler) deleteManagedClusters(ctx context.Context) (int, error) {
+func (r *PatternReconciler) deleteManagedClusters(ctx context.Context, p *api.Pattern) (int, error) {
 	gvrMC := schema.GroupVersionResource{
 		Group:    "cluster.open-cluster-management.io",
 		Version:  "v1",
 		Resource: "managedclusters",
 	}
 
-	// ManagedCluster is a cluster-scoped resource, so no namespace needed
-	mcList, err := r.dynamicClient.Resource(gvrMC).List(ctx, metav1.ListOptions{})
+	clusters, err := r.managedClustersToDetach(ctx, p)
 	if err != nil {
-		return 0, fmt.Errorf("failed to list ManagedClusters: %w", err)
+		return 0, err
 	}
 
 	deletedCount := 0
-	for _, item := range mcList.Items {
-		name := item.GetName()
-		// Exclude local-cluster (hub cluster)
-		if name == "local-cluster" {
-			continue
-		}
+	for i := range clusters {
+		name := clusters[i].GetName()
 
 		// Delete the managed cluster
 		err := r.dynamicClient.Resource(gvrMC).Delete(ctx, name, metav1.DeleteOptions{})
@@ -108,3 +218,169 @@ func (r *PatternReconciler) deleteManagedClusters(ctx context.Context) (int, err
 
 	return deletedCount, nil
 }
+
+// Label the clustergroup chart selects the clusters of a managedClusterGroup with by convention
+const ManagedClusterGroupLabel = "clusterGroup"
+
+// managedClusterGroup is an entry of clusterGroup.managedClusterGroups in the values of the hub
+type managedClusterGroup struct {
+	name     string
+	selector labels.Selector
+	// Label keys the selector looks at
+	keys []string
+}
+
+// getManagedClusterGroups parses clusterGroup.managedClusterGroups, which can be a list or a map,
+// from the values of the hub. Both the clusterSelector and the legacy acmlabels selectors are supported
+// and groups without a selector, which place no cluster, are skipped
+func getManagedClusterGroups(values map[string]any) ([]managedClusterGroup, error) {
+	var entries []map[string]any
+	var names []string
+	switch v := getClusterGroupValue("managedClusterGroups", values).(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for _, entry := range v {
+			if m, ok := entry.(map[string]any); ok {
+				entries = append(entries, m)
+				names = append(names, "")
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if m, ok := v[key].(map[string]any); ok {
+				entries = append(entries, m)
+				names = append(names, key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("could not parse managedClusterGroups: %v", v)
+	}
+
+	var groups []managedClusterGroup
+	for i, entry := range entries {
+		group := managedClusterGroup{name: names[i]}
+		if name, ok := entry["name"].(string); ok && name != "" {
+			group.name = name
+		}
+
+		selector := metav1.LabelSelector{}
+		if clusterSelector, ok := entry["clusterSelector"]; ok {
+			raw, err := json.Marshal(clusterSelector)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse the clusterSelector of %q: %w", group.name, err)
+			}
+			if err = json.Unmarshal(raw, &selector); err != nil {
+				return nil, fmt.Errorf("could not parse the clusterSelector of %q: %w", group.name, err)
+			}
+		} else if acmLabels, ok := entry["acmlabels"].([]any); ok {
+			selector.MatchLabels = map[string]string{}
+			for _, l := range acmLabels {
+				if m, ok := l.(map[string]any); ok {
+					selector.MatchLabels[fmt.Sprint(m["name"])] = fmt.Sprint(m["value"])
+				}
+			}
+		}
+		if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+			continue
+		}
+
+		var err error
+		if group.selector, err = metav1.LabelSelectorAsSelector(&selector); err != nil {
+			return nil, fmt.Errorf("invalid clusterSelector for %q: %w", group.name, err)
+		}
+		for key := range selector.MatchLabels {
+			group.keys = append(group.keys, key)
+		}
+		for _, expression := range selector.MatchExpressions {
+			group.keys = append(group.keys, expression.Key)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// getManagedClusters returns the clusters imported in the hub, except the hub itself, along with
+// the first of groups whose selector matches them and whether p owns them. Placing a cluster in one of
+// groups does not make it owned. It only reads the clusters, it also runs while p is paused
+func getManagedClusters(ctx context.Context, dynamicClient dynamic.Interface, p *api.Pattern, groups []managedClusterGroup) ([]api.PatternManagedCluster, error) {
+	gvrMC := schema.GroupVersionResource{
+		Group:    "cluster.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "managedclusters",
+	}
+
+	mcList, err := dynamicClient.Resource(gvrMC).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ManagedClusters: %w", err)
+	}
+
+	relevant := map[string]bool{ManagedClusterGroupLabel: true, PatternApplicationLabel: true}
+	for _, group := range groups {
+		for _, key := range group.keys {
+			relevant[key] = true
+		}
+	}
+
+	var clusters []api.PatternManagedCluster
+	for i := range mcList.Items {
+		item := &mcList.Items[i]
+		if item.GetName() == "local-cluster" {
+			continue
+		}
+		cluster := api.PatternManagedCluster{
+			Name:             item.GetName(),
+			Available:        managedClusterAvailable(item),
+			OpenShiftVersion: item.GetLabels()["openshiftVersion"],
+		}
+		for key, value := range item.GetLabels() {
+			if relevant[key] {
+				if cluster.Labels == nil {
+					cluster.Labels = map[string]string{}
+				}
+				cluster.Labels[key] = value
+			}
+		}
+		for _, group := range groups {
+			if group.selector.Matches(labels.Set(item.GetLabels())) {
+				cluster.ClusterGroup = group.name
+				break
+			}
+		}
+		cluster.Owned = isManagedClusterOwned(item, p)
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters, nil
+}
+
+// managedClusterAvailable returns the status of the ManagedClusterConditionAvailable condition
+func managedClusterAvailable(mc *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(mc.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]any)
+		if ok && condition["type"] == "ManagedClusterConditionAvailable" {
+			if status, ok := condition["status"].(string); ok {
+				return status
+			}
+		}
+	}
+	return string(metav1.ConditionUnknown)
+}
+
+// getACMVersion returns the version of the MultiClusterHub
+func getACMVersion(dynamicClient dynamic.Interface) string {
+	gvrMCH := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "multiclusterhubs"}
+
+	mch, err := dynamicClient.Resource(gvrMCH).Namespace("open-cluster-management").Get(context.Background(), "multiclusterhub", metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	version, _, _ := unstructured.NestedString(mch.Object, "status", "currentVersion")
+	return version
+}
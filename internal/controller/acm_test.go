@@ -14,6 +14,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -122,6 +123,7 @@ var _ = Describe("HaveACMHub", func() {
 })
 
 var _ = Describe("ListManagedClusters", func() {
+	detachAll := &api.Pattern{Spec: api.PatternSpec{Deletion: &api.DeletionConfig{ManagedClusterPolicy: api.DetachAllManagedClusters}}}
 	var (
 		patternReconciler *PatternReconciler
 		dynamicClient     *dynamicfake.FakeDynamicClient
@@ -158,7 +160,7 @@ var _ = Describe("ListManagedClusters", func() {
 		})
 
 		It("should return all clusters except local-cluster", func() {
-			clusters, err := patternReconciler.listManagedClusters(context.Background())
+			clusters, err := patternReconciler.listManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(clusters).To(HaveLen(2))
 			Expect(clusters).To(ContainElement("spoke-1"))
@@ -169,7 +171,7 @@ var _ = Describe("ListManagedClusters", func() {
 
 	Context("when there are no managed clusters", func() {
 		It("should return empty list", func() {
-			clusters, err := patternReconciler.listManagedClusters(context.Background())
+			clusters, err := patternReconciler.listManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(clusters).To(BeEmpty())
 		})
@@ -191,7 +193,7 @@ var _ = Describe("ListManagedClusters", func() {
 		})
 
 		It("should return empty list", func() {
-			clusters, err := patternReconciler.listManagedClusters(context.Background())
+			clusters, err := patternReconciler.listManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(clusters).To(BeEmpty())
 		})
@@ -205,7 +207,7 @@ var _ = Describe("ListManagedClusters", func() {
 		})
 
 		It("should return an error", func() {
-			_, err := patternReconciler.listManagedClusters(context.Background())
+			_, err := patternReconciler.listManagedClusters(context.Background(), detachAll)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to list ManagedClusters"))
 		})
@@ -213,6 +215,7 @@ var _ = Describe("ListManagedClusters", func() {
 })
 
 var _ = Describe("DeleteManagedClusters", func() {
+	detachAll := &api.Pattern{Spec: api.PatternSpec{Deletion: &api.DeletionConfig{ManagedClusterPolicy: api.DetachAllManagedClusters}}}
 	var (
 		patternReconciler *PatternReconciler
 		dynamicClient     *dynamicfake.FakeDynamicClient
@@ -249,7 +252,7 @@ var _ = Describe("DeleteManagedClusters", func() {
 		})
 
 		It("should delete all clusters except local-cluster", func() {
-			count, err := patternReconciler.deleteManagedClusters(context.Background())
+			count, err := patternReconciler.deleteManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(count).To(Equal(2))
 		})
@@ -257,7 +260,7 @@ var _ = Describe("DeleteManagedClusters", func() {
 
 	Context("when there are no managed clusters", func() {
 		It("should return 0", func() {
-			count, err := patternReconciler.deleteManagedClusters(context.Background())
+			count, err := patternReconciler.deleteManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(count).To(Equal(0))
 		})
@@ -279,7 +282,7 @@ var _ = Describe("DeleteManagedClusters", func() {
 		})
 
 		It("should return 0", func() {
-			count, err := patternReconciler.deleteManagedClusters(context.Background())
+			count, err := patternReconciler.deleteManagedClusters(context.Background(), detachAll)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(count).To(Equal(0))
 		})
@@ -293,7 +296,7 @@ var _ = Describe("DeleteManagedClusters", func() {
 		})
 
 		It("should return an error", func() {
-			_, err := patternReconciler.deleteManagedClusters(context.Background())
+			_, err := patternReconciler.deleteManagedClusters(context.Background(), detachAll)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to list ManagedClusters"))
 		})
@@ -319,9 +322,205 @@ var _ = Describe("DeleteManagedClusters", func() {
 		})
 
 		It("should return an error", func() {
-			_, err := patternReconciler.deleteManagedClusters(context.Background())
+			_, err := patternReconciler.deleteManagedClusters(context.Background(), detachAll)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to delete ManagedCluster"))
 		})
 	})
 })
+
+var _ = Describe("ManagedClusterPolicy", func() {
+	var patternReconciler *PatternReconciler
+	var pattern *api.Pattern
+
+	BeforeEach(func() {
+		gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+		newManagedCluster := func(name string, labels, annotations map[string]any) *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "cluster.open-cluster-management.io/v1",
+				"kind":       "ManagedCluster",
+				"metadata":   map[string]any{"name": name, "labels": labels, "annotations": annotations},
+			}}
+		}
+		patternReconciler = &PatternReconciler{
+			dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				gvrMC: "ManagedClusterList",
+			},
+				newManagedCluster("local-cluster", map[string]any{PatternApplicationLabel: "test"}, nil),
+				newManagedCluster("owned", map[string]any{PatternApplicationLabel: "test", "env": "prod"}, nil),
+				newManagedCluster("created", nil, map[string]any{"argocd.argoproj.io/tracking-id": "test-hub:cluster.open-cluster-management.io/ManagedCluster:/created"}),
+				newManagedCluster("other-pattern", map[string]any{PatternApplicationLabel: "other"}, nil),
+				newManagedCluster("other-team", map[string]any{"env": "prod"}, map[string]any{"argocd.argoproj.io/tracking-id": "fleet:cluster.open-cluster-management.io/ManagedCluster:/other-team"}),
+			),
+		}
+		pattern = &api.Pattern{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: api.PatternSpec{ClusterGroupName: "hub"}}
+	})
+
+	It("should only detach the owned clusters by default", func() {
+		clusters, err := patternReconciler.listManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters).To(ConsistOf("owned", "created"))
+	})
+
+	It("should label the clusters created by the applications of the pattern only", func() {
+		Expect(patternReconciler.labelOwnedManagedClusters(context.Background(), pattern)).To(Succeed())
+		gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+		created, err := patternReconciler.dynamicClient.Resource(gvrMC).Get(context.Background(), "created", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created.GetLabels()).To(HaveKeyWithValue(PatternApplicationLabel, "test"))
+		otherTeam, err := patternReconciler.dynamicClient.Resource(gvrMC).Get(context.Background(), "other-team", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(otherTeam.GetLabels()).ToNot(HaveKey(PatternApplicationLabel))
+	})
+
+	It("should detach all the clusters", func() {
+		pattern.Spec.Deletion = &api.DeletionConfig{ManagedClusterPolicy: api.DetachAllManagedClusters}
+		clusters, err := patternReconciler.listManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters).To(ConsistOf("owned", "created", "other-pattern", "other-team"))
+	})
+
+	It("should keep all the clusters", func() {
+		pattern.Spec.Deletion = &api.DeletionConfig{ManagedClusterPolicy: api.KeepAllManagedClusters}
+		count, err := patternReconciler.deleteManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(count).To(BeZero())
+	})
+
+	It("should detach the selected clusters", func() {
+		pattern.Spec.Deletion = &api.DeletionConfig{
+			ManagedClusterPolicy:   api.SelectedManagedClusters,
+			ManagedClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+		}
+		count, err := patternReconciler.deleteManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(count).To(Equal(2))
+		clusters, err := patternReconciler.listManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters).To(BeEmpty())
+	})
+
+	It("should detach nothing without a selector", func() {
+		pattern.Spec.Deletion = &api.DeletionConfig{ManagedClusterPolicy: api.SelectedManagedClusters}
+		clusters, err := patternReconciler.listManagedClusters(context.Background(), pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters).To(BeEmpty())
+	})
+})
+
+var _ = Describe("GetManagedClusterGroups", func() {
+	It("should parse the list form with clusterSelectors", func() {
+		values := map[string]any{"clusterGroup": map[string]any{"managedClusterGroups": []any{
+			map[string]any{"name": "region-one", "clusterSelector": map[string]any{
+				"matchLabels":      map[string]any{"clusterGroup": "region-one"},
+				"matchExpressions": []any{map[string]any{"key": "vendor", "operator": "In", "values": []any{"OpenShift"}}},
+			}},
+			map[string]any{"name": "no-selector"},
+		}}}
+		groups, err := getManagedClusterGroups(values)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].name).To(Equal("region-one"))
+		Expect(groups[0].keys).To(ConsistOf("clusterGroup", "vendor"))
+	})
+
+	It("should parse the map form with acmlabels", func() {
+		values := map[string]any{"clusterGroup": map[string]any{"managedClusterGroups": map[string]any{
+			"exampleRegion": map[string]any{"name": "region-one", "acmlabels": []any{map[string]any{"name": "clusterGroup", "value": "region-one"}}},
+			"devel":         map[string]any{"acmlabels": []any{map[string]any{"name": "env", "value": "dev"}}},
+		}}}
+		groups, err := getManagedClusterGroups(values)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(groups).To(HaveLen(2))
+		Expect(groups[0].name).To(Equal("devel"))
+		Expect(groups[1].name).To(Equal("region-one"))
+	})
+
+	It("should return nothing without managedClusterGroups", func() {
+		groups, err := getManagedClusterGroups(map[string]any{"clusterGroup": map[string]any{"name": "hub"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(groups).To(BeEmpty())
+	})
+
+	It("should reject invalid selectors", func() {
+		values := map[string]any{"clusterGroup": map[string]any{"managedClusterGroups": []any{
+			map[string]any{"name": "broken", "clusterSelector": map[string]any{
+				"matchExpressions": []any{map[string]any{"key": "vendor", "operator": "Bogus"}},
+			}},
+		}}}
+		_, err := getManagedClusterGroups(values)
+		Expect(err).To(MatchError(ContainSubstring(`invalid clusterSelector for "broken"`)))
+	})
+})
+
+var _ = Describe("GetManagedClusters", func() {
+	var dynamicClient *dynamicfake.FakeDynamicClient
+
+	newManagedCluster := func(name string, labels map[string]any, available string) *unstructured.Unstructured {
+		mc := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "cluster.open-cluster-management.io/v1",
+			"kind":       "ManagedCluster",
+			"metadata":   map[string]any{"name": name, "labels": labels},
+		}}
+		if available != "" {
+			mc.Object["status"] = map[string]any{"conditions": []any{
+				map[string]any{"type": "ManagedClusterConditionAvailable", "status": available},
+			}}
+		}
+		return mc
+	}
+
+	BeforeEach(func() {
+		gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+		dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			gvrMC: "ManagedClusterList",
+		},
+			newManagedCluster("local-cluster", map[string]any{"local-cluster": "true"}, "True"),
+			newManagedCluster("spoke-2", map[string]any{"vendor": "OpenShift", "cloud": "Amazon"}, ""),
+			newManagedCluster("spoke-1", map[string]any{"clusterGroup": "region-one", "openshiftVersion": "4.18.3", "cloud": "Amazon"}, "True"),
+		)
+	})
+
+	It("should report the clusters and the group they are bound to", func() {
+		groups, err := getManagedClusterGroups(map[string]any{"clusterGroup": map[string]any{"managedClusterGroups": []any{
+			map[string]any{"name": "region-one", "clusterSelector": map[string]any{"matchLabels": map[string]any{"clusterGroup": "region-one"}}},
+		}}})
+		Expect(err).ToNot(HaveOccurred())
+
+		pattern := &api.Pattern{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: api.PatternSpec{ClusterGroupName: "hub"}}
+		clusters, err := getManagedClusters(context.Background(), dynamicClient, pattern, groups)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters).To(Equal([]api.PatternManagedCluster{
+			{
+				Name:             "spoke-1",
+				ClusterGroup:     "region-one",
+				Labels:           map[string]string{"clusterGroup": "region-one"},
+				Available:        "True",
+				OpenShiftVersion: "4.18.3",
+			},
+			{
+				Name:      "spoke-2",
+				Available: "Unknown",
+			},
+		}))
+
+		// Matching a group neither makes a cluster owned nor labels it
+		for _, action := range dynamicClient.Actions() {
+			Expect(action.GetVerb()).To(Equal("list"))
+		}
+		gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+		spoke, err := dynamicClient.Resource(gvrMC).Get(context.Background(), "spoke-1", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spoke.GetLabels()).ToNot(HaveKey(PatternApplicationLabel))
+
+		// Owned once created by the app of apps
+		spoke.SetAnnotations(map[string]string{"argocd.argoproj.io/tracking-id": "test-hub:cluster.open-cluster-management.io/ManagedCluster:/spoke-1"})
+		_, err = dynamicClient.Resource(gvrMC).Update(context.Background(), spoke, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		clusters, err = getManagedClusters(context.Background(), dynamicClient, pattern, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusters[0].Owned).To(BeTrue())
+		Expect(clusters[0].ClusterGroup).To(BeEmpty())
+		Expect(clusters[1].Owned).To(BeFalse())
+	})
+})
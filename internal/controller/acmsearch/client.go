@@ -0,0 +1,388 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package acmsearch is a client for the GraphQL API of the ACM search service, which indexes
+// the resources of all the managed clusters of an ACM hub
+package acmsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultURL is the in-cluster address of the search API
+	DefaultURL = "https://search-search-api.open-cluster-management.svc.cluster.local:4010/searchapi/graphql"
+	// DefaultTokenFile is the service account token presented to the search API
+	DefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec
+	// DefaultCAFile is the OpenShift service CA, which signs the certificate of the search API
+	DefaultCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	// DefaultPageSize is the number of items requested at once. The search API caps the size
+	// of a single result, so bigger results are fetched in pages
+	DefaultPageSize = 10000
+	// HubClusterName is the name ACM gives to the hub itself
+	HubClusterName = "local-cluster"
+
+	// Projected service account tokens are rotated by the kubelet, so the file is read again
+	// from time to time and whenever the search API rejects the token
+	tokenRefreshInterval = time.Minute
+
+	searchQuery = "query searchResult($input: [SearchInput]) { searchResult: search(input: $input) { count items } }"
+)
+
+// ErrUnauthorized is returned when the search API keeps rejecting the token
+var ErrUnauthorized = errors.New("the search service rejected the token")
+
+// Filter restricts a search to the items whose property matches one of the values. A value
+// starting with ! excludes the items matching the rest of it
+type Filter struct {
+	Property string   `json:"property"`
+	Values   []string `json:"values"`
+}
+
+// Query is a search of the resources matching all of its filters
+type Query struct {
+	Filters []Filter
+}
+
+// Item is a resource as indexed by the search service, a map of its properties
+type Item map[string]any
+
+// Application is an argo Application as indexed by the search service
+type Application struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Cluster      string `json:"cluster"`
+	SyncStatus   string `json:"syncStatus"`
+	HealthStatus string `json:"healthStatus"`
+}
+
+// ApplicationQuery selects the argo Applications to return
+type ApplicationQuery struct {
+	// Values the namespace of the applications must match (e.g. "!openshift-gitops"), any
+	// namespace when empty
+	Namespaces []string
+	// Also return the applications of the hub cluster
+	IncludeHub bool
+}
+
+// Filters returns the search filters of the query
+func (q ApplicationQuery) Filters() []Filter {
+	filters := []Filter{
+		{Property: "apigroup", Values: []string{"argoproj.io"}},
+		{Property: "kind", Values: []string{"Application"}},
+	}
+	if !q.IncludeHub {
+		filters = append(filters, Filter{Property: "cluster", Values: []string{"!" + HubClusterName}})
+	}
+	if len(q.Namespaces) > 0 {
+		filters = append(filters, Filter{Property: "namespace", Values: q.Namespaces})
+	}
+	return filters
+}
+
+// Client queries the ACM search service
+type Client interface {
+	// Search returns all the items matching the query
+	Search(ctx context.Context, query Query) ([]Item, error)
+	// Applications returns the argo Applications matching the query
+	Applications(ctx context.Context, query ApplicationQuery) ([]Application, error)
+}
+
+// Options configure a search client. Only URL is mandatory
+type Options struct {
+	URL string
+	// Token to present. When empty it is read from TokenFile, DefaultTokenFile by default
+	Token     string
+	TokenFile string
+	// PEM bundle of the CA that signed the certificate of the search API, DefaultCAFile by
+	// default. The system CAs are used when it does not exist
+	CAFile string
+	// Skip the verification of the search API certificate, which is only useful when
+	// reaching it through a port-forward
+	InsecureSkipVerify bool
+	// DefaultPageSize when zero
+	PageSize int
+	// Proxy selects the proxy of the requests, http.ProxyFromEnvironment when nil
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+type client struct {
+	url        string
+	pageSize   int
+	token      *tokenSource
+	httpClient *http.Client
+}
+
+// New returns a search client configured by opts
+func New(opts Options) (Client, error) {
+	parsedURL, err := url.Parse(opts.URL)
+	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") || parsedURL.Host == "" {
+		return nil, fmt.Errorf("invalid search API URL: %s", opts.URL)
+	}
+	cleanURL := url.URL{
+		Scheme: parsedURL.Scheme,
+		Host:   parsedURL.Host,
+		Path:   parsedURL.Path,
+	}
+
+	if opts.TokenFile == "" {
+		opts.TokenFile = DefaultTokenFile
+	}
+	if opts.CAFile == "" {
+		opts.CAFile = DefaultCAFile
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	if opts.Proxy == nil {
+		opts.Proxy = http.ProxyFromEnvironment
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify, //nolint:gosec // Opt-in for port-forwards
+	}
+	if !opts.InsecureSkipVerify {
+		if tlsConfig.RootCAs, err = loadCAs(opts.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &client{
+		url:      cleanURL.String(),
+		pageSize: opts.PageSize,
+		token:    &tokenSource{static: opts.Token, file: opts.TokenFile},
+		httpClient: &http.Client{
+			Timeout: time.Minute,
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				Proxy:           opts.Proxy,
+			},
+		},
+	}, nil
+}
+
+// NewFromEnvironment returns a client for the in-cluster search API. The ACM_SEARCH_API_URL,
+// ACM_SEARCH_API_TOKEN, ACM_SEARCH_API_CA_FILE and ACM_SEARCH_API_INSECURE environment
+// variables override the defaults, e.g. to run the operator locally against a port-forward:
+//
+//	kubectl port-forward -n open-cluster-management svc/search-search-api 4010:4010
+//	ACM_SEARCH_API_URL=https://localhost:4010/searchapi/graphql ACM_SEARCH_API_INSECURE=true
+func NewFromEnvironment(proxy func(*http.Request) (*url.URL, error)) (Client, error) {
+	opts := Options{
+		URL:                os.Getenv("ACM_SEARCH_API_URL"),
+		Token:              os.Getenv("ACM_SEARCH_API_TOKEN"),
+		CAFile:             os.Getenv("ACM_SEARCH_API_CA_FILE"),
+		InsecureSkipVerify: strings.EqualFold(os.Getenv("ACM_SEARCH_API_INSECURE"), "true"),
+		Proxy:              proxy,
+	}
+	if opts.URL == "" {
+		opts.URL = DefaultURL
+	}
+	return New(opts)
+}
+
+// loadCAs returns the system CAs along with the ones in caFile, when it exists
+func loadCAs(caFile string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(caFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return pool, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the search API CA %s: %w", caFile, err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the search API CA %s", caFile)
+	}
+	return pool, nil
+}
+
+func (c *client) Search(ctx context.Context, query Query) ([]Item, error) {
+	var items []Item
+	err := c.searchAll(ctx, query.Filters, func(raw json.RawMessage) error {
+		var item Item
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func (c *client) Applications(ctx context.Context, query ApplicationQuery) ([]Application, error) {
+	var apps []Application
+	err := c.searchAll(ctx, query.Filters(), func(raw json.RawMessage) error {
+		var app Application
+		if err := json.Unmarshal(raw, &app); err != nil {
+			return err
+		}
+		apps = append(apps, app)
+		return nil
+	})
+	return apps, err
+}
+
+// searchAll runs the search one page at a time and hands each item to add
+func (c *client) searchAll(ctx context.Context, filters []Filter, add func(json.RawMessage) error) error {
+	for offset := 0; ; offset += c.pageSize {
+		count, items, err := c.searchPage(ctx, filters, offset)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := add(item); err != nil {
+				return fmt.Errorf("failed to parse search result: %w", err)
+			}
+		}
+		if len(items) < c.pageSize || offset+len(items) >= count {
+			return nil
+		}
+	}
+}
+
+// searchResponse is the GraphQL response to searchQuery
+type searchResponse struct {
+	Data struct {
+		SearchResult []struct {
+			Count int               `json:"count"`
+			Items []json.RawMessage `json:"items"`
+		} `json:"searchResult"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// searchPage returns the total number of matching items and the page starting at offset
+func (c *client) searchPage(ctx context.Context, filters []Filter, offset int) (int, []json.RawMessage, error) {
+	body, err := json.Marshal(map[string]any{
+		"operationName": "searchResult",
+		"query":         searchQuery,
+		"variables": map[string]any{
+			"input": []map[string]any{
+				{
+					"filters": filters,
+					"limit":   c.pageSize,
+					"offset":  offset,
+					// Pages are only consistent with a stable order
+					"orderBy": "name asc",
+				},
+			},
+		},
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal GraphQL query: %w", err)
+	}
+
+	respBody, err := c.post(ctx, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var response searchResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return 0, nil, fmt.Errorf("search service returned an error: %s", response.Errors[0].Message)
+	}
+	if len(response.Data.SearchResult) == 0 {
+		return 0, nil, nil
+	}
+	return response.Data.SearchResult[0].Count, response.Data.SearchResult[0].Items, nil
+}
+
+// post sends the query, reading the token again and retrying once when it is rejected
+func (c *client) post(ctx context.Context, body []byte) ([]byte, error) {
+	for attempt := range 2 {
+		token, err := c.token.get(attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make HTTP request to search service: %w", err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			continue
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("search service returned status %d: %s", resp.StatusCode, string(respBody))
+		}
+		return respBody, nil
+	}
+	return nil, ErrUnauthorized
+}
+
+// tokenSource returns the static token if any, otherwise the content of file which is read
+// again every tokenRefreshInterval
+type tokenSource struct {
+	static string
+	file   string
+
+	mu     sync.Mutex
+	token  string
+	readAt time.Time
+}
+
+func (t *tokenSource) get(refresh bool) (string, error) {
+	if t.static != "" {
+		return t.static, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && !refresh && time.Since(t.readAt) < tokenRefreshInterval {
+		return t.token, nil
+	}
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read serviceaccount token: %w", err)
+	}
+	t.token = strings.TrimSpace(string(data))
+	t.readAt = time.Now()
+	return t.token, nil
+}
@@ -0,0 +1,129 @@
+package acmsearch
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	apps := []Application{
+		{Name: "hub", Namespace: "openshift-gitops", Cluster: HubClusterName, SyncStatus: "Synced", HealthStatus: "Healthy"},
+		{Name: "region-one", Namespace: "openshift-gitops", Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Healthy"},
+		{Name: "config-demo", Namespace: "region-one", Cluster: "region-one", SyncStatus: "OutOfSync", HealthStatus: "Degraded"},
+	}
+	var server *FakeServer
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("over http", func() {
+		BeforeEach(func() {
+			server = NewFakeServer("test-token", apps...)
+		})
+
+		It("should return the spoke applications", func() {
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token"})
+			Expect(err).ToNot(HaveOccurred())
+
+			found, err := client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(ConsistOf(apps[1], apps[2]))
+
+			found, err = client.Applications(context.Background(), ApplicationQuery{Namespaces: []string{"!openshift-gitops"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(ConsistOf(apps[2]))
+
+			found, err = client.Applications(context.Background(), ApplicationQuery{Namespaces: []string{"openshift-gitops"}, IncludeHub: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(ConsistOf(apps[0], apps[1]))
+		})
+
+		It("should run generic queries", func() {
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token"})
+			Expect(err).ToNot(HaveOccurred())
+
+			items, err := client.Search(context.Background(), Query{Filters: []Filter{{Property: "healthStatus", Values: []string{"Degraded"}}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(items).To(HaveLen(1))
+			Expect(items[0]).To(HaveKeyWithValue("name", "config-demo"))
+		})
+
+		It("should fetch big results one page at a time", func() {
+			var many []Application
+			for i := range 25 {
+				many = append(many, Application{Name: fmt.Sprintf("app-%02d", i), Namespace: "ns", Cluster: "region-one"})
+			}
+			server.SetApplications(many...)
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token", PageSize: 10})
+			Expect(err).ToNot(HaveOccurred())
+
+			found, err := client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(Equal(many))
+			Expect(server.Requests()).To(Equal(3))
+		})
+
+		It("should read the token file again when the token is rejected", func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("test-token\n"), 0o600)).To(Succeed())
+			client, err := New(Options{URL: server.SearchURL(), TokenFile: tokenFile})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+
+			// The kubelet rotated the token
+			server.SetToken("rotated-token")
+			Expect(os.WriteFile(tokenFile, []byte("rotated-token\n"), 0o600)).To(Succeed())
+			_, err = client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+
+			server.SetToken("revoked")
+			_, err = client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).To(MatchError(ErrUnauthorized))
+		})
+
+		It("should reject invalid URLs", func() {
+			_, err := New(Options{URL: "ftp://search"})
+			Expect(err).To(MatchError(ContainSubstring("invalid search API URL")))
+		})
+	})
+
+	Context("over https", func() {
+		BeforeEach(func() {
+			server = NewFakeTLSServer("test-token", apps...)
+		})
+
+		It("should trust the service CA", func() {
+			caFile := filepath.Join(GinkgoT().TempDir(), "service-ca.crt")
+			caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			Expect(os.WriteFile(caFile, caPEM, 0o600)).To(Succeed())
+
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token", CAFile: caFile})
+			Expect(err).ToNot(HaveOccurred())
+			found, err := client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(HaveLen(2))
+		})
+
+		It("should refuse an unknown certificate", func() {
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token", CAFile: filepath.Join(GinkgoT().TempDir(), "missing.crt")})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).To(MatchError(ContainSubstring("certificate")))
+		})
+
+		It("should skip the verification when asked to", func() {
+			client, err := New(Options{URL: server.SearchURL(), Token: "test-token", InsecureSkipVerify: true})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Applications(context.Background(), ApplicationQuery{})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
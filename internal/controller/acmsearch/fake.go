@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acmsearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FakeServer is a stand-in for the search API in tests. It serves a set of argo Applications,
+// honoring the filters, the pagination and the token of the queries
+type FakeServer struct {
+	*httptest.Server
+	// Path the GraphQL API is served on
+	Path string
+
+	mu           sync.Mutex
+	token        string
+	applications []Application
+	requests     int
+}
+
+// NewFakeServer starts a plain http fake search API accepting token
+func NewFakeServer(token string, applications ...Application) *FakeServer {
+	f := &FakeServer{Path: "/searchapi/graphql", token: token, applications: applications}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+// NewFakeTLSServer starts a https fake search API accepting token. Its CA is the certificate
+// of f.Server
+func NewFakeTLSServer(token string, applications ...Application) *FakeServer {
+	f := &FakeServer{Path: "/searchapi/graphql", token: token, applications: applications}
+	f.Server = httptest.NewTLSServer(f)
+	return f
+}
+
+// SearchURL is the URL to configure the client with
+func (f *FakeServer) SearchURL() string {
+	return f.URL + f.Path
+}
+
+// SetApplications replaces the applications served
+func (f *FakeServer) SetApplications(applications ...Application) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applications = applications
+}
+
+// SetToken changes the token accepted, as when the service account token is rotated
+func (f *FakeServer) SetToken(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = token
+}
+
+// Requests returns the number of queries answered
+func (f *FakeServer) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path != f.Path || r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var query struct {
+		Variables struct {
+			Input []struct {
+				Filters []Filter `json:"filters"`
+				Limit   int      `json:"limit"`
+				Offset  int      `json:"offset"`
+			} `json:"input"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil || len(query.Variables.Input) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.requests++
+	input := query.Variables.Input[0]
+
+	var matches []Application
+	for _, app := range f.applications {
+		if applicationMatches(app, input.Filters) {
+			matches = append(matches, app)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Application) int { return strings.Compare(a.Name, b.Name) })
+	count := len(matches)
+	if input.Offset < len(matches) {
+		matches = matches[input.Offset:]
+	} else {
+		matches = nil
+	}
+	if input.Limit > 0 && len(matches) > input.Limit {
+		matches = matches[:input.Limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{
+			"searchResult": []map[string]any{{"count": count, "items": matches}},
+		},
+	})
+}
+
+func applicationMatches(app Application, filters []Filter) bool {
+	properties := map[string]string{
+		"apigroup":     "argoproj.io",
+		"kind":         "Application",
+		"name":         app.Name,
+		"namespace":    app.Namespace,
+		"cluster":      app.Cluster,
+		"syncStatus":   app.SyncStatus,
+		"healthStatus": app.HealthStatus,
+	}
+	for _, filter := range filters {
+		value := properties[filter.Property]
+		matched := false
+		for _, want := range filter.Values {
+			if excluded, ok := strings.CutPrefix(want, "!"); ok {
+				matched = value != excluded
+			} else {
+				matched = strings.EqualFold(value, want)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
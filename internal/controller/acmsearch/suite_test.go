@@ -0,0 +1,13 @@
+package acmsearch
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestACMSearch(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ACM Search Suite")
+}
@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"encoding/base64"
 	"encoding/hex"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -88,7 +89,7 @@ func getDeviceHash(p *api.Pattern) string {
 }
 
 func getBaseGitRepo(p *api.Pattern) string {
-	s, _ := extractRepositoryName(p.Spec.GitConfig.TargetRepo)
+	s, _ := extractRepositoryName(getTargetRepo(p))
 	return s
 }
 
@@ -153,6 +154,20 @@ func hasBit(n int, pos uint) bool {
 	return (val > 0)
 }
 
+// newClient returns a segment client whose traffic goes through the cluster-wide proxy
+func (v *VpAnalytics) newClient() analytics.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = getClusterProxy().proxyFunc()
+	client, err := analytics.NewWithConfig(v.apiKey, analytics.Config{
+		Transport: transport,
+	})
+	if err != nil {
+		v.logger.Info("Could not create analytics client with proxy settings:", "info", err)
+		return analytics.New(v.apiKey)
+	}
+	return client
+}
+
 // This called at the beginning of the reconciliation loop and only once
 // returns true if the status object in the crd should be updated
 func (v *VpAnalytics) SendPatternInstallationInfo(p *api.Pattern) bool {
@@ -167,7 +182,7 @@ func (v *VpAnalytics) SendPatternInstallationInfo(p *api.Pattern) bool {
 		properties.Set(k, v)
 	}
 	properties.Set("pattern", p.Name)
-	client := analytics.New(v.apiKey)
+	client := v.newClient()
 	defer client.Close()
 	id := analytics.Identify{
 		UserId:  getNewUUID(p),
@@ -198,7 +213,7 @@ func (v *VpAnalytics) SendPatternStartEventInfo(p *api.Pattern) bool {
 		return false
 	}
 
-	client := analytics.New(v.apiKey)
+	client := v.newClient()
 	defer client.Close()
 	err := retryAnalytics(v.logger, 2, 1, getAnalyticsTrack(p, PatternStartEvent), client.Enqueue)
 	if err != nil {
@@ -219,7 +234,7 @@ func (v *VpAnalytics) SendPatternEndEventInfo(p *api.Pattern) bool {
 		return false
 	}
 	var event string
-	client := analytics.New(v.apiKey)
+	client := v.newClient()
 	defer client.Close()
 
 	// If we already sent the end event once, let's now call it refresh event from now on
@@ -0,0 +1,316 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
+)
+
+// Version of the argoproj.io API serving Applications
+const ArgoApplicationVersion = "v1alpha1"
+
+const (
+	// Set by ACM on the hub applications it pulls to a managed cluster
+	ocmManagedClusterAnnotation = "apps.open-cluster-management.io/ocm-managed-cluster"
+	// Destination name argo gives to the cluster it runs in
+	argoInClusterName = "in-cluster"
+	// Keep the status of applications with lots of broken resources to a reasonable size
+	maxUnhealthyResources = 10
+)
+
+// ApplicationStatusReconciler keeps Status.Applications of each Pattern in step with the argo
+// applications created for it. It is driven by a watch on those applications, so sync and health
+// changes are reported right away and regardless of how far the Pattern reconcile loop got.
+// On an ACM hub it also periodically collects the applications of the spoke clusters
+// into Status.Clusters
+type ApplicationStatusReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	fullClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	mgr           ctrl.Manager
+	ctrl          crcontroller.Controller
+	watchStarted  bool
+
+	acmSearch       acmsearch.Client
+	lastSpokeSearch map[types.NamespacedName]time.Time
+}
+
+func (r *ApplicationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	instance := &api.Pattern{}
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// The Application CRD only shows up once the pattern installed the gitops operator
+	if !r.startApplicationWatch() {
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	var applications argoapi.ApplicationList
+	if err := r.List(ctx, &applications, client.MatchingLabels{PatternApplicationLabel: instance.Name}); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	original := instance.DeepCopy()
+	setApplicationsStatus(&instance.Status, applications.Items)
+	result := reconcile.Result{RequeueAfter: r.updateSpokeStatus(instance)}
+	if equality.Semantic.DeepEqual(original.Status, instance.Status) {
+		return result, nil
+	}
+	// Patch rather than update so that we do not need to win the race against the Pattern
+	// reconcile loop, which owns all the other status fields
+	if err := r.Status().Patch(ctx, instance, client.MergeFrom(original)); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	return result, nil
+}
+
+// keepApplicationsStatus copies from stored into status the fields this controller maintains, so that
+// the Pattern reconcile loop leaves them alone when it stores its own fields
+func keepApplicationsStatus(status, stored *api.PatternStatus) {
+	status.Applications = stored.Applications
+	status.ApplicationsHealth = stored.ApplicationsHealth
+	status.ApplicationsSync = stored.ApplicationsSync
+	status.Clusters = stored.Clusters
+	status.ClustersLastUpdated = stored.ClustersLastUpdated
+	status.ManagedClusters = stored.ManagedClusters
+	status.ACMVersion = stored.ACMVersion
+	removePatternCondition(status, api.SpokeDegraded)
+	if _, condition := getPatternConditionByType(stored.Conditions, api.SpokeDegraded); condition != nil {
+		status.Conditions = append(status.Conditions, *condition)
+	}
+}
+
+// setApplicationsStatus records the state of the given applications in status along with the
+// worst health and the overall sync state across all of them
+func setApplicationsStatus(status *api.PatternStatus, applications []argoapi.Application) {
+	status.Applications = nil
+	status.ApplicationsHealth = ""
+	status.ApplicationsSync = ""
+	if len(applications) == 0 {
+		return
+	}
+
+	worstHealth := string(health.HealthStatusHealthy)
+	sync := string(argoapi.SyncStatusCodeSynced)
+	for i := range applications {
+		app := &applications[i]
+		status.Applications = append(status.Applications, applicationInfo(app))
+		worstHealth = worseHealth(worstHealth, string(app.Status.Health.Status))
+		sync = combineSync(sync, string(app.Status.Sync.Status))
+	}
+	sort.Slice(status.Applications, func(i, j int) bool {
+		if status.Applications[i].Namespace != status.Applications[j].Namespace {
+			return status.Applications[i].Namespace < status.Applications[j].Namespace
+		}
+		return status.Applications[i].Name < status.Applications[j].Name
+	})
+	status.ApplicationsHealth = worstHealth
+	status.ApplicationsSync = sync
+}
+
+// worseHealth returns the worse of two application health statuses, a missing status counting
+// as Unknown
+func worseHealth(current, other string) string {
+	if other == "" {
+		other = string(health.HealthStatusUnknown)
+	}
+	if health.IsWorse(health.HealthStatusCode(current), health.HealthStatusCode(other)) {
+		return other
+	}
+	return current
+}
+
+// combineSync folds the sync status of one more application into an overall sync status:
+// OutOfSync wins over anything else, then Unknown
+func combineSync(current, other string) string {
+	switch {
+	case other == string(argoapi.SyncStatusCodeOutOfSync):
+		return other
+	case other != string(argoapi.SyncStatusCodeSynced) && current == string(argoapi.SyncStatusCodeSynced):
+		return string(argoapi.SyncStatusCodeUnknown)
+	}
+	return current
+}
+
+// applicationInfo extracts what we report about an application
+func applicationInfo(app *argoapi.Application) api.PatternApplicationInfo {
+	info := api.PatternApplicationInfo{
+		Name:            app.Name,
+		Namespace:       app.Namespace,
+		AppHealthStatus: string(app.Status.Health.Status),
+		AppSyncStatus:   string(app.Status.Sync.Status),
+		Cluster:         applicationCluster(app),
+		Revision:        app.Status.Sync.Revision,
+	}
+	if info.Revision == "" {
+		// Multi-source applications
+		info.Revision = strings.Join(app.Status.Sync.Revisions, ",")
+	}
+	if op := app.Status.OperationState; op != nil {
+		info.OperationPhase = string(op.Phase)
+		info.OperationMessage = op.Message
+		info.RetryCount = op.RetryCount
+		if !op.StartedAt.IsZero() {
+			startedAt := op.StartedAt
+			info.LastSyncStartedAt = &startedAt
+		}
+		info.LastSyncFinishedAt = op.FinishedAt.DeepCopy()
+	}
+	for i := range app.Status.Resources {
+		res := &app.Status.Resources[i]
+		if res.Health == nil || res.Health.Status == "" || res.Health.Status == health.HealthStatusHealthy {
+			continue
+		}
+		if len(info.UnhealthyResources) == maxUnhealthyResources {
+			break
+		}
+		info.UnhealthyResources = append(info.UnhealthyResources, api.PatternApplicationResource{
+			Group:     res.Group,
+			Kind:      res.Kind,
+			Namespace: res.Namespace,
+			Name:      res.Name,
+			Health:    string(res.Health.Status),
+			Message:   res.Health.Message,
+		})
+	}
+	return info
+}
+
+// applicationCluster returns the managed cluster an application deploys to, or an empty string
+// when it deploys to the cluster argo runs in
+func applicationCluster(app *argoapi.Application) string {
+	if cluster := app.Annotations[ocmManagedClusterAnnotation]; cluster != "" {
+		return cluster
+	}
+	if name := app.Spec.Destination.Name; name != "" && name != argoInClusterName {
+		return name
+	}
+	if server := app.Spec.Destination.Server; server != "" && server != argoapi.KubernetesInternalAPIServerAddr {
+		return server
+	}
+	return ""
+}
+
+// applicationStatusChanged filters out the frequent application updates (refreshes,
+// reconciledAt...) that do not affect what we report
+func applicationStatusChanged(e event.TypedUpdateEvent[*argoapi.Application]) bool {
+	return e.ObjectOld.Labels[PatternApplicationLabel] != e.ObjectNew.Labels[PatternApplicationLabel] ||
+		!equality.Semantic.DeepEqual(applicationInfo(e.ObjectOld), applicationInfo(e.ObjectNew))
+}
+
+// enqueuePatternsForApplication maps an application to the patterns named by its label
+func (r *ApplicationStatusReconciler) enqueuePatternsForApplication(ctx context.Context, app *argoapi.Application) []reconcile.Request {
+	name := app.Labels[PatternApplicationLabel]
+	if name == "" {
+		return nil
+	}
+	var patterns api.PatternList
+	if err := r.List(ctx, &patterns); err != nil {
+		ctrl.Log.Error(err, "failed to list Patterns after Application change", "application", app.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range patterns.Items {
+		if patterns.Items[i].Name != name {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      patterns.Items[i].Name,
+				Namespace: patterns.Items[i].Namespace,
+			},
+		})
+	}
+	return requests
+}
+
+// startApplicationWatch dynamically adds the watch on argo applications once their CRD is
+// available. It returns whether the watch is running
+func (r *ApplicationStatusReconciler) startApplicationWatch() bool {
+	if r.watchStarted {
+		return true
+	}
+
+	if err := checkAPIVersion(r.fullClient, ArgoCDGroup, ArgoApplicationVersion); err != nil {
+		return false
+	}
+
+	err := r.ctrl.Watch(source.Kind(r.mgr.GetCache(), &argoapi.Application{},
+		handler.TypedEnqueueRequestsFromMapFunc(r.enqueuePatternsForApplication),
+		predicate.TypedFuncs[*argoapi.Application]{UpdateFunc: applicationStatusChanged},
+	))
+	if err != nil {
+		ctrl.Log.Error(err, "Failed to start Application watch, will retry on next reconcile")
+		return false
+	}
+
+	ctrl.Log.Info("Application watch started successfully")
+	r.watchStarted = true
+	return true
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ApplicationStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var err error
+	if r.fullClient, err = kubernetes.NewForConfig(mgr.GetConfig()); err != nil {
+		return err
+	}
+	if r.dynamicClient, err = dynamic.NewForConfig(mgr.GetConfig()); err != nil {
+		return err
+	}
+	if r.acmSearch, err = acmsearch.NewFromEnvironment(clusterProxyFunc); err != nil {
+		return err
+	}
+	r.lastSpokeSearch = map[types.NamespacedName]time.Time{}
+	r.mgr = mgr
+
+	r.ctrl, err = ctrl.NewControllerManagedBy(mgr).
+		Named("pattern-applications").
+		For(&api.Pattern{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Build(r)
+	return err
+}
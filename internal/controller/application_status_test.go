@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newTestApplication(name, namespace, pattern string, sync argoapi.SyncStatusCode, healthStatus health.HealthStatusCode) *argoapi.Application {
+	app := &argoapi.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+	if pattern != "" {
+		app.Labels = map[string]string{PatternApplicationLabel: pattern}
+	}
+	app.Status.Sync.Status = sync
+	app.Status.Health.Status = healthStatus
+	return app
+}
+
+var _ = Describe("Application status", func() {
+	Context("setApplicationsStatus", func() {
+		It("should clear the status when there are no applications", func() {
+			status := &api.PatternStatus{
+				Applications:       buildTestApplicationInfoArray(),
+				ApplicationsHealth: "Healthy",
+				ApplicationsSync:   "Synced",
+			}
+			setApplicationsStatus(status, nil)
+			Expect(status.Applications).To(BeNil())
+			Expect(status.ApplicationsHealth).To(BeEmpty())
+			Expect(status.ApplicationsSync).To(BeEmpty())
+		})
+
+		It("should sort the applications and report the worst health", func() {
+			status := &api.PatternStatus{}
+			setApplicationsStatus(status, []argoapi.Application{
+				*newTestApplication("b", "ns2", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy),
+				*newTestApplication("z", "ns1", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusDegraded),
+				*newTestApplication("a", "ns2", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusProgressing),
+			})
+			Expect(status.Applications).To(Equal([]api.PatternApplicationInfo{
+				{Name: "z", Namespace: "ns1", AppSyncStatus: "Synced", AppHealthStatus: "Degraded"},
+				{Name: "a", Namespace: "ns2", AppSyncStatus: "Synced", AppHealthStatus: "Progressing"},
+				{Name: "b", Namespace: "ns2", AppSyncStatus: "Synced", AppHealthStatus: "Healthy"},
+			}))
+			Expect(status.ApplicationsHealth).To(Equal("Degraded"))
+			Expect(status.ApplicationsSync).To(Equal("Synced"))
+		})
+
+		It("should report OutOfSync over Unknown", func() {
+			status := &api.PatternStatus{}
+			setApplicationsStatus(status, []argoapi.Application{
+				*newTestApplication("a", "ns", "test", "", ""),
+				*newTestApplication("b", "ns", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy),
+			})
+			Expect(status.ApplicationsSync).To(Equal("Unknown"))
+			Expect(status.ApplicationsHealth).To(Equal("Unknown"))
+
+			setApplicationsStatus(status, []argoapi.Application{
+				*newTestApplication("a", "ns", "test", "", health.HealthStatusHealthy),
+				*newTestApplication("b", "ns", "test", argoapi.SyncStatusCodeOutOfSync, health.HealthStatusHealthy),
+			})
+			Expect(status.ApplicationsSync).To(Equal("OutOfSync"))
+			Expect(status.ApplicationsHealth).To(Equal("Healthy"))
+		})
+	})
+
+	Context("applicationInfo", func() {
+		It("should report the last operation and the unhealthy resources", func() {
+			started := metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+			finished := metav1.NewTime(started.Add(time.Minute))
+			app := newTestApplication("vault", "test-hub", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusDegraded)
+			app.Status.Sync.Revision = "abc123"
+			app.Status.OperationState = &argoapi.OperationState{
+				Phase:      synccommon.OperationFailed,
+				Message:    "one or more objects failed to apply",
+				StartedAt:  started,
+				FinishedAt: &finished,
+				RetryCount: 2,
+			}
+			app.Status.Resources = []argoapi.ResourceStatus{
+				{Kind: "Service", Namespace: "vault", Name: "vault", Health: &argoapi.HealthStatus{Status: health.HealthStatusHealthy}},
+				{Kind: "ConfigMap", Namespace: "vault", Name: "config"},
+				{Group: "apps", Kind: "StatefulSet", Namespace: "vault", Name: "vault", Health: &argoapi.HealthStatus{
+					Status: health.HealthStatusDegraded, Message: "0 of 1 pods ready"}},
+			}
+
+			Expect(applicationInfo(app)).To(Equal(api.PatternApplicationInfo{
+				Name:               "vault",
+				Namespace:          "test-hub",
+				AppSyncStatus:      "Synced",
+				AppHealthStatus:    "Degraded",
+				Revision:           "abc123",
+				LastSyncStartedAt:  &started,
+				LastSyncFinishedAt: &finished,
+				OperationPhase:     "Failed",
+				OperationMessage:   "one or more objects failed to apply",
+				RetryCount:         2,
+				UnhealthyResources: []api.PatternApplicationResource{
+					{Group: "apps", Kind: "StatefulSet", Namespace: "vault", Name: "vault", Health: "Degraded", Message: "0 of 1 pods ready"},
+				},
+			}))
+		})
+
+		It("should report the revisions of multi-source applications", func() {
+			app := newTestApplication("hub", "vp-gitops", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy)
+			app.Status.Sync.Revisions = []string{"0.9.1", "abc123"}
+			Expect(applicationInfo(app).Revision).To(Equal("0.9.1,abc123"))
+		})
+
+		It("should cap the number of unhealthy resources", func() {
+			app := newTestApplication("hub", "vp-gitops", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusMissing)
+			for i := range maxUnhealthyResources + 5 {
+				app.Status.Resources = append(app.Status.Resources, argoapi.ResourceStatus{
+					Kind: "ConfigMap", Name: fmt.Sprintf("cm-%d", i), Health: &argoapi.HealthStatus{Status: health.HealthStatusMissing},
+				})
+			}
+			Expect(applicationInfo(app).UnhealthyResources).To(HaveLen(maxUnhealthyResources))
+		})
+
+		It("should report the cluster the application deploys to", func() {
+			app := newTestApplication("hub", "vp-gitops", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy)
+			app.Spec.Destination.Server = argoapi.KubernetesInternalAPIServerAddr
+			Expect(applicationCluster(app)).To(BeEmpty())
+
+			app.Spec.Destination = argoapi.ApplicationDestination{Name: argoInClusterName}
+			Expect(applicationCluster(app)).To(BeEmpty())
+
+			app.Spec.Destination = argoapi.ApplicationDestination{Name: "region-one"}
+			Expect(applicationCluster(app)).To(Equal("region-one"))
+
+			app.Spec.Destination = argoapi.ApplicationDestination{Server: "https://api.region-two.example.com:6443"}
+			Expect(applicationCluster(app)).To(Equal("https://api.region-two.example.com:6443"))
+
+			app.Annotations = map[string]string{ocmManagedClusterAnnotation: "region-three"}
+			Expect(applicationCluster(app)).To(Equal("region-three"))
+		})
+	})
+
+	Context("applicationStatusChanged", func() {
+		It("should only fire when the reported state or the label changes", func() {
+			oldApp := newTestApplication("a", "ns", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy)
+			newApp := oldApp.DeepCopy()
+			newApp.Status.ReconciledAt = &metav1.Time{}
+			Expect(applicationStatusChanged(event.TypedUpdateEvent[*argoapi.Application]{ObjectOld: oldApp, ObjectNew: newApp})).To(BeFalse())
+
+			newApp.Status.Health.Status = health.HealthStatusDegraded
+			Expect(applicationStatusChanged(event.TypedUpdateEvent[*argoapi.Application]{ObjectOld: oldApp, ObjectNew: newApp})).To(BeTrue())
+
+			newApp = oldApp.DeepCopy()
+			newApp.Status.OperationState = &argoapi.OperationState{Phase: synccommon.OperationRunning}
+			Expect(applicationStatusChanged(event.TypedUpdateEvent[*argoapi.Application]{ObjectOld: oldApp, ObjectNew: newApp})).To(BeTrue())
+
+			newApp = oldApp.DeepCopy()
+			newApp.Labels[PatternApplicationLabel] = "other"
+			Expect(applicationStatusChanged(event.TypedUpdateEvent[*argoapi.Application]{ObjectOld: oldApp, ObjectNew: newApp})).To(BeTrue())
+		})
+	})
+
+	Context("Reconcile", func() {
+		var reconciler *ApplicationStatusReconciler
+		var pattern *api.Pattern
+		patternName := types.NamespacedName{Name: "test", Namespace: "pattern-ns"}
+
+		BeforeEach(func() {
+			s := runtime.NewScheme()
+			Expect(api.AddToScheme(s)).To(Succeed())
+			Expect(argoapi.AddToScheme(s)).To(Succeed())
+			pattern = &api.Pattern{
+				ObjectMeta: metav1.ObjectMeta{Name: patternName.Name, Namespace: patternName.Namespace},
+				Status:     api.PatternStatus{LastStep: "validation"},
+			}
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(s).
+				WithStatusSubresource(&api.Pattern{}).
+				WithObjects(pattern,
+					newTestApplication("hub", "vp-gitops", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy),
+					newTestApplication("vault", "test-hub", "test", argoapi.SyncStatusCodeOutOfSync, health.HealthStatusProgressing),
+					newTestApplication("other", "test-hub", "another", argoapi.SyncStatusCodeSynced, health.HealthStatusDegraded),
+					newTestApplication("unlabelled", "test-hub", "", argoapi.SyncStatusCodeSynced, health.HealthStatusDegraded),
+				).
+				Build()
+			// The watch itself needs a running manager
+			reconciler = &ApplicationStatusReconciler{
+				Client:          fakeClient,
+				Scheme:          s,
+				watchStarted:    true,
+				dynamicClient:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+				lastSpokeSearch: map[types.NamespacedName]time.Time{},
+			}
+		})
+
+		It("should record the applications of the pattern", func() {
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: patternName})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(reconciler.Get(context.Background(), patternName, pattern)).To(Succeed())
+			Expect(pattern.Status.Applications).To(Equal([]api.PatternApplicationInfo{
+				{Name: "vault", Namespace: "test-hub", AppSyncStatus: "OutOfSync", AppHealthStatus: "Progressing"},
+				{Name: "hub", Namespace: "vp-gitops", AppSyncStatus: "Synced", AppHealthStatus: "Healthy"},
+			}))
+			Expect(pattern.Status.ApplicationsHealth).To(Equal("Progressing"))
+			Expect(pattern.Status.ApplicationsSync).To(Equal("OutOfSync"))
+			// The rest of the status belongs to the Pattern reconcile loop
+			Expect(pattern.Status.LastStep).To(Equal("validation"))
+		})
+
+		It("should ignore a missing pattern", func() {
+			_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "gone", Namespace: "pattern-ns"}})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should map applications to the pattern named by their label", func() {
+			requests := reconciler.enqueuePatternsForApplication(context.Background(),
+				newTestApplication("vault", "test-hub", "test", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy))
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].NamespacedName).To(Equal(patternName))
+
+			Expect(reconciler.enqueuePatternsForApplication(context.Background(),
+				newTestApplication("other", "test-hub", "another", argoapi.SyncStatusCodeSynced, health.HealthStatusHealthy))).To(BeEmpty())
+		})
+	})
+})
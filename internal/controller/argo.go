@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -232,7 +233,7 @@ return health_status`,
 	initContainers := []v1.Container{
 		{
 			Name:  "fetch-ca",
-			Image: "registry.redhat.io/ubi9/ubi-minimal:latest",
+			Image: getMirrors().rewriteImage(ArgoInitContainerImage),
 			VolumeMounts: []v1.VolumeMount{
 				{
 					Name:      "kube-root-ca",
@@ -564,6 +565,7 @@ func getArgoCD(client dynamic.Interface, name, namespace string) (*argooperator.
 }
 
 func newApplicationParameters(p *api.Pattern) []argoapi.HelmParameter {
+	patternRepoURL, patternRevision := getPatternRepo(p)
 	parameters := []argoapi.HelmParameter{
 		{
 			Name:  "global.pattern",
@@ -575,7 +577,7 @@ func newApplicationParameters(p *api.Pattern) []argoapi.HelmParameter {
 		},
 		{
 			Name:  "global.repoURL",
-			Value: p.Spec.GitConfig.TargetRepo,
+			Value: patternRepoURL,
 		},
 		{
 			Name:  "global.originURL",
@@ -583,7 +585,7 @@ func newApplicationParameters(p *api.Pattern) []argoapi.HelmParameter {
 		},
 		{
 			Name:  "global.targetRevision",
-			Value: p.Spec.GitConfig.TargetRevision,
+			Value: patternRevision,
 		},
 		{
 			Name:  "global.hubClusterDomain",
@@ -619,7 +621,7 @@ func newApplicationParameters(p *api.Pattern) []argoapi.HelmParameter {
 		},
 		{
 			Name:  "global.multiSourceRepoUrl",
-			Value: p.Spec.MultiSourceConfig.HelmRepoUrl,
+			Value: getHelmRepoURL(p),
 		},
 
 		{
@@ -777,6 +779,10 @@ func getSharedValueFiles(p *api.Pattern, prefix string) ([]string, error) {
 
 func commonSyncPolicy(p *api.Pattern) *argoapi.SyncPolicy {
 	var syncPolicy *argoapi.SyncPolicy
+	config := p.Spec.GitOpsConfig
+	if config == nil {
+		config = &api.GitOpsConfig{}
+	}
 	if !p.DeletionTimestamp.IsZero() {
 		syncPolicy = &argoapi.SyncPolicy{
 			// Automated will keep an application synced to the target revision
@@ -786,24 +792,98 @@ func commonSyncPolicy(p *api.Pattern) *argoapi.SyncPolicy {
 			// Options allow you to specify whole app sync-SyncOptions
 			SyncOptions: []string{"Prune=true"},
 		}
-	} else if !p.Spec.GitOpsConfig.ManualSync {
+	} else if !config.ManualSync {
 		// SyncPolicy controls when and how a sync will be performed
 		syncPolicy = &argoapi.SyncPolicy{
 			// Automated will keep an application synced to the target revision
 			Automated: &argoapi.SyncPolicyAutomated{
-				SelfHeal: true,
+				Prune:      config.Prune,
+				SelfHeal:   config.SelfHeal == nil || *config.SelfHeal,
+				AllowEmpty: config.AllowEmpty,
 			},
 			// Options allow you to specify whole app sync-options
-			SyncOptions: []string{},
+			SyncOptions: commonSyncOptions(config),
 			// Retry controls failed sync retry behavior
-			Retry: &argoapi.RetryStrategy{
-				Limit: 20,
-			},
+			Retry: commonRetryStrategy(config),
+		}
+	} else if options := commonSyncOptions(config); len(options) > 0 || config.Retry != nil {
+		// Manual syncs honor the sync options and the retries too
+		syncPolicy = &argoapi.SyncPolicy{
+			SyncOptions: options,
+			Retry:       commonRetryStrategy(config),
 		}
 	}
 	return syncPolicy
 }
 
+// commonSyncOptions returns the argo sync options of the pattern applications, always in the same order
+func commonSyncOptions(config *api.GitOpsConfig) argoapi.SyncOptions {
+	options := argoapi.SyncOptions{}
+	if config.SyncOptions.ServerSideApply {
+		options = append(options, "ServerSideApply=true")
+	}
+	if config.SyncOptions.CreateNamespace {
+		options = append(options, "CreateNamespace=true")
+	}
+	// The syncs must leave the child applications in the project of the maintenance windows
+	if config.SyncOptions.RespectIgnoreDifferences || len(config.MaintenanceWindows) > 0 {
+		options = append(options, "RespectIgnoreDifferences=true")
+	}
+	return options
+}
+
+func commonRetryStrategy(config *api.GitOpsConfig) *argoapi.RetryStrategy {
+	retry := &argoapi.RetryStrategy{
+		Limit: 20,
+	}
+	if config.Retry == nil {
+		return retry
+	}
+	if config.Retry.Limit != nil {
+		retry.Limit = *config.Retry.Limit
+	}
+	retry.Refresh = config.Retry.Refresh
+	if backoff := config.Retry.Backoff; backoff != nil {
+		retry.Backoff = &argoapi.Backoff{
+			Duration:    backoff.Duration,
+			MaxDuration: backoff.MaxDuration,
+		}
+		if backoff.Factor != nil {
+			factor := *backoff.Factor
+			retry.Backoff.Factor = &factor
+		}
+	}
+	return retry
+}
+
+// commonIgnoreDifferences returns the ignoreDifferences rules of the pattern applications
+func commonIgnoreDifferences(p *api.Pattern) argoapi.IgnoreDifferences {
+	var ignoreDifferences argoapi.IgnoreDifferences
+	if applicationProject(p) != defaultProject {
+		// The operator moves the child applications to the project of the maintenance windows
+		ignoreDifferences = append(ignoreDifferences, argoapi.ResourceIgnoreDifferences{
+			Group:        "argoproj.io",
+			Kind:         "Application",
+			JSONPointers: []string{"/spec/project"},
+		})
+	}
+	if p.Spec.GitOpsConfig == nil {
+		return ignoreDifferences
+	}
+	for _, rule := range p.Spec.GitOpsConfig.IgnoreDifferences {
+		ignoreDifferences = append(ignoreDifferences, argoapi.ResourceIgnoreDifferences{
+			Group:                 rule.Group,
+			Kind:                  rule.Kind,
+			Name:                  rule.Name,
+			Namespace:             rule.Namespace,
+			JSONPointers:          rule.JSONPointers,
+			JQPathExpressions:     rule.JQPathExpressions,
+			ManagedFieldsManagers: rule.ManagedFieldsManagers,
+		})
+	}
+	return ignoreDifferences
+}
+
 func commonApplicationSpec(p *api.Pattern, sources []argoapi.ApplicationSource) *argoapi.ApplicationSpec {
 	spec := &argoapi.ApplicationSpec{
 		Destination: argoapi.ApplicationDestination{
@@ -812,10 +892,10 @@ func commonApplicationSpec(p *api.Pattern, sources []argoapi.ApplicationSource)
 		},
 		// Project is a reference to the project this application belongs to.
 		// The empty string means that application belongs to the 'default' project.
-		Project: "default",
+		Project: applicationProject(p),
 
 		// IgnoreDifferences is a list of resources and their fields which should be ignored during comparison
-		// IgnoreDifferences []ResourceIgnoreDifferences `json:"ignoreDifferences,omitempty" protobuf:"bytes,5,name=ignoreDifferences"`
+		IgnoreDifferences: commonIgnoreDifferences(p),
 		// Info contains a list of information (URLs, email addresses, and plain text) that relates to the application
 		// Info []Info `json:"info,omitempty" protobuf:"bytes,6,name=info"`
 		// RevisionHistoryLimit limits the number of items kept in the
@@ -872,7 +952,7 @@ func commonApplicationSourceHelm(p *api.Pattern, prefix string) *argoapi.Applica
 
 func newArgoOperatorApplication(p *api.Pattern, spec *argoapi.ApplicationSpec) *argoapi.Application {
 	labels := make(map[string]string)
-	labels["validatedpatterns.io/pattern"] = p.Name
+	labels[PatternApplicationLabel] = p.Name
 	app := argoapi.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      applicationName(p),
@@ -891,9 +971,9 @@ func newSourceApplication(p *api.Pattern) *argoapi.Application {
 	// root := filepath.Join(os.TempDir(), r.ReplaceAllString(NormalizeGitURL(rawRepoURL), "_"))
 	// Source is a reference to the location of the application's manifests or chart
 	source := argoapi.ApplicationSource{
-		RepoURL:        p.Spec.GitConfig.TargetRepo,
+		RepoURL:        getTargetRepo(p),
 		Path:           "common/clustergroup",
-		TargetRevision: p.Spec.GitConfig.TargetRevision,
+		TargetRevision: getTargetRevision(p),
 		Helm:           commonApplicationSourceHelm(p, ""),
 	}
 	spec := commonApplicationSpec(p, []argoapi.ApplicationSource{source})
@@ -906,9 +986,10 @@ func newMultiSourceApplication(p *api.Pattern) *argoapi.Application {
 	sources := []argoapi.ApplicationSource{}
 	var baseSource *argoapi.ApplicationSource
 
+	patternRepoURL, patternRevision := getPatternRepo(p)
 	valuesSource := &argoapi.ApplicationSource{
-		RepoURL:        p.Spec.GitConfig.TargetRepo,
-		TargetRevision: p.Spec.GitConfig.TargetRevision,
+		RepoURL:        patternRepoURL,
+		TargetRevision: patternRevision,
 		Ref:            "patternref",
 	}
 	sources = append(sources, *valuesSource)
@@ -918,10 +999,20 @@ func newMultiSourceApplication(p *api.Pattern) *argoapi.Application {
 	if p.Spec.MultiSourceConfig.ClusterGroupGitRepoUrl == "" {
 		// If the user set the clustergroupchart version use that
 
+		repoURL := getHelmRepoURL(p)
+		chartVersion := getClusterGroupChartVersion(p)
+		// Argo wants OCI helm repositories without the scheme and cannot resolve version
+		// ranges against OCI tags, so we use the version resolved by the operator
+		if isOCIURL(repoURL) {
+			repoURL = strings.TrimPrefix(repoURL, OCIScheme)
+			if p.Status.ClusterGroupChartVersion != "" {
+				chartVersion = p.Status.ClusterGroupChartVersion
+			}
+		}
 		baseSource = &argoapi.ApplicationSource{
-			RepoURL:        p.Spec.MultiSourceConfig.HelmRepoUrl,
-			Chart:          "clustergroup",
-			TargetRevision: getClusterGroupChartVersion(p),
+			RepoURL:        repoURL,
+			Chart:          ClusterGroupChartName,
+			TargetRevision: chartVersion,
 			Helm:           commonApplicationSourceHelm(p, "$patternref"),
 		}
 	} else {
@@ -953,6 +1044,42 @@ func getClusterGroupChartVersion(p *api.Pattern) string {
 	return clusterGroupChartVersion
 }
 
+// getPatternRepo returns the url and revision the pattern content is deployed from, which is
+// either the git TargetRepo or the pattern OCI artifact
+func getPatternRepo(p *api.Pattern) (repoURL, revision string) {
+	if p.Spec.MultiSourceConfig.PatternOCIUrl != "" {
+		return getMirrors().rewriteURL(p.Spec.MultiSourceConfig.PatternOCIUrl), getPatternOCIVersion(p)
+	}
+	return getTargetRepo(p), getTargetRevision(p)
+}
+
+// getTargetRepo returns the git repository the pattern is deployed from: the in-cluster copy
+// of the upstream repository once it has been imported, TargetRepo otherwise
+func getTargetRepo(p *api.Pattern) string {
+	if p.Status.EffectiveTargetRepo != "" {
+		return p.Status.EffectiveTargetRepo
+	}
+	return p.Spec.GitConfig.TargetRepo
+}
+
+// getHelmRepoURL returns the helm repository of the clustergroup chart, pointing to its
+// mirror when running disconnected
+func getHelmRepoURL(p *api.Pattern) string {
+	return getMirrors().rewriteURL(p.Spec.MultiSourceConfig.HelmRepoUrl)
+}
+
+// getPatternOCIVersion returns the resolved tag of the pattern OCI artifact, falling back to
+// the requested version when it has not been resolved yet
+func getPatternOCIVersion(p *api.Pattern) string {
+	if p.Status.PatternOCIVersion != "" {
+		return p.Status.PatternOCIVersion
+	}
+	if p.Spec.MultiSourceConfig.PatternOCIVersion != "" {
+		return p.Spec.MultiSourceConfig.PatternOCIVersion
+	}
+	return OCIDefaultVersion
+}
+
 func newArgoApplication(p *api.Pattern) *argoapi.Application {
 	// -- ArgoCD Application
 	var targetApp *argoapi.Application
@@ -982,6 +1109,28 @@ func newArgoGiteaApplication(p *api.Pattern, patternsOperatorConfig PatternsOper
 			Value: consoleHref,
 		},
 	}
+	// Gitea clones the upstream repository itself during the migration, so it needs
+	// to go through the cluster-wide proxy as well, unless noProxy says otherwise
+	proxyConfig := getClusterProxy()
+	if hosts := proxyConfig.giteaProxyHosts(p.Spec.GitConfig.OriginRepo, p.Spec.GitConfig.TargetRepo); hosts != "" {
+		proxyURL := proxyConfig.HTTPSProxy
+		if proxyURL == "" {
+			proxyURL = proxyConfig.HTTPProxy
+		}
+		parameters = append(parameters,
+			argoapi.HelmParameter{
+				Name:  "gitea.config.proxy.PROXY_ENABLED",
+				Value: "true",
+			},
+			argoapi.HelmParameter{
+				Name:  "gitea.config.proxy.PROXY_URL",
+				Value: proxyURL,
+			},
+			argoapi.HelmParameter{
+				Name:  "gitea.config.proxy.PROXY_HOSTS",
+				Value: hosts,
+			})
+	}
 	spec := &argoapi.ApplicationSpec{
 		Destination: argoapi.ApplicationDestination{
 			Name:      "in-cluster",
@@ -989,7 +1138,7 @@ func newArgoGiteaApplication(p *api.Pattern, patternsOperatorConfig PatternsOper
 		},
 		Project: "default",
 		Source: &argoapi.ApplicationSource{
-			RepoURL:        patternsOperatorConfig.getStringValue("gitea.helmRepoUrl"),
+			RepoURL:        getMirrors().rewriteURL(patternsOperatorConfig.getStringValue("gitea.helmRepoUrl")),
 			TargetRevision: patternsOperatorConfig.getStringValue("gitea.chartVersion"),
 			Chart:          patternsOperatorConfig.getStringValue("gitea.chartName"),
 			Helm: &argoapi.ApplicationSourceHelm{
@@ -999,7 +1148,7 @@ func newArgoGiteaApplication(p *api.Pattern, patternsOperatorConfig PatternsOper
 		SyncPolicy: commonSyncPolicy(p),
 	}
 	labels := make(map[string]string)
-	labels["validatedpatterns.io/pattern"] = p.Name
+	labels[PatternApplicationLabel] = p.Name
 	app := argoapi.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      GiteaApplicationName,
@@ -1082,6 +1231,10 @@ func compareApplication(goal, actual *argoapi.Application) bool {
 	if (goal == nil) != (actual == nil) {
 		return false
 	}
+	if goal.Spec.Project != actual.Spec.Project {
+		log.Printf("Project changed %s -> %s\n", actual.Spec.Project, goal.Spec.Project)
+		return false
+	}
 	if !compareSource(goal.Spec.Source, actual.Spec.Source) {
 		return false
 	}
@@ -1091,6 +1244,9 @@ func compareApplication(goal, actual *argoapi.Application) bool {
 	if !compareSyncPolicy(goal.Spec.SyncPolicy, actual.Spec.SyncPolicy) {
 		return false
 	}
+	if !compareIgnoreDifferences(goal.Spec.IgnoreDifferences, actual.Spec.IgnoreDifferences) {
+		return false
+	}
 	return true
 }
 
@@ -1124,6 +1280,40 @@ func compareRetryStrategy(goal, actual *argoapi.RetryStrategy) bool {
 		log.Printf("RetryStrategy Limit changed %d -> %d\n", actual.Limit, goal.Limit)
 		return false
 	}
+	if goal.Refresh != actual.Refresh {
+		log.Printf("RetryStrategy Refresh changed %t -> %t\n", actual.Refresh, goal.Refresh)
+		return false
+	}
+	return compareBackoff(goal.Backoff, actual.Backoff)
+}
+
+func compareBackoff(goal, actual *argoapi.Backoff) bool {
+	if goal == nil && actual == nil {
+		return true
+	}
+	if (goal == nil) != (actual == nil) {
+		log.Printf("RetryStrategy Backoff changed %v -> %v\n", actual, goal)
+		return false
+	}
+	if goal.Duration != actual.Duration || goal.MaxDuration != actual.MaxDuration {
+		log.Printf("RetryStrategy Backoff durations changed %s/%s -> %s/%s\n", actual.Duration, actual.MaxDuration, goal.Duration, goal.MaxDuration)
+		return false
+	}
+	if (goal.Factor == nil) != (actual.Factor == nil) || (goal.Factor != nil && *goal.Factor != *actual.Factor) {
+		log.Printf("RetryStrategy Backoff factor changed\n")
+		return false
+	}
+	return true
+}
+
+func compareIgnoreDifferences(goal, actual argoapi.IgnoreDifferences) bool {
+	if len(goal) == 0 && len(actual) == 0 {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(goal, actual) {
+		log.Printf("IgnoreDifferences changed %v -> %v\n", actual, goal)
+		return false
+	}
 	return true
 }
 
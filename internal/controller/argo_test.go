@@ -195,6 +195,33 @@ var _ = Describe("Argo Pattern", func() {
 				Expect(newMultiSourceApplication(pattern)).To(Equal(multiSourceArgoApp))
 			})
 		})
+		Context("multiSource with an OCI helm repository", func() {
+			It("Strips the scheme and uses the resolved chart version", func() {
+				pattern.Spec.MultiSourceConfig.HelmRepoUrl = "oci://quay.io/validatedpatterns"
+				app := newMultiSourceApplication(pattern)
+				Expect(app.Spec.Sources[1].RepoURL).To(Equal("quay.io/validatedpatterns"))
+				Expect(app.Spec.Sources[1].TargetRevision).To(Equal("0.0.*"))
+
+				pattern.Status.ClusterGroupChartVersion = "0.0.7"
+				app = newMultiSourceApplication(pattern)
+				Expect(app.Spec.Sources[1].TargetRevision).To(Equal("0.0.7"))
+			})
+		})
+		Context("multiSource with a pattern OCI artifact", func() {
+			It("Sources the values from the OCI artifact", func() {
+				pattern.Spec.MultiSourceConfig.PatternOCIUrl = "oci://quay.io/validatedpatterns/multicloud-gitops"
+				app := newMultiSourceApplication(pattern)
+				Expect(app.Spec.Sources[0].RepoURL).To(Equal("oci://quay.io/validatedpatterns/multicloud-gitops"))
+				Expect(app.Spec.Sources[0].TargetRevision).To(Equal(OCIDefaultVersion))
+				Expect(app.Spec.Sources[1].Helm.Parameters).To(ContainElement(
+					argoapi.HelmParameter{Name: "global.repoURL", Value: "oci://quay.io/validatedpatterns/multicloud-gitops"}))
+
+				pattern.Spec.MultiSourceConfig.PatternOCIVersion = "1.x"
+				Expect(newMultiSourceApplication(pattern).Spec.Sources[0].TargetRevision).To(Equal("1.x"))
+				pattern.Status.PatternOCIVersion = "1.2.0"
+				Expect(newMultiSourceApplication(pattern).Spec.Sources[0].TargetRevision).To(Equal("1.2.0"))
+			})
+		})
 	})
 
 	Describe("Testing newApplicationValueFiles function", func() {
@@ -1175,6 +1202,76 @@ var _ = Describe("CompareApplication", func() {
 			Expect(compareApplication(app1, app2)).To(BeFalse())
 		})
 	})
+
+	Context("when applications have different ignoreDifferences", func() {
+		It("should return false", func() {
+			app1 := newArgoApplication(pattern)
+			app2 := app1.DeepCopy()
+			app2.Spec.IgnoreDifferences = argoapi.IgnoreDifferences{{Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}}}
+			Expect(compareApplication(app1, app2)).To(BeFalse())
+			app2.Spec.IgnoreDifferences = argoapi.IgnoreDifferences{}
+			Expect(compareApplication(app1, app2)).To(BeTrue())
+		})
+	})
+
+	Context("when the applications set the retry and ignoreDifferences options", func() {
+		var app *argoapi.Application
+
+		BeforeEach(func() {
+			factor := int64(2)
+			pattern.Spec.GitOpsConfig.Retry = &api.GitOpsRetry{
+				Refresh: true,
+				Backoff: &api.GitOpsBackoff{Duration: "5s", Factor: &factor, MaxDuration: "3m"},
+			}
+			pattern.Spec.GitOpsConfig.IgnoreDifferences = []api.GitOpsIgnoreDifference{
+				{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+			}
+			app = newArgoApplication(pattern)
+		})
+
+		It("should return true when they are identical", func() {
+			Expect(app.Spec.SyncPolicy.Retry.Refresh).To(BeTrue())
+			Expect(app.Spec.SyncPolicy.Retry.Backoff).ToNot(BeNil())
+			Expect(app.Spec.IgnoreDifferences).To(HaveLen(1))
+			Expect(compareApplication(app, app.DeepCopy())).To(BeTrue())
+			Expect(compareApplication(app, newArgoApplication(pattern))).To(BeTrue())
+		})
+
+		It("should return false when Refresh changed", func() {
+			actual := app.DeepCopy()
+			actual.Spec.SyncPolicy.Retry.Refresh = false
+			Expect(compareApplication(app, actual)).To(BeFalse())
+		})
+
+		It("should return false when the backoff changed", func() {
+			actual := app.DeepCopy()
+			actual.Spec.SyncPolicy.Retry.Backoff.Duration = "10s"
+			Expect(compareApplication(app, actual)).To(BeFalse())
+
+			actual = app.DeepCopy()
+			factor := int64(3)
+			actual.Spec.SyncPolicy.Retry.Backoff.Factor = &factor
+			Expect(compareApplication(app, actual)).To(BeFalse())
+
+			actual = app.DeepCopy()
+			actual.Spec.SyncPolicy.Retry.Backoff.MaxDuration = "5m"
+			Expect(compareApplication(app, actual)).To(BeFalse())
+
+			actual = app.DeepCopy()
+			actual.Spec.SyncPolicy.Retry.Backoff = nil
+			Expect(compareApplication(app, actual)).To(BeFalse())
+		})
+
+		It("should return false when the ignoreDifferences changed", func() {
+			actual := app.DeepCopy()
+			actual.Spec.IgnoreDifferences[0].JSONPointers = []string{"/spec/template"}
+			Expect(compareApplication(app, actual)).To(BeFalse())
+
+			actual = app.DeepCopy()
+			actual.Spec.IgnoreDifferences = nil
+			Expect(compareApplication(app, actual)).To(BeFalse())
+		})
+	})
 })
 
 var _ = Describe("GetApplication", func() {
@@ -2128,6 +2225,32 @@ var _ = Describe("newArgoGiteaApplication", func() {
 		Expect(paramMap).To(HaveKey("gitea.console.href"))
 		Expect(paramMap["gitea.console.href"]).To(ContainSubstring("apps.example.com"))
 		Expect(paramMap).To(HaveKey("gitea.config.server.ROOT_URL"))
+		Expect(paramMap).ToNot(HaveKey("gitea.config.proxy.PROXY_ENABLED"))
+	})
+
+	It("should configure the cluster-wide proxy when one is set", func() {
+		setClusterProxy(clusterProxy{HTTPSProxy: "http://proxy:3128", NoProxy: ".internal.example.com"})
+		defer setClusterProxy(clusterProxy{})
+		pattern.Spec.GitConfig.OriginRepo = "https://git.internal.example.com/test/repo"
+		app = newArgoGiteaApplication(pattern, patternsOperatorConfig)
+
+		paramMap := make(map[string]string)
+		for _, p := range app.Spec.Source.Helm.Parameters {
+			paramMap[p.Name] = p.Value
+		}
+		Expect(paramMap).To(HaveKeyWithValue("gitea.config.proxy.PROXY_ENABLED", "true"))
+		Expect(paramMap).To(HaveKeyWithValue("gitea.config.proxy.PROXY_URL", "http://proxy:3128"))
+		Expect(paramMap).To(HaveKeyWithValue("gitea.config.proxy.PROXY_HOSTS", "github.com"))
+	})
+
+	It("should not proxy the repositories noProxy reaches directly", func() {
+		setClusterProxy(clusterProxy{HTTPSProxy: "http://proxy:3128", NoProxy: "github.com"})
+		defer setClusterProxy(clusterProxy{})
+		app = newArgoGiteaApplication(pattern, patternsOperatorConfig)
+
+		for _, p := range app.Spec.Source.Helm.Parameters {
+			Expect(p.Name).ToNot(HavePrefix("gitea.config.proxy."))
+		}
 	})
 
 	It("should have the foreground propagation finalizer", func() {
@@ -2268,6 +2391,69 @@ var _ = Describe("commonSyncPolicy", func() {
 		policy := commonSyncPolicy(pattern)
 		Expect(policy).To(BeNil())
 	})
+
+	It("should honor the tuned sync policy", func() {
+		selfHeal := false
+		limit := int64(5)
+		factor := int64(3)
+		pattern := &api.Pattern{
+			Spec: api.PatternSpec{
+				GitOpsConfig: &api.GitOpsConfig{
+					Prune:       true,
+					SelfHeal:    &selfHeal,
+					AllowEmpty:  true,
+					SyncOptions: api.GitOpsSyncOptions{ServerSideApply: true, RespectIgnoreDifferences: true},
+					Retry: &api.GitOpsRetry{
+						Limit:   &limit,
+						Refresh: true,
+						Backoff: &api.GitOpsBackoff{Duration: "10s", Factor: &factor, MaxDuration: "5m"},
+					},
+				},
+			},
+		}
+		policy := commonSyncPolicy(pattern)
+		Expect(policy.Automated).To(Equal(&argoapi.SyncPolicyAutomated{Prune: true, SelfHeal: false, AllowEmpty: true}))
+		Expect(policy.SyncOptions).To(Equal(argoapi.SyncOptions{"ServerSideApply=true", "RespectIgnoreDifferences=true"}))
+		Expect(policy.Retry).To(Equal(&argoapi.RetryStrategy{
+			Limit:   5,
+			Refresh: true,
+			Backoff: &argoapi.Backoff{Duration: "10s", Factor: &factor, MaxDuration: "5m"},
+		}))
+		Expect(compareSyncPolicy(policy, commonSyncPolicy(pattern))).To(BeTrue())
+
+		// The tuned values are not reverted to the defaults
+		Expect(compareSyncPolicy(commonSyncPolicy(pattern), commonSyncPolicy(&api.Pattern{}))).To(BeFalse())
+		changed := commonSyncPolicy(pattern)
+		changed.Retry.Backoff.MaxDuration = "3m"
+		Expect(compareSyncPolicy(policy, changed)).To(BeFalse())
+	})
+
+	It("should keep the sync options and the retries of manual syncs", func() {
+		pattern := &api.Pattern{
+			Spec: api.PatternSpec{
+				GitOpsConfig: &api.GitOpsConfig{ManualSync: true, SyncOptions: api.GitOpsSyncOptions{CreateNamespace: true}},
+			},
+		}
+		policy := commonSyncPolicy(pattern)
+		Expect(policy.Automated).To(BeNil())
+		Expect(policy.SyncOptions).To(Equal(argoapi.SyncOptions{"CreateNamespace=true"}))
+		Expect(policy.Retry.Limit).To(Equal(int64(20)))
+	})
+
+	It("should set the ignoreDifferences rules on the applications", func() {
+		pattern := &api.Pattern{
+			Spec: api.PatternSpec{
+				GitOpsConfig: &api.GitOpsConfig{IgnoreDifferences: []api.GitOpsIgnoreDifference{
+					{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+				}},
+			},
+		}
+		spec := commonApplicationSpec(pattern, []argoapi.ApplicationSource{{}})
+		Expect(spec.IgnoreDifferences).To(Equal(argoapi.IgnoreDifferences{
+			{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+		}))
+		Expect(commonApplicationSpec(&api.Pattern{}, []argoapi.ApplicationSource{{}}).IgnoreDifferences).To(BeNil())
+	})
 })
 
 var _ = Describe("applicationName", func() {
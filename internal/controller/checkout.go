@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	nethttp "net/http"
 	"os"
@@ -28,18 +29,23 @@ import (
 	"path/filepath"
 
 	stdssh "golang.org/x/crypto/ssh"
+	"golang.org/x/oauth2/google"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/client"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
 
 	argogit "github.com/argoproj/argo-cd/v3/util/git"
+	"github.com/argoproj/argo-cd/v3/util/workloadidentity"
 )
 
 type GitAuthenticationBackend uint
@@ -49,9 +55,15 @@ const (
 	GitAuthPassword  GitAuthenticationBackend = 1
 	GitAuthSsh       GitAuthenticationBackend = 2
 	GitAuthGitHubApp GitAuthenticationBackend = 3
+	// The following follow the argo repository secret fields of the same name
+	GitAuthBearerToken           GitAuthenticationBackend = 4
+	GitAuthTLSClientCert         GitAuthenticationBackend = 5
+	GitAuthGoogleCloud           GitAuthenticationBackend = 6
+	GitAuthAzureWorkloadIdentity GitAuthenticationBackend = 7
 )
 
 const ContextTimeout = 15 * time.Second
+const GoogleCloudSourceScope = "https://www.googleapis.com/auth/cloud-platform"
 const GitCustomCAFile = "/tmp/vp-git-cas.pem"
 const GitHEAD = "HEAD"
 const VPTmpFolder = "vp"
@@ -271,24 +283,14 @@ func getFetchOptions(fullClient kubernetes.Interface, url string, secret map[str
 		Force:           true,
 		InsecureSkipTLS: true,
 		Tags:            git.AllTags,
+		ProxyOptions:    getClusterProxy().gitProxyOptions(url),
 	}
-	switch authType := detectGitAuthType(secret); authType {
-	case GitAuthPassword:
-		foptions.Auth = getHttpAuth(secret)
-	case GitAuthSsh:
-		publicKey, err := getSshPublicKey(url, secret)
-		if err != nil {
-			return nil, err
-		}
-		foptions.Auth = publicKey
-	case GitAuthGitHubApp:
-		gitHubAppAuth, err := getGitHubAppAuth(fullClient, secret)
-		if err != nil {
-			return nil, err
-		}
-
-		foptions.Auth = gitHubAppAuth
+	auth, err := getGitAuth(fullClient, url, secret)
+	if err != nil {
+		return nil, err
 	}
+	foptions.Auth = auth
+	foptions.ClientCert, foptions.ClientKey = getTLSClientCert(secret)
 
 	return foptions, nil
 }
@@ -302,27 +304,101 @@ func getCloneOptions(fullClient kubernetes.Interface, url string, secret map[str
 		Depth:        0,
 		SingleBranch: false,
 		Tags:         git.AllTags,
+		ProxyOptions: getClusterProxy().gitProxyOptions(url),
+	}
+	auth, err := getGitAuth(fullClient, url, secret)
+	if err != nil {
+		return nil, err
 	}
+	options.Auth = auth
+	options.ClientCert, options.ClientKey = getTLSClientCert(secret)
+
+	return options, nil
+}
 
+// getRemoteCommit returns the commit a branch, a tag (or HEAD) of a remote repository points to,
+// without cloning it. A commit sha is returned as is
+func getRemoteCommit(fullClient kubernetes.Interface, url, revision string, secret map[string][]byte) (string, error) {
+	if plumbing.IsHash(revision) {
+		return revision, nil
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	auth, err := getGitAuth(fullClient, url, secret)
+	if err != nil {
+		return "", err
+	}
+	refs, err := remote.List(&git.ListOptions{
+		Auth:            auth,
+		InsecureSkipTLS: true,
+		ProxyOptions:    getClusterProxy().gitProxyOptions(url),
+		// Annotated tags point to a tag object, their commit is advertised as the peeled reference
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return "", err
+	}
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, ref := range refs {
+		byName[ref.Name()] = ref
+	}
+
+	if revision == "" || revision == GitHEAD {
+		// HEAD is usually advertised as a symbolic reference, so follow it once
+		head, found := byName[plumbing.HEAD]
+		if found && head.Type() == plumbing.SymbolicReference {
+			head, found = byName[head.Target()]
+		}
+		if !found {
+			return "", fmt.Errorf("reference %s not found in %s", plumbing.HEAD, url)
+		}
+		return head.Hash().String(), nil
+	}
+	tag := plumbing.NewTagReferenceName(revision)
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(revision), tag + "^{}", tag} {
+		if ref, found := byName[name]; found {
+			return ref.Hash().String(), nil
+		}
+	}
+	return "", fmt.Errorf("revision %s not found in %s", revision, url)
+}
+
+// getGitAuth maps the argo-style repository secret to the go-git auth method
+// used for the local checkout. Returns nil when no authentication is needed.
+func getGitAuth(fullClient kubernetes.Interface, url string, secret map[string][]byte) (transport.AuthMethod, error) {
 	switch authType := detectGitAuthType(secret); authType {
 	case GitAuthPassword:
-		options.Auth = getHttpAuth(secret)
+		return getHttpAuth(secret), nil
 	case GitAuthSsh:
 		publicKey, err := getSshPublicKey(url, secret)
 		if err != nil {
 			return nil, err
 		}
-		options.Auth = publicKey
+		return publicKey, nil
 	case GitAuthGitHubApp:
 		gitHubAppAuth, err := getGitHubAppAuth(fullClient, secret)
 		if err != nil {
 			return nil, err
 		}
-
-		options.Auth = gitHubAppAuth
+		return gitHubAppAuth, nil
+	case GitAuthBearerToken:
+		return getBearerTokenAuth(secret), nil
+	case GitAuthGoogleCloud:
+		googleCloudAuth, err := getGoogleCloudAuth(secret)
+		if err != nil {
+			return nil, err
+		}
+		return googleCloudAuth, nil
+	case GitAuthAzureWorkloadIdentity:
+		azureAuth, err := getAzureWorkloadIdentityAuth(azureWorkloadIdentityTokenProvider)
+		if err != nil {
+			return nil, err
+		}
+		return azureAuth, nil
 	}
 
-	return options, nil
+	// GitAuthNone and GitAuthTLSClientCert do not need an auth method, the
+	// client certificate is passed separately via getTLSClientCert()
+	return nil, nil
 }
 
 func getHttpAuth(secret map[string][]byte) *http.BasicAuth {
@@ -409,6 +485,73 @@ func getGitHubAppAuth(fullClient kubernetes.Interface, secret map[string][]byte)
 	return auth, nil
 }
 
+func getBearerTokenAuth(secret map[string][]byte) *http.TokenAuth {
+	return &http.TokenAuth{
+		Token: string(getField(secret, "bearerToken")),
+	}
+}
+
+// getTLSClientCert returns the PEM encoded client certificate and key used for
+// mutual TLS authentication. Both are nil unless the secret contains both fields.
+func getTLSClientCert(secret map[string][]byte) (cert, key []byte) {
+	cert = getField(secret, "tlsClientCertData")
+	key = getField(secret, "tlsClientCertKey")
+	if cert == nil || key == nil {
+		return nil, nil
+	}
+	return cert, key
+}
+
+// getGoogleCloudAuth exchanges the service account key in gcpServiceAccountKey for an
+// access token. Google Cloud Source repositories expect the service account email as
+// username and the token as password, which is what argo does as well.
+func getGoogleCloudAuth(secret map[string][]byte) (*http.BasicAuth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ContextTimeout)
+	defer cancel()
+
+	jsonData := getField(secret, "gcpServiceAccountKey")
+	creds, err := google.CredentialsFromJSON(ctx, jsonData, GoogleCloudSourceScope)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse gcpServiceAccountKey: %w", err)
+	}
+
+	var serviceAccount struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if err = json.Unmarshal(jsonData, &serviceAccount); err != nil {
+		return nil, fmt.Errorf("could not get client_email from gcpServiceAccountKey: %w", err)
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("could not get Google Cloud access token: %w", err)
+	}
+
+	return &http.BasicAuth{
+		Username: serviceAccount.ClientEmail,
+		Password: token.AccessToken,
+	}, nil
+}
+
+// azureWorkloadIdentityTokenProvider is a variable so tests can swap in a fake provider
+var azureWorkloadIdentityTokenProvider = func() workloadidentity.TokenProvider {
+	return workloadidentity.NewWorkloadIdentityTokenProvider()
+}
+
+// getAzureWorkloadIdentityAuth obtains an Entra ID token for Azure DevOps using the
+// federated token projected into the operator pod by the Azure workload identity webhook
+func getAzureWorkloadIdentityAuth(tokenProvider func() workloadidentity.TokenProvider) (*http.TokenAuth, error) {
+	creds := argogit.NewAzureWorkloadIdentityCreds(argogit.NoopCredsStore{}, tokenProvider())
+	token, err := creds.GetAzureDevOpsAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not get Azure workload identity token: %w", err)
+	}
+
+	return &http.TokenAuth{
+		Token: token,
+	}, nil
+}
+
 // This returns the user prefix in git urls like:
 // git@github.com:/foo/bar or "" when not found
 func getUserFromURL(url string) string {
@@ -435,8 +578,9 @@ func repoHash(directory string) (string, error) {
 }
 
 // Developed after https://argo-cd.readthedocs.io/en/stable/operator-manual/declarative-setup/#repositories
-// if a secret has
-// returns "" if a secret could not be parse, "ssh" if it is an ssh auth, and "password" if a username + pass auth
+// Returns the authentication backend described by the secret. When a secret matches
+// several backends the first one in the following order wins: ssh, username + password,
+// GitHub App, bearer token, Google Cloud, Azure workload identity, TLS client certificate
 func detectGitAuthType(secret map[string][]byte) GitAuthenticationBackend {
 	// SSH
 	if _, ok := secret["sshPrivateKey"]; ok {
@@ -458,6 +602,28 @@ func detectGitAuthType(secret map[string][]byte) GitAuthenticationBackend {
 		return GitAuthGitHubApp
 	}
 
+	// Bearer token
+	if _, ok := secret["bearerToken"]; ok {
+		return GitAuthBearerToken
+	}
+
+	// Google Cloud Source
+	if _, ok := secret["gcpServiceAccountKey"]; ok {
+		return GitAuthGoogleCloud
+	}
+
+	// Azure workload identity
+	if strings.EqualFold(string(secret["useAzureWorkloadIdentity"]), "true") {
+		return GitAuthAzureWorkloadIdentity
+	}
+
+	// TLS client certificate only
+	_, hasClientCertData := secret["tlsClientCertData"]
+	_, hasClientCertKey := secret["tlsClientCertKey"]
+	if hasClientCertData && hasClientCertKey {
+		return GitAuthTLSClientCert
+	}
+
 	// None
 	return GitAuthNone
 }
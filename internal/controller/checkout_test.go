@@ -3,9 +3,12 @@ package controllers
 import (
 	"time"
 
+	"github.com/argoproj/argo-cd/v3/util/workloadidentity"
+
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	//+kubebuilder:scaffold:imports
@@ -244,6 +247,67 @@ var _ = Describe("detectGitAuthType", func() {
 			Expect(detectGitAuthType(secret)).To(Equal(GitAuthNone))
 		})
 	})
+
+	Context("with bearer token secret", func() {
+		It("should return GitAuthBearerToken", func() {
+			secret := map[string][]byte{
+				"bearerToken": []byte("token"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthBearerToken))
+		})
+	})
+
+	Context("with Google Cloud secret", func() {
+		It("should return GitAuthGoogleCloud", func() {
+			secret := map[string][]byte{
+				"gcpServiceAccountKey": []byte("{}"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthGoogleCloud))
+		})
+	})
+
+	Context("with Azure workload identity secret", func() {
+		It("should return GitAuthAzureWorkloadIdentity", func() {
+			secret := map[string][]byte{
+				"useAzureWorkloadIdentity": []byte("True"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthAzureWorkloadIdentity))
+		})
+
+		It("should return GitAuthNone when disabled", func() {
+			secret := map[string][]byte{
+				"useAzureWorkloadIdentity": []byte("false"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthNone))
+		})
+	})
+
+	Context("with TLS client certificate secret", func() {
+		It("should return GitAuthTLSClientCert", func() {
+			secret := map[string][]byte{
+				"tlsClientCertData": []byte("cert"),
+				"tlsClientCertKey":  []byte("key"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthTLSClientCert))
+		})
+
+		It("should return GitAuthNone when the key is missing", func() {
+			secret := map[string][]byte{
+				"tlsClientCertData": []byte("cert"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthNone))
+		})
+
+		It("should prefer username and password when both are set", func() {
+			secret := map[string][]byte{
+				"username":          []byte("user"),
+				"password":          []byte("pass"),
+				"tlsClientCertData": []byte("cert"),
+				"tlsClientCertKey":  []byte("key"),
+			}
+			Expect(detectGitAuthType(secret)).To(Equal(GitAuthPassword))
+		})
+	})
 })
 
 var _ = Describe("getField", func() {
@@ -304,6 +368,74 @@ var _ = Describe("getFetchOptions", func() {
 	})
 })
 
+var _ = Describe("getGitAuth", func() {
+	Context("with bearer token authentication", func() {
+		It("should return token auth", func() {
+			secret := map[string][]byte{
+				"bearerToken": []byte("token"),
+			}
+			auth, err := getGitAuth(nil, "https://bitbucket.example.com/scm/repo.git", secret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(auth).To(Equal(&http.TokenAuth{Token: "token"}))
+		})
+	})
+
+	Context("with an invalid Google Cloud service account key", func() {
+		It("should return an error", func() {
+			secret := map[string][]byte{
+				"gcpServiceAccountKey": []byte("not-json"),
+			}
+			_, err := getGitAuth(nil, "https://source.developers.google.com/p/project/r/repo", secret)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("could not parse gcpServiceAccountKey"))
+		})
+	})
+
+	Context("with TLS client certificate only", func() {
+		It("should not return an auth method", func() {
+			secret := map[string][]byte{
+				"tlsClientCertData": []byte("cert"),
+				"tlsClientCertKey":  []byte("key"),
+			}
+			auth, err := getGitAuth(nil, "https://github.com/user/repo", secret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(auth).To(BeNil())
+		})
+	})
+})
+
+var _ = Describe("getAzureWorkloadIdentityAuth", func() {
+	It("should return token auth with the workload identity token", func() {
+		provider := func() workloadidentity.TokenProvider {
+			return &fakeTokenProvider{token: "azure-token"}
+		}
+		auth, err := getAzureWorkloadIdentityAuth(provider)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(auth.Token).To(Equal("azure-token"))
+	})
+})
+
+var _ = Describe("getTLSClientCert", func() {
+	It("should return the certificate and key", func() {
+		secret := map[string][]byte{
+			"tlsClientCertData": []byte("cert"),
+			"tlsClientCertKey":  []byte("key"),
+		}
+		cert, key := getTLSClientCert(secret)
+		Expect(cert).To(Equal([]byte("cert")))
+		Expect(key).To(Equal([]byte("key")))
+	})
+
+	It("should return nothing when only the certificate is set", func() {
+		secret := map[string][]byte{
+			"tlsClientCertData": []byte("cert"),
+		}
+		cert, key := getTLSClientCert(secret)
+		Expect(cert).To(BeNil())
+		Expect(key).To(BeNil())
+	})
+})
+
 var _ = Describe("getCloneOptions", func() {
 	Context("with no authentication", func() {
 		It("should return options without auth", func() {
@@ -328,6 +460,20 @@ var _ = Describe("getCloneOptions", func() {
 		})
 	})
 
+	Context("with TLS client certificate authentication", func() {
+		It("should set the client certificate and key", func() {
+			secret := map[string][]byte{
+				"tlsClientCertData": []byte("cert"),
+				"tlsClientCertKey":  []byte("key"),
+			}
+			opts, err := getCloneOptions(nil, "https://github.com/user/repo", secret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.Auth).To(BeNil())
+			Expect(opts.ClientCert).To(Equal([]byte("cert")))
+			Expect(opts.ClientKey).To(Equal([]byte("key")))
+		})
+	})
+
 	Context("with SSH authentication and invalid key", func() {
 		It("should return an error", func() {
 			secret := map[string][]byte{
@@ -518,3 +664,11 @@ var _ = Describe("getCommitFromTarget", func() {
 		Expect(err.Error()).To(ContainSubstring("unknown target"))
 	})
 })
+
+type fakeTokenProvider struct {
+	token string
+}
+
+func (f *fakeTokenProvider) GetToken(_ string) (*workloadidentity.Token, error) {
+	return &workloadidentity.Token{AccessToken: f.token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
@@ -49,8 +49,12 @@ const (
 	catalogComponentLabel = "patterns-operator-pattern-ui-catalog"
 )
 
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch
+// ImageMirror rewrites the catalog image to its mirror on disconnected clusters.
+// It is set by the pattern controller and leaves the image untouched by default.
+var ImageMirror = func(image string) string { return image }
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 
 // CreateOrUpdateCatalog creates or updates the pattern-ui-catalog ConfigMap, Service,
 // and Deployment. If the operator ConfigMap contains a "catalog.image" key, that
@@ -60,7 +64,7 @@ func CreateOrUpdateCatalog(ctx context.Context, cl client.Client, operatorConfig
 	logger := log.FromContext(ctx).WithName("catalog")
 	ns := getDeploymentNamespace()
 
-	image := getCatalogImage(operatorConfigMap)
+	image := ImageMirror(CatalogImage(operatorConfigMap))
 	logger.Info("using catalog image", "image", image)
 
 	if err := createOrUpdateCatalogConfigMap(ctx, cl, ns); err != nil {
@@ -76,9 +80,9 @@ func CreateOrUpdateCatalog(ctx context.Context, cl client.Client, operatorConfig
 	return nil
 }
 
-// getCatalogImage reads the optional "catalog.image" override from the operator
+// CatalogImage reads the optional "catalog.image" override from the operator
 // ConfigMap. Returns the default image when the key is absent or empty.
-func getCatalogImage(operatorConfigMap *corev1.ConfigMap) string {
+func CatalogImage(operatorConfigMap *corev1.ConfigMap) string {
 	if operatorConfigMap != nil {
 		if image, ok := operatorConfigMap.Data["catalog.image"]; ok && image != "" {
 			return image
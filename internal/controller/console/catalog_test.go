@@ -2,6 +2,7 @@ package console
 
 import (
 	"context"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -135,6 +136,27 @@ var _ = Describe("CreateOrUpdateCatalog", func() {
 		})
 	})
 
+	Context("when the catalog image is mirrored", func() {
+		BeforeEach(func() {
+			cl = newFakeClient()
+			ImageMirror = func(image string) string {
+				return strings.Replace(image, "quay.io/validatedpatterns", "mirror.example.com/vp", 1)
+			}
+		})
+
+		AfterEach(func() {
+			ImageMirror = func(image string) string { return image }
+		})
+
+		It("should use the mirrored image", func() {
+			Expect(CreateOrUpdateCatalog(ctx, cl, nil)).To(Succeed())
+
+			deploy := &appsv1.Deployment{}
+			Expect(cl.Get(ctx, client.ObjectKey{Namespace: defaultNamespace, Name: CatalogDeploymentName}, deploy)).To(Succeed())
+			Expect(deploy.Spec.Template.Spec.Containers[0].Image).To(Equal("mirror.example.com/vp/pattern-ui-catalog:stable-v1"))
+		})
+	})
+
 	Context("when the operator ConfigMap is empty (no data)", func() {
 		BeforeEach(func() {
 			cl = newFakeClient()
@@ -153,3 +153,25 @@ func EnablePlugin(ctx context.Context, cl client.Client) error {
 	}
 	return nil
 }
+
+// DisablePlugin removes the plugin from the console operator configuration, the reverse of EnablePlugin
+func DisablePlugin(ctx context.Context, cl client.Client) error {
+	consoleKey := client.ObjectKey{Namespace: "", Name: "cluster"}
+	consoleObj := &operatorv1.Console{}
+	if err := cl.Get(ctx, consoleKey, consoleObj); apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not find resource - APIVersion: %s, Kind: %s, Name: %s: %w",
+			consoleObj.APIVersion, consoleObj.Kind, consoleObj.Name, err)
+	}
+
+	if i := slices.Index(consoleObj.Spec.Plugins, PluginName); i >= 0 {
+		consoleObj.Spec.Plugins = slices.Delete(consoleObj.Spec.Plugins, i, i+1)
+		err := cl.Update(ctx, consoleObj)
+		if err != nil {
+			return fmt.Errorf("could not update resource - APIVersion: %s, Kind: %s, Name: %s: %w",
+				consoleObj.APIVersion, consoleObj.Kind, consoleObj.Name, err)
+		}
+	}
+	return nil
+}
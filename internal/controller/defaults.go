@@ -40,6 +40,11 @@ const (
 	LegacyApplicationNamespace = "openshift-gitops"
 	// Legacy ClusterWide Argo Name
 	LegacyClusterWideArgoName = "openshift-gitops"
+	// Label set on every argo application created for a pattern, and on the ManagedClusters it owns,
+	// its value is the pattern name
+	PatternApplicationLabel = "validatedpatterns.io/pattern"
+	// Image of the init container fetching the CA bundles of the argo repo server
+	ArgoInitContainerImage = "registry.redhat.io/ubi9/ubi-minimal:latest"
 )
 
 // GitOps Subscription
@@ -81,6 +86,41 @@ const (
 	GiteaApplicationName = "gitea-in-cluster"
 	// Gitea Default Random Password Length
 	GiteaDefaultPasswordLen = 15
+	// Name of the gitea in-cluster git server backend
+	GiteaGitServerBackend = "gitea"
+	// Default interval of the gitea push mirror
+	GiteaDefaultPushMirrorInterval = "8h0m0s"
+	// Suffixes of the repositories a resync imports into and of the repositories it replaces
+	GiteaResyncSuffix   = "-resync"
+	GiteaReplacedSuffix = "-replaced"
+	// Unprivileged gitea user argo reads the repositories with
+	GiteaReaderUser = "vp_reader"
+	// Secret holding the read-only token of GiteaReaderUser
+	GiteaReaderSecretName = "gitea-reader-secret" //nolint:gosec
+	// Secret holding the token the operator imports and syncs repositories with
+	GiteaMigrationSecretName = "gitea-migration-secret" //nolint:gosec
+	// Names of the gitea access tokens created by the operator
+	GiteaReaderTokenName    = "vp-argocd-read"
+	GiteaMigrationTokenName = "vp-operator-migration"
+	// Name of the argo repository secret for the in-cluster git server
+	InClusterGitCredentialsSecretName = "vp-in-cluster-git-credentials" //nolint:gosec
+)
+
+// Helm chart repository
+const (
+	// Name of the clustergroup chart in the helm repository
+	ClusterGroupChartName = "clustergroup"
+	// Name of the argo repository secret holding the helm repository credentials
+	HelmRepoCredentialsSecretName = "vp-helm-repo-credentials" //nolint:gosec
+)
+
+// Pattern deletion
+const (
+	// How long each deletion phase waits for its applications to be removed before it moves on.
+	// 0 waits until the phase completes or is forced with the force deletion annotation
+	DefaultSpokeChildAppsDeletionTimeout = "0"
+	DefaultSpokeDeletionTimeout          = "0"
+	DefaultHubChildAppsDeletionTimeout   = "0"
 )
 
 // Experimental Capabilities that can be enabled
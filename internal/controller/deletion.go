@@ -0,0 +1,398 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+// Operator config keys of the timeout of each deletion phase that waits for applications to go
+var deletionPhaseTimeoutKeys = map[api.PatternDeletionPhase]string{
+	api.DeleteSpokeChildApps: "deletion.spokeChildAppsTimeout",
+	api.DeleteSpoke:          "deletion.spokeTimeout",
+	api.DeleteHubChildApps:   "deletion.hubChildAppsTimeout",
+}
+
+// Annotation holding the argo sync options of a resource
+const argoSyncOptionsAnnotation = "argocd.argoproj.io/sync-options"
+
+// Kinds of resources the retain policy can leave in place. The operator is only allowed to annotate
+// these, keep the list in line with the RetainConfig kinds enum and the RBAC markers of the controller
+var retainableKinds = []schema.GroupKind{
+	{Kind: "ConfigMap"},
+	{Kind: "Namespace"},
+	{Kind: "PersistentVolumeClaim"},
+	{Kind: "Secret"},
+	{Group: "apps", Kind: "StatefulSet"},
+}
+
+// Sync options that make argo leave a resource in place when it is pruned or its application is deleted
+var retainSyncOptions = []string{"Prune=false", "Delete=false"}
+
+// deletionEscalation is how a deletion phase that is still waiting goes on
+type deletionEscalation int
+
+const (
+	// The phase keeps waiting
+	deletionWait deletionEscalation = iota
+	// The phase timed out and moves on
+	deletionTimedOut
+	// The phase was forced with the force deletion annotation
+	deletionForced
+)
+
+// deletionWaitError is returned by a deletion phase that is waiting for resources to be removed
+type deletionWaitError struct {
+	message   string
+	waitingOn []string
+}
+
+func (e *deletionWaitError) Error() string {
+	if len(e.waitingOn) == 0 {
+		return e.message
+	}
+	return fmt.Sprintf("%s: %s", e.message, strings.Join(e.waitingOn, ", "))
+}
+
+func (r *PatternReconciler) updateDeletionPhase(instance *api.Pattern, phase api.PatternDeletionPhase) error {
+	log.Printf("Updating deletion phase to '%s'", phase)
+	now := metav1.Now()
+	deletion := &api.PatternDeletionStatus{PhaseStartTime: &now}
+	if instance.Status.Deletion != nil {
+		deletion.LastForceRequest = instance.Status.Deletion.LastForceRequest
+		deletion.Retained = instance.Status.Deletion.Retained
+	}
+	instance.Status.DeletionPhase = phase
+	instance.Status.Deletion = deletion
+	if err := r.patchStatus(instance); err != nil {
+		return fmt.Errorf("failed to update deletion phase: %w", err)
+	}
+
+	// Re-fetch to get updated status
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(instance), instance); err != nil {
+		return fmt.Errorf("failed to re-fetch pattern after phase update: %w", err)
+	}
+
+	return nil
+}
+
+// escalateDeletionPhase decides what to do with a deletion phase that failed with waitErr. The phase
+// moves on when it timed out or the force annotation has a new value, otherwise escalateDeletionPhase
+// records what the phase is waiting on and returns waitErr
+func (r *PatternReconciler) escalateDeletionPhase(p *api.Pattern, patternsOperatorConfig PatternsOperatorConfig,
+	waitErr error) (deletionEscalation, error) {
+	phase := p.Status.DeletionPhase
+	deletion := &api.PatternDeletionStatus{}
+	if p.Status.Deletion != nil {
+		deletion = p.Status.Deletion.DeepCopy()
+	}
+	if deletion.PhaseStartTime == nil {
+		// The phase started before its start time was tracked
+		now := metav1.Now()
+		deletion.PhaseStartTime = &now
+	}
+	deletion.PhaseDeadline = nil
+	if timeout := patternsOperatorConfig.getDurationValue(deletionPhaseTimeoutKeys[phase]); timeout > 0 {
+		deadline := metav1.NewTime(deletion.PhaseStartTime.Add(timeout))
+		deletion.PhaseDeadline = &deadline
+	}
+
+	escalation := deletionWait
+	var reason, message string
+	if request := p.Annotations[api.ForceDeletionAnnotation]; request != "" && request != deletion.LastForceRequest {
+		deletion.LastForceRequest = request
+		escalation = deletionForced
+		reason = "DeletionPhaseForced"
+		message = fmt.Sprintf("Forcing deletion phase %s as requested by %s", phase, api.ForceDeletionAnnotation)
+	} else if deletion.PhaseDeadline != nil && time.Now().After(deletion.PhaseDeadline.Time) {
+		escalation = deletionTimedOut
+		reason = "DeletionPhaseTimedOut"
+		message = fmt.Sprintf("Deletion phase %s timed out after %s, moving on", phase, deletion.PhaseDeadline.Sub(deletion.PhaseStartTime.Time))
+	}
+
+	deletion.WaitingOn = nil
+	var wait *deletionWaitError
+	if errors.As(waitErr, &wait) {
+		deletion.WaitingOn = wait.waitingOn
+		deletion.Message = wait.message
+	} else {
+		deletion.Message = waitErr.Error()
+	}
+
+	if escalation != deletionWait {
+		if len(deletion.WaitingOn) > 0 {
+			message = fmt.Sprintf("%s, still waiting on: %s", message, strings.Join(deletion.WaitingOn, ", "))
+		}
+		log.Print(message)
+		r.recorder.Event(p, corev1.EventTypeWarning, reason, message)
+		// updateDeletionPhase persists LastForceRequest when moving on
+		p.Status.Deletion = deletion
+		return escalation, nil
+	}
+
+	if !equality.Semantic.DeepEqual(p.Status.Deletion, deletion) {
+		p.Status.Deletion = deletion
+		if err := r.patchStatus(p); err != nil {
+			return deletionWait, fmt.Errorf("failed to update the deletion status: %w", err)
+		}
+	}
+	return deletionWait, waitErr
+}
+
+// forceRemoveChildApplications deletes the child applications of app that are left and removes their
+// finalizers, so that argo stops waiting for resources it cannot prune. It is only called when the
+// deletion is forced with the force deletion annotation
+func (r *PatternReconciler) forceRemoveChildApplications(p *api.Pattern, app *argoapi.Application) error {
+	childApps, err := getChildApplications(r.argoClient, app)
+	if err != nil {
+		return err
+	}
+
+	for i := range childApps {
+		childApp := &childApps[i]
+		apps := r.argoClient.ArgoprojV1alpha1().Applications(childApp.Namespace)
+		if len(childApp.Finalizers) > 0 {
+			_, err = apps.Patch(context.TODO(), childApp.Name, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+			if err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("failed to remove the finalizers of application %q: %w", childApp.Name, err)
+			}
+			message := fmt.Sprintf("Removed finalizers %v of application %s/%s", childApp.Finalizers, childApp.Namespace, childApp.Name)
+			log.Print(message)
+			r.recorder.Event(p, corev1.EventTypeWarning, "ApplicationFinalizersRemoved", message)
+		}
+		if err = apps.Delete(context.TODO(), childApp.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete application %q: %w", childApp.Name, err)
+		}
+	}
+	return nil
+}
+
+// deletionPreview computes what deleting p, whose clusterGroup application is app, removes
+func (r *PatternReconciler) deletionPreview(p *api.Pattern, app *argoapi.Application) *api.PatternDeletionPreview {
+	preview := &api.PatternDeletionPreview{Prune: strings.EqualFold(p.Annotations[api.PruneAnnotation], "true")}
+	var errs []string
+
+	namespaces := map[string]bool{}
+	addNamespaces := func(a *argoapi.Application) {
+		for _, resource := range a.Status.Resources {
+			if resource.Group == "" && resource.Kind == "Namespace" {
+				namespaces[resource.Name] = true
+			}
+		}
+	}
+	apps := []*argoapi.Application{app}
+	childApps, err := getChildApplications(r.argoClient, app)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	for i := range childApps {
+		preview.Applications = append(preview.Applications, fmt.Sprintf("%s/%s", childApps[i].Namespace, childApps[i].Name))
+		apps = append(apps, &childApps[i])
+	}
+	for _, a := range apps {
+		addNamespaces(a)
+	}
+	for _, resource := range retainedResources(p, apps) {
+		preview.Retained = append(preview.Retained, retainedResourceName(&resource))
+		if resource.Group == "" && resource.Kind == "Namespace" {
+			delete(namespaces, resource.Name)
+		}
+	}
+	for ns := range namespaces {
+		preview.Namespaces = append(preview.Namespaces, ns)
+	}
+
+	if haveACMHub(r) {
+		spokeApps, err := patternSpokeApplications(context.Background(), r.acmSearch, p)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("could not search the spoke applications: %v", err))
+		}
+		for _, spokeApp := range spokeApps {
+			preview.SpokeApplications = append(preview.SpokeApplications, fmt.Sprintf("%s/%s in %s", spokeApp.Namespace, spokeApp.Name, spokeApp.Cluster))
+		}
+		if preview.ManagedClusters, err = r.listManagedClusters(context.Background(), p); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	sort.Strings(preview.Applications)
+	sort.Strings(preview.SpokeApplications)
+	sort.Strings(preview.ManagedClusters)
+	sort.Strings(preview.Namespaces)
+	preview.Message = strings.Join(errs, "; ")
+	return preview
+}
+
+// updateDeletionPreview refreshes status.deletionPreview according to the deletion preview annotation
+// and returns true when it changed
+func (r *PatternReconciler) updateDeletionPreview(p *api.Pattern, app *argoapi.Application) bool {
+	if !strings.EqualFold(p.Annotations[api.DeletionPreviewAnnotation], "true") {
+		changed := p.Status.DeletionPreview != nil
+		p.Status.DeletionPreview = nil
+		return changed
+	}
+
+	preview := r.deletionPreview(p, app)
+	if p.Status.DeletionPreview != nil {
+		preview.LastUpdated = p.Status.DeletionPreview.LastUpdated
+		if equality.Semantic.DeepEqual(p.Status.DeletionPreview, preview) {
+			return false
+		}
+	}
+	now := metav1.Now()
+	preview.LastUpdated = &now
+	p.Status.DeletionPreview = preview
+	return true
+}
+
+// isRetained returns true when the retain policy selects resource, which must be of one of retainableKinds
+func isRetained(retain *api.RetainConfig, resource *argoapi.ResourceStatus) bool {
+	if !slices.Contains(retainableKinds, schema.GroupKind{Group: resource.Group, Kind: resource.Kind}) {
+		return false
+	}
+	if slices.Contains(retain.Namespaces, resource.Namespace) {
+		return true
+	}
+	if resource.Group == "" && resource.Kind == "Namespace" && slices.Contains(retain.Namespaces, resource.Name) {
+		return true
+	}
+	return slices.Contains(retain.Kinds, api.RetainKind(resource.Kind))
+}
+
+// retainedResources returns the resources of apps the retain policy of p leaves in place
+func retainedResources(p *api.Pattern, apps []*argoapi.Application) []argoapi.ResourceStatus {
+	if p.Spec.Deletion == nil || p.Spec.Deletion.Retain == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var resources []argoapi.ResourceStatus
+	for _, app := range apps {
+		for i := range app.Status.Resources {
+			resource := &app.Status.Resources[i]
+			name := retainedResourceName(resource)
+			if seen[name] || !isRetained(p.Spec.Deletion.Retain, resource) {
+				continue
+			}
+			seen[name] = true
+			resources = append(resources, *resource)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return retainedResourceName(&resources[i]) < retainedResourceName(&resources[j])
+	})
+	return resources
+}
+
+func retainedResourceName(resource *argoapi.ResourceStatus) string {
+	if resource.Namespace == "" {
+		return fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", resource.Kind, resource.Namespace, resource.Name)
+}
+
+// retainResources annotates the resources of app and of its child applications selected by the retain
+// policy of p, so that argo leaves them in place, and returns their names
+func (r *PatternReconciler) retainResources(p *api.Pattern, app *argoapi.Application) ([]string, error) {
+	if p.Spec.Deletion == nil || p.Spec.Deletion.Retain == nil {
+		return nil, nil
+	}
+
+	apps := []*argoapi.Application{app}
+	childApps, err := getChildApplications(r.argoClient, app)
+	if err != nil {
+		return nil, err
+	}
+	for i := range childApps {
+		apps = append(apps, &childApps[i])
+	}
+
+	var retained []string
+	for _, resource := range retainedResources(p, apps) {
+		if err := r.retainResource(&resource); err != nil {
+			return nil, err
+		}
+		retained = append(retained, retainedResourceName(&resource))
+	}
+	return retained, nil
+}
+
+// retainResource adds retainSyncOptions to the argo sync options of resource
+func (r *PatternReconciler) retainResource(resource *argoapi.ResourceStatus) error {
+	mapping, err := r.restMapper.RESTMapping(schema.GroupKind{Group: resource.Group, Kind: resource.Kind}, resource.Version)
+	if err != nil {
+		return fmt.Errorf("could not find the resource type of %s: %w", retainedResourceName(resource), err)
+	}
+	var client dynamic.ResourceInterface = r.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = r.dynamicClient.Resource(mapping.Resource).Namespace(resource.Namespace)
+	}
+
+	obj, err := client.Get(context.TODO(), resource.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not get %s: %w", retainedResourceName(resource), err)
+	}
+
+	var options []string
+	if current := obj.GetAnnotations()[argoSyncOptionsAnnotation]; current != "" {
+		for _, option := range strings.Split(current, ",") {
+			options = append(options, strings.TrimSpace(option))
+		}
+	}
+	changed := false
+	for _, option := range retainSyncOptions {
+		if !slices.Contains(options, option) {
+			options = append(options, option)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch, _ := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": map[string]string{argoSyncOptionsAnnotation: strings.Join(options, ",")}},
+	})
+	if _, err = client.Patch(context.TODO(), resource.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("could not annotate %s: %w", retainedResourceName(resource), err)
+	}
+	log.Printf("Retaining %s", retainedResourceName(resource))
+	return nil
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var _ = Describe("Deletion preview", func() {
+	var reconciler *PatternReconciler
+	var pattern *api.Pattern
+	var app *argoapi.Application
+	var search *fakeACMSearch
+
+	newApp := func(name, parent string, namespaces ...string) *argoapi.Application {
+		a := &argoapi.Application{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ApplicationNamespace}}
+		if parent != "" {
+			a.Annotations = map[string]string{"argocd.argoproj.io/tracking-id": parent + ":argoproj.io/Application:" + parent + "/" + name}
+		}
+		for _, ns := range namespaces {
+			a.Status.Resources = append(a.Status.Resources, argoapi.ResourceStatus{Version: "v1", Kind: "Namespace", Name: ns})
+		}
+		a.Status.Resources = append(a.Status.Resources, argoapi.ResourceStatus{Version: "v1", Kind: "ConfigMap", Namespace: "ignored", Name: "cm"})
+		return a
+	}
+
+	BeforeEach(func() {
+		pattern = buildPatternManifest()
+		pattern.Annotations = map[string]string{api.DeletionPreviewAnnotation: "true"}
+		app = newApp("multicloud-gitops-hub", "", "config-demo", "vault")
+		pattern.Status.ManagedClusters = []api.PatternManagedCluster{{Name: "region-one", ClusterGroup: "region-one"}}
+		search = &fakeACMSearch{applications: []acmsearch.Application{
+			{Name: "foo-region-one", Namespace: getClusterWideArgoNamespace(), Cluster: "region-one"},
+			{Name: "config-demo", Namespace: "foo-region-one", Cluster: "region-one"},
+			// Another pattern and another team deploying on the same cluster
+			{Name: "other-region-one", Namespace: getClusterWideArgoNamespace(), Cluster: "region-one"},
+			{Name: "billing", Namespace: "billing", Cluster: "region-one"},
+		}}
+
+		gvrMCH := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "multiclusterhubs"}
+		gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+		reconciler = newFakeReconciler()
+		reconciler.argoClient = argofake.NewSimpleClientset(app,
+			newApp("vault", app.Name, "vault"),
+			newApp("golang-external-secrets", app.Name, "golang-external-secrets"),
+			newApp("unrelated", "other-app", "unrelated"))
+		reconciler.acmSearch = search
+		reconciler.dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			gvrMCH: "MultiClusterHubList",
+			gvrMC:  "ManagedClusterList",
+		}, &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "operator.open-cluster-management.io/v1",
+			"kind":       "MultiClusterHub",
+			"metadata": map[string]any{
+				"name":        "multiclusterhub",
+				"namespace":   "open-cluster-management",
+				"annotations": map[string]any{"patterns.gitops.validatedpatterns.io/managed": "true"},
+			},
+		}}, &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "cluster.open-cluster-management.io/v1",
+			"kind":       "ManagedCluster",
+			"metadata":   map[string]any{"name": "region-one", "labels": map[string]any{PatternApplicationLabel: pattern.Name}},
+		}}, &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "cluster.open-cluster-management.io/v1",
+			"kind":       "ManagedCluster",
+			"metadata":   map[string]any{"name": "local-cluster"},
+		}})
+	})
+
+	It("should list what the deletion removes", func() {
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeTrue())
+		preview := pattern.Status.DeletionPreview
+		Expect(preview).ToNot(BeNil())
+		Expect(preview.Prune).To(BeFalse())
+		Expect(preview.LastUpdated).ToNot(BeNil())
+		Expect(preview.Applications).To(Equal([]string{"vp-gitops/golang-external-secrets", "vp-gitops/vault"}))
+		Expect(preview.SpokeApplications).To(Equal([]string{
+			"foo-region-one/config-demo in region-one",
+			getClusterWideArgoNamespace() + "/foo-region-one in region-one",
+		}))
+		Expect(preview.ManagedClusters).To(Equal([]string{"region-one"}))
+		Expect(preview.Namespaces).To(Equal([]string{"config-demo", "golang-external-secrets", "vault"}))
+		Expect(preview.Message).To(BeEmpty())
+
+		// Nothing changed
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeFalse())
+	})
+
+	It("should report whether the deletion cascades", func() {
+		pattern.Annotations[api.PruneAnnotation] = "true"
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeTrue())
+		Expect(pattern.Status.DeletionPreview.Prune).To(BeTrue())
+	})
+
+	It("should publish a partial preview when the search fails", func() {
+		search.applications = nil
+		search.err = fmt.Errorf("search service returned status 503")
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeTrue())
+		Expect(pattern.Status.DeletionPreview.SpokeApplications).To(BeEmpty())
+		Expect(pattern.Status.DeletionPreview.ManagedClusters).To(Equal([]string{"region-one"}))
+		Expect(pattern.Status.DeletionPreview.Message).To(ContainSubstring("503"))
+	})
+
+	It("should clear the preview once the annotation is removed", func() {
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeTrue())
+		delete(pattern.Annotations, api.DeletionPreviewAnnotation)
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeTrue())
+		Expect(pattern.Status.DeletionPreview).To(BeNil())
+		Expect(reconciler.updateDeletionPreview(pattern, app)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Retain policy", func() {
+	var reconciler *PatternReconciler
+	var pattern *api.Pattern
+	var app *argoapi.Application
+	gvrNamespace := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	gvrPVC := schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+	gvrSecret := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+	newObject := func(kind, namespace, name string, annotations map[string]any) *unstructured.Unstructured {
+		metadata := map[string]any{"name": name, "annotations": annotations}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+		return &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": kind, "metadata": metadata}}
+	}
+
+	BeforeEach(func() {
+		pattern = buildPatternManifest()
+		pattern.Spec.Deletion = &api.DeletionConfig{Retain: &api.RetainConfig{
+			Namespaces: []string{"vault"},
+			Kinds:      []api.RetainKind{"PersistentVolumeClaim"},
+		}}
+		app = &argoapi.Application{ObjectMeta: metav1.ObjectMeta{Name: "multicloud-gitops-hub", Namespace: ApplicationNamespace}}
+		app.Status.Resources = []argoapi.ResourceStatus{
+			{Version: "v1", Kind: "Namespace", Name: "vault"},
+			{Version: "v1", Kind: "Namespace", Name: "config-demo"},
+			{Version: "v1", Kind: "Secret", Namespace: "vault", Name: "vault-token"},
+			{Version: "v1", Kind: "Secret", Namespace: "config-demo", Name: "config-demo-secret"},
+			{Version: "v1", Kind: "PersistentVolumeClaim", Namespace: "config-demo", Name: "data"},
+		}
+
+		restMapper := meta.NewDefaultRESTMapper(nil)
+		restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+		restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+		restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}, meta.RESTScopeNamespace)
+
+		reconciler = newFakeReconciler()
+		reconciler.argoClient = argofake.NewSimpleClientset(app)
+		reconciler.restMapper = restMapper
+		reconciler.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			newObject("Namespace", "", "vault", nil),
+			newObject("Secret", "vault", "vault-token", map[string]any{argoSyncOptionsAnnotation: "ServerSideApply=true"}),
+			newObject("Secret", "config-demo", "config-demo-secret", nil),
+			newObject("PersistentVolumeClaim", "config-demo", "data", map[string]any{argoSyncOptionsAnnotation: "Prune=false,Delete=false"}),
+		)
+	})
+
+	It("should select the resources of the retained namespaces and kinds", func() {
+		retain := pattern.Spec.Deletion.Retain
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "Namespace", Name: "vault"})).To(BeTrue())
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "Secret", Namespace: "vault", Name: "vault-token"})).To(BeTrue())
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "PersistentVolumeClaim", Namespace: "other", Name: "data"})).To(BeTrue())
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "Secret", Namespace: "other", Name: "secret"})).To(BeFalse())
+
+		// The operator is not allowed to annotate other kinds, even in a retained namespace
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "Route", Group: "route.openshift.io", Namespace: "vault", Name: "vault"})).To(BeFalse())
+		retain.Kinds = []api.RetainKind{"StatefulSet"}
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "StatefulSet", Group: "apps", Namespace: "other", Name: "db"})).To(BeTrue())
+		Expect(isRetained(retain, &argoapi.ResourceStatus{Kind: "StatefulSet", Group: "example.com", Namespace: "other", Name: "db"})).To(BeFalse())
+	})
+
+	It("should annotate the retained resources so that argo leaves them in place", func() {
+		retained, err := reconciler.retainResources(pattern, app)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retained).To(Equal([]string{
+			"Namespace vault",
+			"PersistentVolumeClaim config-demo/data",
+			"Secret vault/vault-token",
+		}))
+
+		ns, err := reconciler.dynamicClient.Resource(gvrNamespace).Get(context.Background(), "vault", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ns.GetAnnotations()).To(HaveKeyWithValue(argoSyncOptionsAnnotation, "Prune=false,Delete=false"))
+		secret, err := reconciler.dynamicClient.Resource(gvrSecret).Namespace("vault").Get(context.Background(), "vault-token", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secret.GetAnnotations()).To(HaveKeyWithValue(argoSyncOptionsAnnotation, "ServerSideApply=true,Prune=false,Delete=false"))
+		pvc, err := reconciler.dynamicClient.Resource(gvrPVC).Namespace("config-demo").Get(context.Background(), "data", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()).To(HaveKeyWithValue(argoSyncOptionsAnnotation, "Prune=false,Delete=false"))
+		other, err := reconciler.dynamicClient.Resource(gvrSecret).Namespace("config-demo").Get(context.Background(), "config-demo-secret", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.GetAnnotations()).ToNot(HaveKey(argoSyncOptionsAnnotation))
+	})
+
+	It("should leave everything alone without a retain policy", func() {
+		pattern.Spec.Deletion = nil
+		retained, err := reconciler.retainResources(pattern, app)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retained).To(BeEmpty())
+	})
+
+	It("should show the retained resources in the deletion preview", func() {
+		preview := reconciler.deletionPreview(pattern, app)
+		Expect(preview.Retained).To(HaveLen(3))
+		Expect(preview.Namespaces).To(Equal([]string{"config-demo"}))
+	})
+})
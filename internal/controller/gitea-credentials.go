@@ -0,0 +1,224 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"code.gitea.io/sdk/gitea"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+// Token scopes as understood by gitea >= 1.19. The sdk only knows about the older ones
+const (
+	giteaScopeReadRepository  gitea.AccessTokenScope = "read:repository"
+	giteaScopeWriteRepository gitea.AccessTokenScope = "write:repository"
+)
+
+// giteaCredentials are the scoped credentials used instead of the gitea admin account: a
+// token for the operator to import and sync repositories and a read-only user for argo
+type giteaCredentials struct {
+	MigrationToken string
+	ReaderUser     string
+	ReaderToken    string
+}
+
+// ensureCredentials rotates the admin password when requested and makes sure the migration
+// and reader tokens exist, creating them with the admin account when they do not. The admin
+// account is only used for that, most of the time the secrets are there and it is not needed
+func (g *giteaGitServer) ensureCredentials(input *api.Pattern, giteaRouteURL string) (*giteaCredentials, error) {
+	r := g.r
+	adminSecret, err := getSecret(r.fullClient, GiteaAdminSecretName, GiteaNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gitea Admin Secret: %v", err)
+	}
+	adminUser := string(adminSecret.Data["username"])
+	var adminClient *gitea.Client
+	getAdminClient := func() (*gitea.Client, error) {
+		if adminClient != nil {
+			return adminClient, nil
+		}
+		client, clientErr := newGiteaClient(r.fullClient, giteaRouteURL, gitea.SetBasicAuth(adminUser, string(adminSecret.Data["password"])))
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		adminClient = client
+		return adminClient, nil
+	}
+
+	if request := input.Annotations[api.RotateGitServerPasswordAnnotation]; request != "" &&
+		(input.Status.GitServer == nil || input.Status.GitServer.LastPasswordRotation != request) {
+		client, clientErr := getAdminClient()
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		if adminClient, err = g.rotateAdminPassword(client, giteaRouteURL, adminUser); err != nil {
+			return nil, err
+		}
+		getGitServerStatus(input).LastPasswordRotation = request
+	}
+
+	migration, err := g.ensureCredentialsSecret(GiteaMigrationSecretName, func() (map[string][]byte, error) {
+		client, clientErr := getAdminClient()
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		token, tokenErr := recreateGiteaToken(client, GiteaMigrationTokenName, giteaScopeWriteRepository)
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+		return map[string][]byte{"username": []byte(adminUser), "token": []byte(token)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := g.ensureCredentialsSecret(GiteaReaderSecretName, func() (map[string][]byte, error) {
+		// Nobody logs in as the reader, so its password is only used to create the token
+		password, pwErr := GenerateRandomPassword(GiteaDefaultPasswordLen, DefaultRandRead)
+		if pwErr != nil {
+			return nil, pwErr
+		}
+		client, clientErr := getAdminClient()
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		if pwErr = ensureGiteaUser(client, GiteaReaderUser, password); pwErr != nil {
+			return nil, pwErr
+		}
+		readerClient, clientErr := newGiteaClient(r.fullClient, giteaRouteURL, gitea.SetBasicAuth(GiteaReaderUser, password))
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		token, tokenErr := recreateGiteaToken(readerClient, GiteaReaderTokenName, giteaScopeReadRepository)
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+		return map[string][]byte{"username": []byte(GiteaReaderUser), "password": []byte(token)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &giteaCredentials{
+		MigrationToken: string(migration["token"]),
+		ReaderUser:     string(reader["username"]),
+		ReaderToken:    string(reader["password"]),
+	}, nil
+}
+
+// rotateAdminPassword sets a new admin password and drops the tokens derived from the admin
+// account so that they get created again. It returns a client using the new password
+func (g *giteaGitServer) rotateAdminPassword(adminClient *gitea.Client, giteaRouteURL, adminUser string) (*gitea.Client, error) {
+	r := g.r
+	password, err := GenerateRandomPassword(GiteaDefaultPasswordLen, DefaultRandRead)
+	if err != nil {
+		return nil, fmt.Errorf("error Generating gitea_admin password: %v", err)
+	}
+	mustChange := false
+	// Change the password in gitea first: the chart resets it from the secret when the pod
+	// restarts, so should the secret update fail gitea goes back to the old password
+	if _, err = adminClient.AdminEditUser(adminUser, gitea.EditUserOption{
+		LoginName:          adminUser,
+		Password:           password,
+		MustChangePassword: &mustChange,
+	}); err != nil {
+		return nil, fmt.Errorf("could not change the gitea admin password: %v", err)
+	}
+
+	secret, err := getSecret(r.fullClient, GiteaAdminSecretName, GiteaNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gitea Admin Secret: %v", err)
+	}
+	secret.Data["password"] = []byte(password)
+	if _, err = r.fullClient.CoreV1().Secrets(GiteaNamespace).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("could not update gitea Admin Secret: %v", err)
+	}
+	log.Printf("Rotated the gitea admin password")
+
+	for _, name := range []string{GiteaMigrationSecretName, GiteaReaderSecretName} {
+		err = r.fullClient.CoreV1().Secrets(GiteaNamespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return nil, fmt.Errorf("could not delete secret %s: %v", name, err)
+		}
+	}
+	return newGiteaClient(r.fullClient, giteaRouteURL, gitea.SetBasicAuth(adminUser, password))
+}
+
+// ensureCredentialsSecret returns the data of the named secret in the gitea namespace,
+// creating it from generate() when it does not exist
+func (g *giteaGitServer) ensureCredentialsSecret(name string, generate func() (map[string][]byte, error)) (map[string][]byte, error) {
+	r := g.r
+	secret, err := getSecret(r.fullClient, name, GiteaNamespace)
+	if err == nil {
+		return secret.Data, nil
+	}
+	if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("could not get secret %s: %v", name, err)
+	}
+	data, err := generate()
+	if err != nil {
+		return nil, fmt.Errorf("could not create gitea credentials for %s: %v", name, err)
+	}
+	if _, err = r.fullClient.CoreV1().Secrets(GiteaNamespace).Create(context.Background(), newSecret(name, GiteaNamespace, data, nil), metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("could not create secret %s: %v", name, err)
+	}
+	return data, nil
+}
+
+// ensureGiteaUser creates an unprivileged gitea user, or resets its password when it exists
+func ensureGiteaUser(adminClient *gitea.Client, username, password string) error {
+	mustChange := false
+	_, response, err := adminClient.GetUserInfo(username)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		_, _, err = adminClient.AdminCreateUser(gitea.CreateUserOption{
+			LoginName:          username,
+			Username:           username,
+			Email:              username + "@noreply.localhost",
+			Password:           password,
+			MustChangePassword: &mustChange,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = adminClient.AdminEditUser(username, gitea.EditUserOption{
+		LoginName:          username,
+		Password:           password,
+		MustChangePassword: &mustChange,
+	})
+	return err
+}
+
+// recreateGiteaToken creates an access token for the user of client, replacing any previous
+// token with the same name since gitea only returns the token value on creation
+func recreateGiteaToken(client *gitea.Client, name string, scopes ...gitea.AccessTokenScope) (string, error) {
+	// A missing token is not an error
+	_, _ = client.DeleteAccessToken(name)
+	token, _, err := client.CreateAccessToken(gitea.CreateAccessTokenOption{Name: name, Scopes: scopes})
+	if err != nil {
+		return "", fmt.Errorf("could not create gitea token %s: %v", name, err)
+	}
+	return token.Token, nil
+}
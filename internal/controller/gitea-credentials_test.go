@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeGiteaUsers serves the gitea user and token endpoints and records the calls made
+type fakeGiteaUsers struct {
+	users    map[string]bool
+	tokens   int
+	calls    []string
+	versions int
+}
+
+func (f *fakeGiteaUsers) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	authUser, _, _ := r.BasicAuth()
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case path == "/version":
+		f.versions++
+		_, _ = w.Write([]byte(`{"version": "1.21.11"}`))
+	case len(parts) == 2 && parts[0] == "users" && r.Method == nethttp.MethodGet:
+		if !f.users[parts[1]] {
+			w.WriteHeader(nethttp.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(gitea.User{UserName: parts[1]})
+	case path == "/admin/users" && r.Method == nethttp.MethodPost:
+		var opt gitea.CreateUserOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		f.users[opt.Username] = true
+		f.calls = append(f.calls, "create-user "+opt.Username)
+		w.WriteHeader(nethttp.StatusCreated)
+		_ = json.NewEncoder(w).Encode(gitea.User{UserName: opt.Username})
+	case len(parts) == 3 && parts[0] == "admin" && r.Method == nethttp.MethodPatch:
+		var opt gitea.EditUserOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		f.calls = append(f.calls, "edit-user "+parts[2])
+		_ = json.NewEncoder(w).Encode(gitea.User{UserName: parts[2]})
+	case len(parts) == 4 && parts[2] == "tokens" && r.Method == nethttp.MethodDelete:
+		w.WriteHeader(nethttp.StatusNotFound)
+	case len(parts) == 3 && parts[2] == "tokens" && r.Method == nethttp.MethodPost:
+		var opt gitea.CreateAccessTokenOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		f.tokens++
+		f.calls = append(f.calls, fmt.Sprintf("token %s by %s %v", opt.Name, authUser, opt.Scopes))
+		w.WriteHeader(nethttp.StatusCreated)
+		_ = json.NewEncoder(w).Encode(gitea.AccessToken{Name: opt.Name, Token: fmt.Sprintf("token-%d", f.tokens)})
+	default:
+		w.WriteHeader(nethttp.StatusNotFound)
+	}
+}
+
+var _ = Describe("Gitea credentials", func() {
+	var fakeGitea *fakeGiteaUsers
+	var server *httptest.Server
+	var backend *giteaGitServer
+	var pattern *api.Pattern
+
+	BeforeEach(func() {
+		fakeGitea = &fakeGiteaUsers{users: map[string]bool{GiteaAdminUser: true}}
+		server = httptest.NewServer(fakeGitea)
+		adminSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: GiteaAdminSecretName, Namespace: GiteaNamespace},
+			Data:       map[string][]byte{"username": []byte(GiteaAdminUser), "password": []byte("admin-pass")},
+		}
+		backend = &giteaGitServer{r: &PatternReconciler{fullClient: kubeclient.NewSimpleClientset(adminSecret)}}
+		pattern = &api.Pattern{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	getSecretData := func(name string) map[string][]byte {
+		secret, err := backend.r.fullClient.CoreV1().Secrets(GiteaNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		return secret.Data
+	}
+
+	It("should create the scoped tokens once", func() {
+		credentials, err := backend.ensureCredentials(pattern, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials).To(Equal(&giteaCredentials{MigrationToken: "token-1", ReaderUser: GiteaReaderUser, ReaderToken: "token-2"}))
+		Expect(fakeGitea.calls).To(Equal([]string{
+			"token vp-operator-migration by gitea_admin [write:repository]",
+			"create-user vp_reader",
+			"token vp-argocd-read by vp_reader [read:repository]",
+		}))
+		Expect(getSecretData(GiteaReaderSecretName)).To(HaveKeyWithValue("password", []byte("token-2")))
+
+		// With the tokens in place the admin account is not needed
+		versions := fakeGitea.versions
+		_, err = backend.ensureCredentials(pattern, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeGitea.calls).To(HaveLen(3))
+		Expect(fakeGitea.versions).To(Equal(versions))
+		Expect(pattern.Status.GitServer).To(BeNil())
+	})
+
+	It("should rotate the admin password and the tokens on request", func() {
+		_, err := backend.ensureCredentials(pattern, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		fakeGitea.calls = nil
+
+		pattern.Annotations = map[string]string{api.RotateGitServerPasswordAnnotation: "1"}
+		credentials, err := backend.ensureCredentials(pattern, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials.MigrationToken).To(Equal("token-3"))
+		Expect(credentials.ReaderToken).To(Equal("token-4"))
+		Expect(fakeGitea.calls).To(Equal([]string{
+			"edit-user gitea_admin",
+			"token vp-operator-migration by gitea_admin [write:repository]",
+			"edit-user vp_reader",
+			"token vp-argocd-read by vp_reader [read:repository]",
+		}))
+		Expect(getSecretData(GiteaAdminSecretName)["password"]).ToNot(Equal([]byte("admin-pass")))
+		Expect(pattern.Status.GitServer.LastPasswordRotation).To(Equal("1"))
+
+		// The same request is only handled once
+		_, err = backend.ensureCredentials(pattern, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fakeGitea.calls).To(HaveLen(4))
+	})
+})
@@ -17,28 +17,46 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
 )
 
 type GiteaOperations interface {
-	MigrateGiteaRepo(fullClient kubernetes.Interface, username, password, upstreamURL, giteaServerRoute string) (success bool, repositoryURL string, err error)
+	MigrateGiteaRepo(fullClient kubernetes.Interface, token, upstreamURL, giteaServerRoute, mirrorInterval string) (success bool, repositoryURL string, err error)
 }
 
 type GiteaOperationsImpl struct{}
 
-// Function that creates a mirror repo in Gitea
-func (g *GiteaOperationsImpl) MigrateGiteaRepo(
-	fullClient kubernetes.Interface, username, password, upstreamURL, giteaServerRoute string) (success bool, repositoryURL string, err error) {
-	option := gitea.SetBasicAuth(username, password)
+// newGiteaClient returns a gitea api client, authenticated when gitea.SetBasicAuth or gitea.SetToken
+// are passed. Creating the client queries the server version, so it fails when the api is unreachable
+func newGiteaClient(fullClient kubernetes.Interface, giteaServerRoute string, options ...gitea.ClientOption) (*gitea.Client, error) {
 	httpClient := &http.Client{
 		Transport: getHTTPSTransport(fullClient),
 	}
+	return gitea.NewClient(giteaServerRoute, append(options, gitea.SetHTTPClient(httpClient))...)
+}
 
-	giteaClient, err := gitea.NewClient(giteaServerRoute, option, gitea.SetHTTPClient(httpClient))
+// Function that creates a copy of the upstream repo in Gitea. When mirrorInterval is set the
+// copy is a pull mirror which gitea keeps in sync, otherwise it is a regular repository that
+// can be edited in place.
+func (g *GiteaOperationsImpl) MigrateGiteaRepo(
+	fullClient kubernetes.Interface, token, upstreamURL, giteaServerRoute, mirrorInterval string) (success bool, repositoryURL string, err error) {
+	giteaClient, err := newGiteaClient(fullClient, giteaServerRoute, gitea.SetToken(token))
 	if err != nil {
 		return false, "", err
 	}
@@ -50,26 +68,591 @@ func (g *GiteaOperationsImpl) MigrateGiteaRepo(
 	repository, response, _ := giteaClient.GetRepo(GiteaAdminUser, repoName)
 
 	// Repo has been already migrated
-	if response.StatusCode == http.StatusOK {
+	if response != nil && response.StatusCode == http.StatusOK {
 		return true, repository.HTMLURL, nil
 	}
 
+	repository, err = migrateGiteaRepo(giteaClient, repoName, upstreamURL, mirrorInterval)
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, repository.HTMLURL, nil
+}
+
+func migrateGiteaRepo(giteaClient *gitea.Client, repoName, upstreamURL, mirrorInterval string) (*gitea.Repository, error) {
 	// Default description will include repo name and that it was created by
 	// the Validated Patterns operator.
 	descriptionFormat := "The [%s] repository was migrated by the Validated Patterns Operator."
 
 	description := fmt.Sprintf(descriptionFormat, repoName)
 
-	repository, _, err = giteaClient.MigrateRepo(gitea.MigrateRepoOption{
-		CloneAddr:   upstreamURL,
-		RepoOwner:   username,
-		RepoName:    repoName,
-		Mirror:      false, // We do not create a mirror because of https://www.github.com/go-gitea/gitea/issues/7609
-		Description: description,
+	// Mirroring is opt-in: a pull mirror cannot be edited in gitea, see
+	// https://www.github.com/go-gitea/gitea/issues/7609
+	repository, _, err := giteaClient.MigrateRepo(gitea.MigrateRepoOption{
+		CloneAddr:      upstreamURL,
+		RepoOwner:      GiteaAdminUser,
+		RepoName:       repoName,
+		Mirror:         mirrorInterval != "",
+		MirrorInterval: mirrorInterval,
+		Description:    description,
 	})
+	return repository, err
+}
+
+// giteaGitServer is the GitServerBackend deploying gitea through an argo application
+type giteaGitServer struct {
+	r                      *PatternReconciler
+	patternsOperatorConfig PatternsOperatorConfig
+}
+
+func newGiteaGitServer(r *PatternReconciler, patternsOperatorConfig PatternsOperatorConfig) GitServerBackend {
+	return &giteaGitServer{r: r, patternsOperatorConfig: patternsOperatorConfig}
+}
+
+func (g *giteaGitServer) Name() string {
+	return GiteaGitServerBackend
+}
+
+func (g *giteaGitServer) Provision(input *api.Pattern) error {
+	r := g.r
+	clusterWideNS := getClusterWideArgoNamespace()
+	// The reason we create the vp-gitea namespace and and the
+	// gitea-admin-secret is because otherwise it takes and extremely long time
+	// to reconcile everything because the reconcile loop will be waiting a long time
+	// for the namespace to show up and then the pod will take quite a while to retry
+	// with the gitea-admin-secret mounted into it
+	if !haveNamespace(r.Client, GiteaNamespace) {
+		err := createNamespace(r.fullClient, GiteaNamespace)
+		if err != nil {
+			return fmt.Errorf("error creating %s namespace: %v", GiteaNamespace, err)
+		}
+	}
+	var giteaAdminPassword string
+	giteaAdminPassword, err := GenerateRandomPassword(GiteaDefaultPasswordLen, DefaultRandRead)
 	if err != nil {
-		return false, "", err
+		return fmt.Errorf("error Generating gitea_admin password: %v", err)
 	}
 
-	return true, repository.HTMLURL, nil
+	secretData := map[string][]byte{
+		"username": []byte(GiteaAdminUser),
+		"password": []byte(giteaAdminPassword),
+	}
+	giteaAdminSecret := newSecret(GiteaAdminSecretName, GiteaNamespace, secretData, nil)
+	err = r.Create(context.Background(), giteaAdminSecret)
+	if err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create Gitea Admin Secret: %v", err)
+	}
+
+	status := getGitServerStatus(input)
+	status.ApplicationSynced = false
+	giteaApp := newArgoGiteaApplication(input, g.patternsOperatorConfig)
+	_ = controllerutil.SetOwnerReference(input, giteaApp, r.Scheme)
+	app, err := getApplication(r.argoClient, GiteaApplicationName, clusterWideNS)
+	if app == nil {
+		log.Printf("Gitea app not found: %s\n", err.Error())
+		log.Printf("In-cluster git server requested, creating gitea instance for: %s", gitServerUpstream(input))
+		err = createApplication(r.argoClient, giteaApp, clusterWideNS)
+		return fmt.Errorf("create gitea application: %v", err)
+	} else if ownedBySame(giteaApp, app) {
+		// Check values
+		changed, errApp := updateApplication(r.argoClient, giteaApp, app, clusterWideNS)
+		if changed {
+			if errApp != nil {
+				input.Status.Version = 1 + input.Status.Version
+			}
+			_ = DropLocalGitPaths()
+
+			return fmt.Errorf("updated gitea application: %v", errApp)
+		}
+	} else {
+		// Someone manually removed the owner ref
+		return fmt.Errorf("we no longer own Application %q", giteaApp.Name)
+	}
+	if !haveNamespace(r.Client, GiteaNamespace) {
+		return fmt.Errorf("waiting for giteanamespace creation")
+	}
+	// Not being synced is not fatal, the route and api checks tell whether gitea is usable
+	status.ApplicationSynced = app.Status.Sync.Status == argoapi.SyncStatusCodeSynced
+	return nil
+}
+
+func (g *giteaGitServer) ImportRepository(input *api.Pattern, upstreamURL string) (string, error) {
+	r := g.r
+	// Here we need to call the gitea migration bits
+	// Let's get the GiteaServer route
+	status := getGitServerStatus(input)
+	status.RouteAvailable, status.APIReachable, status.RepositoryMigrated = false, false, false
+	giteaRouteURL, routeErr := getRoute(r.routeClient, GiteaRouteName, GiteaNamespace)
+	if routeErr != nil {
+		return "", fmt.Errorf("GiteaServer route not ready: %v", routeErr)
+	}
+	status.RouteAvailable = true
+	if _, err := newGiteaClient(r.fullClient, giteaRouteURL); err != nil {
+		return "", fmt.Errorf("GiteaServer api not reachable: %v", err)
+	}
+	status.APIReachable = true
+
+	// Extract the repository name from the original target repo
+	upstreamRepoName, repoErr := extractRepositoryName(upstreamURL)
+	if repoErr != nil {
+		return "", fmt.Errorf("error getting target Repo URL: %v", repoErr)
+	}
+
+	giteaRepoURL := fmt.Sprintf("%s/%s/%s", giteaRouteURL, GiteaAdminUser, upstreamRepoName)
+	credentials, err := g.ensureCredentials(input, giteaRouteURL)
+	if err != nil {
+		return "", err
+	}
+	giteaClient, err := newGiteaClient(r.fullClient, giteaRouteURL, gitea.SetToken(credentials.MigrationToken))
+	if err != nil {
+		return "", err
+	}
+
+	// Let's attempt to migrate the repo to Gitea
+	repo, err := g.migrateRepository(giteaClient, credentials.MigrationToken, giteaRouteURL, upstreamRepoName, upstreamURL,
+		input.Spec.GitConfig.InClusterGitMirror.PullInterval, status)
+	if err != nil {
+		return "", err
+	}
+	if repo == nil {
+		if status.MigrationStartTime == nil {
+			return "", fmt.Errorf("importing %s into gitea", upstreamURL)
+		}
+		return "", fmt.Errorf("importing %s into gitea, started %s ago", upstreamURL,
+			time.Since(status.MigrationStartTime.Time).Truncate(time.Second))
+	}
+	status.RepositoryMigrated = true
+	status.RepositoryURL = giteaRepoURL
+
+	// Argo reads the repository as an unprivileged user, which also works for private repositories
+	if err = grantGiteaReadAccess(giteaClient, giteaRepoURL, repo, credentials); err != nil {
+		return "", err
+	}
+	return giteaRepoURL, nil
+}
+
+// giteaReadAccess remembers, keyed by the url of the gitea repository, which repository and reader
+// credentials the read access was granted for. A resync replaces the repository, and its collaborators
+// with it, so the repository is told apart by its id and creation time rather than by its name
+var (
+	giteaReadAccessMutex sync.Mutex
+	giteaReadAccess      = map[string]string{}
+)
+
+// grantGiteaReadAccess makes the reader user a read-only collaborator of repo, unless it was already
+// made one for the same repository and reader credentials
+func grantGiteaReadAccess(giteaClient *gitea.Client, repoURL string, repo *gitea.Repository, credentials *giteaCredentials) error {
+	credentialsSum := sha256.Sum256([]byte(credentials.ReaderUser + ":" + credentials.ReaderToken))
+	grant := fmt.Sprintf("%d/%s/%x", repo.ID, repo.Created.UTC().Format(time.RFC3339Nano), credentialsSum)
+	giteaReadAccessMutex.Lock()
+	granted := giteaReadAccess[repoURL] == grant
+	giteaReadAccessMutex.Unlock()
+	if granted {
+		return nil
+	}
+
+	readAccess := gitea.AccessModeRead
+	if _, err := giteaClient.AddCollaborator(GiteaAdminUser, repo.Name, credentials.ReaderUser,
+		gitea.AddCollaboratorOption{Permission: &readAccess}); err != nil {
+		return fmt.Errorf("could not grant %s read access to %s: %v", credentials.ReaderUser, repo.Name, err)
+	}
+	giteaReadAccessMutex.Lock()
+	giteaReadAccess[repoURL] = grant
+	giteaReadAccessMutex.Unlock()
+	return nil
+}
+
+// giteaMigration is a repository import running in the background
+type giteaMigration struct {
+	started time.Time
+	done    bool
+	err     error
+}
+
+// giteaMigrations tracks the running imports, keyed by the url of the gitea repository.
+// Gitea imports the repository while serving the api call, which can take longer than we
+// want to block the reconcile loop for
+var (
+	giteaMigrationsMutex sync.Mutex
+	giteaMigrations      = map[string]*giteaMigration{}
+)
+
+// giteaMigrationTracked returns true when an import is tracked under key
+func giteaMigrationTracked(key string) bool {
+	_, found := giteaMigrationStarted(key)
+	return found
+}
+
+// giteaMigrationStarted returns when the import tracked under key started, false when there is none
+func giteaMigrationStarted(key string) (time.Time, bool) {
+	giteaMigrationsMutex.Lock()
+	defer giteaMigrationsMutex.Unlock()
+	migration, found := giteaMigrations[key]
+	if !found {
+		return time.Time{}, false
+	}
+	return migration.started, true
+}
+
+// trackGiteaMigration runs migrate in the background, once per key. It returns true along with
+// the outcome of migrate once it finished, and then forgets about it so that failed imports are
+// retried on the next call
+func trackGiteaMigration(key string, migrate func() error) (bool, error) {
+	giteaMigrationsMutex.Lock()
+	defer giteaMigrationsMutex.Unlock()
+
+	migration, found := giteaMigrations[key]
+	if !found {
+		migration = &giteaMigration{started: time.Now().Truncate(time.Second)}
+		giteaMigrations[key] = migration
+		go func() {
+			err := migrate()
+			giteaMigrationsMutex.Lock()
+			defer giteaMigrationsMutex.Unlock()
+			migration.done, migration.err = true, err
+		}()
+		return false, nil
+	}
+	if !migration.done {
+		return false, nil
+	}
+	delete(giteaMigrations, key)
+	return true, migration.err
+}
+
+// migrateRepository imports upstreamURL into gitea in the background and tracks the progress
+// in status. It returns the repository once it has been imported, nil until then
+func (g *giteaGitServer) migrateRepository(giteaClient *gitea.Client, token, giteaRouteURL, repoName, upstreamURL, mirrorInterval string,
+	status *api.GitServerStatus) (*gitea.Repository, error) {
+	key := fmt.Sprintf("%s/%s/%s", giteaRouteURL, GiteaAdminUser, repoName)
+	if !giteaMigrationTracked(key) {
+		// Gitea creates the repository empty and fills it once the clone is done
+		if repo, _, err := giteaClient.GetRepo(GiteaAdminUser, repoName); err == nil && !repo.Empty {
+			status.MigrationPhase = api.GitServerMigrationSucceeded
+			return repo, nil
+		}
+		log.Printf("Importing %s into gitea", upstreamURL)
+	}
+	done, err := trackGiteaMigration(key, func() error {
+		_, _, err := g.r.giteaOperations.MigrateGiteaRepo(g.r.fullClient, token, upstreamURL, giteaRouteURL, mirrorInterval)
+		return err
+	})
+	if !done {
+		// Taken from the tracker every time, the status of the pass that started the import may
+		// never have been stored
+		if started, found := giteaMigrationStarted(key); found {
+			startTime := metav1.NewTime(started)
+			status.MigrationStartTime = &startTime
+			status.MigrationPhase = api.GitServerMigrationRunning
+		}
+		return nil, nil
+	}
+	if err != nil {
+		status.MigrationPhase = api.GitServerMigrationFailed
+		return nil, fmt.Errorf("GiteaServer Migrate Repository Error: %v", err)
+	}
+	repo, _, err := giteaClient.GetRepo(GiteaAdminUser, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the imported repository %s: %v", repoName, err)
+	}
+	status.MigrationPhase = api.GitServerMigrationSucceeded
+	return repo, nil
+}
+
+// resyncGiteaRepository imports upstreamURL again next to repoName, in the background, and swaps
+// the new copy in once the import succeeded. A failed import leaves repoName as it was. It returns
+// true once repoName has been replaced
+func resyncGiteaRepository(giteaClient *gitea.Client, repoName, upstreamURL, mirrorInterval string) (bool, error) {
+	newName := repoName + GiteaResyncSuffix
+	done, err := trackGiteaMigration("resync/"+GiteaAdminUser+"/"+newName, func() error {
+		// Left behind by an interrupted resync
+		if resp, err := giteaClient.DeleteRepo(GiteaAdminUser, newName); err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			return fmt.Errorf("could not delete repository %s: %v", newName, err)
+		}
+		_, err := migrateGiteaRepo(giteaClient, newName, upstreamURL, mirrorInterval)
+		return err
+	})
+	if !done {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	oldName := repoName + GiteaReplacedSuffix
+	if err = renameGiteaRepo(giteaClient, repoName, oldName); err != nil {
+		return false, err
+	}
+	if err = renameGiteaRepo(giteaClient, newName, repoName); err != nil {
+		if restoreErr := renameGiteaRepo(giteaClient, oldName, repoName); restoreErr != nil {
+			log.Printf("Could not restore repository %s: %v", repoName, restoreErr)
+		}
+		return false, err
+	}
+	if _, err = giteaClient.DeleteRepo(GiteaAdminUser, oldName); err != nil {
+		log.Printf("Could not delete the replaced repository %s: %v", oldName, err)
+	}
+	return true, nil
+}
+
+func renameGiteaRepo(giteaClient *gitea.Client, from, to string) error {
+	if _, _, err := giteaClient.EditRepo(GiteaAdminUser, from, gitea.EditRepoOption{Name: &to}); err != nil {
+		return fmt.Errorf("could not rename repository %s to %s: %v", from, to, err)
+	}
+	return nil
+}
+
+// How often the origin repository of the in-cluster git server is asked for its latest commit
+const GitServerOriginCheckInterval = 5 * time.Minute
+
+// originCommitCheck is the result of the last lookup of a revision of an origin repository
+type originCommitCheck struct {
+	commit    string
+	err       error
+	checkedAt time.Time
+}
+
+// originCommits caches the latest commit of the origin repositories, keyed by url and revision,
+// so that they are not queried on every reconcile
+var (
+	originCommitsMutex sync.Mutex
+	originCommits      = map[string]originCommitCheck{}
+)
+
+// getOriginCommit returns the commit revision points to in upstreamURL, asking the repository at most
+// every GitServerOriginCheckInterval. gitAuth holds the credentials of the pattern repository, if any
+func getOriginCommit(fullClient kubernetes.Interface, upstreamURL, revision string, gitAuth map[string][]byte) (string, error) {
+	key := upstreamURL + "#" + revision
+	originCommitsMutex.Lock()
+	check, found := originCommits[key]
+	originCommitsMutex.Unlock()
+	if found && time.Since(check.checkedAt) < GitServerOriginCheckInterval {
+		return check.commit, check.err
+	}
+
+	commit, err := getRemoteCommit(fullClient, upstreamURL, revision, gitAuth)
+	originCommitsMutex.Lock()
+	originCommits[key] = originCommitCheck{commit: commit, err: err, checkedAt: time.Now()}
+	originCommitsMutex.Unlock()
+	return commit, err
+}
+
+func (g *giteaGitServer) SyncRepository(input *api.Pattern, upstreamURL string) error {
+	r := g.r
+	giteaRouteURL, err := getRoute(r.routeClient, GiteaRouteName, GiteaNamespace)
+	if err != nil {
+		return fmt.Errorf("GiteaServer route not ready: %v", err)
+	}
+	secret, err := getSecret(r.fullClient, GiteaMigrationSecretName, GiteaNamespace)
+	if err != nil {
+		return fmt.Errorf("error getting gitea migration Secret: %v", err)
+	}
+	giteaClient, err := newGiteaClient(r.fullClient, giteaRouteURL, gitea.SetToken(string(secret.Data["token"])))
+	if err != nil {
+		return err
+	}
+	var gitAuth map[string][]byte
+	if input.Spec.GitConfig.TokenSecret != "" {
+		if gitAuth, err = r.authGitFromSecret(input.Spec.GitConfig.TokenSecretNamespace, input.Spec.GitConfig.TokenSecret); err != nil {
+			return err
+		}
+	}
+	return syncGiteaRepository(r.fullClient, giteaClient, input, upstreamURL, gitAuth)
+}
+
+// RepositoryCredentials returns the read-only credentials of the argo repository secret
+func (g *giteaGitServer) RepositoryCredentials(input *api.Pattern) (map[string][]byte, error) {
+	secret, err := getSecret(g.r.fullClient, GiteaReaderSecretName, GiteaNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting gitea reader Secret: %v", err)
+	}
+	return map[string][]byte{
+		"type":     []byte("git"),
+		"url":      []byte(getTargetRepo(input)),
+		"username": secret.Data["username"],
+		"password": secret.Data["password"],
+	}, nil
+}
+
+// giteaRevisionCommit returns the commit revision, a branch, a tag or a commit sha, points to in repoName
+func giteaRevisionCommit(giteaClient *gitea.Client, repoName, revision string) (string, error) {
+	branch, resp, err := giteaClient.GetRepoBranch(GiteaAdminUser, repoName, revision)
+	if err == nil {
+		if branch.Commit == nil {
+			return "", nil
+		}
+		return branch.Commit.ID, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("could not get branch %s of %s: %v", revision, repoName, err)
+	}
+	if tag, _, err := giteaClient.GetTag(GiteaAdminUser, repoName, revision); err == nil && tag.Commit != nil {
+		return tag.Commit.SHA, nil
+	}
+	commit, _, err := giteaClient.GetSingleCommit(GiteaAdminUser, repoName, revision)
+	if err != nil {
+		return "", fmt.Errorf("could not find revision %s in %s: %v", revision, repoName, err)
+	}
+	return commit.SHA, nil
+}
+
+// syncGiteaRepository applies the InClusterGitMirror settings to the gitea copy of upstreamURL,
+// handles resync requests and records how the copy relates to the upstream repository in
+// input.Status.GitServer. gitAuth holds the credentials of upstreamURL, if any
+func syncGiteaRepository(fullClient kubernetes.Interface, giteaClient *gitea.Client, input *api.Pattern, upstreamURL string,
+	gitAuth map[string][]byte) error {
+	mirror := input.Spec.GitConfig.InClusterGitMirror
+	repoName, err := extractRepositoryName(upstreamURL)
+	if err != nil {
+		return err
+	}
+	repo, _, err := giteaClient.GetRepo(GiteaAdminUser, repoName)
+	if err != nil {
+		return fmt.Errorf("could not get repository %s: %v", repoName, err)
+	}
+
+	status := getGitServerStatus(input)
+	status.Mirror = repo.Mirror
+	var messages []string
+
+	switch {
+	case repo.Mirror && mirror.PullInterval != "" && repo.MirrorInterval != mirror.PullInterval:
+		if _, _, err = giteaClient.EditRepo(GiteaAdminUser, repoName, gitea.EditRepoOption{MirrorInterval: &mirror.PullInterval}); err != nil {
+			return fmt.Errorf("could not update the mirror interval of %s: %v", repoName, err)
+		}
+	case repo.Mirror && mirror.PullInterval == "":
+		messages = append(messages, "the repository is a pull mirror and cannot be edited, convert it to a regular repository in gitea")
+	case !repo.Mirror && mirror.PullInterval != "":
+		messages = append(messages, fmt.Sprintf("the repository is not a pull mirror, set the %s annotation to import it again as one", api.ResyncMirrorAnnotation))
+	}
+
+	revision := input.Spec.GitConfig.TargetRevision
+	if revision == "" || revision == GitHEAD {
+		revision = repo.DefaultBranch
+	}
+	if status.Commit, err = giteaRevisionCommit(giteaClient, repoName, revision); err != nil {
+		return err
+	}
+	originCommit, err := getOriginCommit(fullClient, upstreamURL, revision, gitAuth)
+	if err != nil {
+		messages = append(messages, fmt.Sprintf("could not read %s from %s: %v", revision, upstreamURL, err))
+	} else {
+		status.OriginCommit = originCommit
+	}
+
+	// A mirror is in sync with whatever gitea fetched last. A regular repository was in sync
+	// when it was imported, which is when gitea created it. It is the first commit we see.
+	if repo.Mirror {
+		status.LastSyncCommit = status.Commit
+		if !repo.MirrorUpdated.IsZero() {
+			syncTime := metav1.NewTime(repo.MirrorUpdated.Truncate(time.Second))
+			status.LastSyncTime = &syncTime
+		}
+	} else if status.LastSyncCommit == "" {
+		status.LastSyncCommit = status.Commit
+		status.LastSyncTime = nil
+		if !repo.Created.IsZero() {
+			syncTime := metav1.NewTime(repo.Created.Truncate(time.Second))
+			status.LastSyncTime = &syncTime
+		}
+	}
+	localChanges := !repo.Mirror && status.Commit != status.LastSyncCommit
+	originMoved := status.OriginCommit != "" && status.OriginCommit != status.LastSyncCommit
+	status.Diverged = localChanges && originMoved
+
+	if request := input.Annotations[api.ResyncMirrorAnnotation]; request != "" && request != status.LastResyncRequest {
+		switch {
+		case repo.Mirror:
+			status.LastResyncRequest = request
+			log.Printf("Resync of the in-cluster mirror of %s requested", upstreamURL)
+			if _, err = giteaClient.MirrorSync(GiteaAdminUser, repoName); err != nil {
+				return fmt.Errorf("could not sync mirror %s: %v", repoName, err)
+			}
+		case localChanges:
+			status.LastResyncRequest = request
+			messages = append(messages, "resync refused, the repository has local changes that would be lost")
+		default:
+			// Nothing would be lost, so import the repository again with the current settings. The
+			// request stays pending until the new copy replaced the current one
+			if !giteaMigrationTracked("resync/" + GiteaAdminUser + "/" + repoName + GiteaResyncSuffix) {
+				log.Printf("Resync of the in-cluster copy of %s requested, importing it again", upstreamURL)
+			}
+			done, resyncErr := resyncGiteaRepository(giteaClient, repoName, upstreamURL, mirror.PullInterval)
+			if resyncErr != nil {
+				status.LastResyncRequest = request
+				return fmt.Errorf("could not import %s again, the in-cluster copy was left as it was: %v", upstreamURL, resyncErr)
+			}
+			if !done {
+				status.Message = fmt.Sprintf("resync running, importing %s again", upstreamURL)
+				return nil
+			}
+			status.LastResyncRequest = request
+			status.LastSyncCommit = ""
+			status.LastSyncTime = nil
+			return nil
+		}
+	}
+
+	if mirror.PushRepo != "" {
+		msg, pushErr := ensureGiteaPushMirror(fullClient, giteaClient, repoName, mirror)
+		if pushErr != nil {
+			return pushErr
+		}
+		if msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+
+	switch {
+	case status.Diverged:
+		messages = append(messages, "the repository has local changes and the origin repository has new commits")
+	case localChanges:
+		messages = append(messages, "the repository has local changes")
+	case originMoved && repo.Mirror:
+		messages = append(messages, "the origin repository has new commits, waiting for the next mirror sync")
+	case originMoved:
+		messages = append(messages, fmt.Sprintf("the origin repository has new commits, set the %s annotation to import them", api.ResyncMirrorAnnotation))
+	}
+	status.Message = strings.Join(messages, "; ")
+	return nil
+}
+
+// ensureGiteaPushMirror makes sure gitea pushes the repository to mirror.PushRepo. It returns
+// the last push error reported by gitea, if any
+func ensureGiteaPushMirror(fullClient kubernetes.Interface, giteaClient *gitea.Client, repoName string, mirror api.InClusterGitMirror) (string, error) {
+	pushMirrors, _, err := giteaClient.ListPushMirrors(GiteaAdminUser, repoName, gitea.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not list the push mirrors of %s: %v", repoName, err)
+	}
+	for _, m := range pushMirrors {
+		if m.RemoteAddress == mirror.PushRepo {
+			if m.LastError != "" {
+				return fmt.Sprintf("push mirror to %s failed: %s", mirror.PushRepo, m.LastError), nil
+			}
+			return "", nil
+		}
+	}
+
+	opt := gitea.CreatePushMirrorOption{
+		Interval:      mirror.PushInterval,
+		RemoteAddress: mirror.PushRepo,
+		SyncONCommit:  true,
+	}
+	if opt.Interval == "" {
+		opt.Interval = GiteaDefaultPushMirrorInterval
+	}
+	if mirror.PushSecret != "" {
+		secret, err := getSecret(fullClient, mirror.PushSecret, mirror.PushSecretNamespace)
+		if err != nil {
+			return "", fmt.Errorf("could not get push mirror secret %s/%s: %v", mirror.PushSecretNamespace, mirror.PushSecret, err)
+		}
+		opt.RemoteUsername = string(secret.Data["username"])
+		opt.RemotePassword = string(secret.Data["password"])
+	}
+	log.Printf("Adding push mirror of %s to %s", repoName, mirror.PushRepo)
+	if _, _, err = giteaClient.PushMirrors(GiteaAdminUser, repoName, opt); err != nil {
+		return "", fmt.Errorf("could not add push mirror to %s: %v", mirror.PushRepo, err)
+	}
+	return "", nil
 }
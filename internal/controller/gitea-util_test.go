@@ -1,13 +1,28 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"code.gitea.io/sdk/gitea"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	gomock "go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -19,8 +34,7 @@ var _ = Describe("MigrateGiteaRepo", func() {
 		giteaServer      *httptest.Server
 		giteaServerRoute string
 		giteaOperations  GiteaOperations
-		username         string
-		password         string
+		token            string
 		upstreamURL      string
 		repoName         string
 	)
@@ -34,8 +48,7 @@ var _ = Describe("MigrateGiteaRepo", func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockKubeClient = fake.NewSimpleClientset()
 		giteaOperations = &GiteaOperationsImpl{}
-		username = "user"
-		password = "pass"
+		token = "token"
 		upstreamURL = "https://github.com/example/repo.git"
 		repoName = "repo"
 
@@ -66,7 +79,7 @@ var _ = Describe("MigrateGiteaRepo", func() {
 
 	Context("when the repository does not exist", func() {
 		It("should migrate the repository successfully", func() {
-			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, username, password, upstreamURL, giteaServerRoute)
+			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, token, upstreamURL, giteaServerRoute, "")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(success).To(BeTrue())
 			Expect(repositoryURL).To(Equal("https://gitea.example.com/user/repo"))
@@ -98,7 +111,7 @@ var _ = Describe("MigrateGiteaRepo", func() {
 		})
 
 		It("should not migrate the repository and return the existing repository URL", func() {
-			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, username, password, upstreamURL, giteaServerRoute)
+			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, token, upstreamURL, giteaServerRoute, "")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(success).To(BeTrue())
 			Expect(repositoryURL).To(Equal("https://gitea.example.com/user/repo"))
@@ -109,7 +122,7 @@ var _ = Describe("MigrateGiteaRepo", func() {
 		It("should return an error", func() {
 			// Use an invalid Gitea server route to simulate client creation failure
 			invalidRoute := "http://invalid-url"
-			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, username, password, upstreamURL, invalidRoute)
+			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, token, upstreamURL, invalidRoute, "")
 			Expect(err).To(HaveOccurred())
 			Expect(success).To(BeFalse())
 			Expect(repositoryURL).To(BeEmpty())
@@ -129,10 +142,429 @@ var _ = Describe("MigrateGiteaRepo", func() {
 		})
 
 		It("should return an error", func() {
-			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, username, password, upstreamURL, giteaServerRoute)
+			success, repositoryURL, err := giteaOperations.MigrateGiteaRepo(mockKubeClient, token, upstreamURL, giteaServerRoute, "")
 			Expect(err).To(HaveOccurred())
 			Expect(success).To(BeFalse())
 			Expect(repositoryURL).To(BeEmpty())
 		})
 	})
 })
+
+// fakeGiteaRepo serves the gitea api endpoints used to keep a single repository in sync
+type fakeGiteaRepo struct {
+	mu            sync.Mutex
+	repo          gitea.Repository
+	missing       bool
+	commit        string
+	calls         []string
+	pushMirrors   []*gitea.PushMirrorResponse
+	migrateFailed bool
+	// Whether the repositories imported next to repo are mirrors
+	imported map[string]bool
+	// Commits of the tags of repo
+	tags map[string]string
+}
+
+func (f *fakeGiteaRepo) getCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *fakeGiteaRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reposPrefix := "/api/v1/repos/" + GiteaAdminUser + "/"
+	name, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, reposPrefix), "/")
+	if path != "" {
+		path = "/" + path
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/api/v1/version":
+		_, _ = w.Write([]byte(`{"version": "1.21.11"}`))
+	case r.URL.Path == "/api/v1/repos/migrate":
+		var opt gitea.MigrateRepoOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		f.calls = append(f.calls, fmt.Sprintf("migrate %s mirror=%t", opt.RepoName, opt.Mirror))
+		if f.migrateFailed {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if opt.RepoName == f.repo.Name {
+			f.repo.Mirror = opt.Mirror
+		} else {
+			if f.imported == nil {
+				f.imported = map[string]bool{}
+			}
+			f.imported[opt.RepoName] = opt.Mirror
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(f.repo)
+	case !strings.HasPrefix(r.URL.Path, reposPrefix):
+		w.WriteHeader(http.StatusNotFound)
+	case path == "" && r.Method == http.MethodDelete:
+		f.calls = append(f.calls, "delete "+name)
+		w.WriteHeader(http.StatusNoContent)
+	case path == "" && r.Method == http.MethodPatch:
+		var opt gitea.EditRepoOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		if opt.Name != nil {
+			f.calls = append(f.calls, fmt.Sprintf("rename %s to %s", name, *opt.Name))
+			if mirror, ok := f.imported[name]; ok && *opt.Name == f.repo.Name {
+				f.repo.Mirror = mirror
+			}
+		} else {
+			f.calls = append(f.calls, "edit interval="+*opt.MirrorInterval)
+		}
+		_ = json.NewEncoder(w).Encode(f.repo)
+	case name != f.repo.Name:
+		w.WriteHeader(http.StatusNotFound)
+	case path == "" && r.Method == http.MethodGet && f.missing:
+		w.WriteHeader(http.StatusNotFound)
+	case path == "" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.repo)
+	case path == "/branches/"+f.repo.DefaultBranch:
+		_ = json.NewEncoder(w).Encode(gitea.Branch{Name: f.repo.DefaultBranch, Commit: &gitea.PayloadCommit{ID: f.commit}})
+	case strings.HasPrefix(path, "/tags/") && f.tags[strings.TrimPrefix(path, "/tags/")] != "":
+		tag := strings.TrimPrefix(path, "/tags/")
+		_ = json.NewEncoder(w).Encode(gitea.Tag{Name: tag, Commit: &gitea.CommitMeta{SHA: f.tags[tag]}})
+	case path == "/git/commits/"+f.commit:
+		_ = json.NewEncoder(w).Encode(gitea.Commit{CommitMeta: &gitea.CommitMeta{SHA: f.commit}})
+	case strings.HasPrefix(path, "/collaborators/") && r.Method == http.MethodPut:
+		f.calls = append(f.calls, "collaborator "+strings.TrimPrefix(path, "/collaborators/"))
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/mirror-sync":
+		f.calls = append(f.calls, "mirror-sync")
+	case path == "/push_mirrors" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.pushMirrors)
+	case path == "/push_mirrors" && r.Method == http.MethodPost:
+		var opt gitea.CreatePushMirrorOption
+		_ = json.NewDecoder(r.Body).Decode(&opt)
+		f.calls = append(f.calls, fmt.Sprintf("push-mirror %s %s %s", opt.RemoteAddress, opt.RemoteUsername, opt.Interval))
+		_ = json.NewEncoder(w).Encode(gitea.PushMirrorResponse{RemoteAddress: opt.RemoteAddress})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("syncGiteaRepository", func() {
+	var (
+		fakeGitea   *fakeGiteaRepo
+		giteaServer *httptest.Server
+		giteaClient *gitea.Client
+		kubeClient  *fake.Clientset
+		origin      *git.Repository
+		originURL   string
+		originHead  string
+		pattern     *api.Pattern
+	)
+
+	BeforeEach(func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "repo")
+		var err error
+		origin, err = git.PlainInit(dir, false)
+		Expect(err).ToNot(HaveOccurred())
+		hash, err := createTestCommit(origin, "main", "Initial commit")
+		Expect(err).ToNot(HaveOccurred())
+		originHead = hash.String()
+		originURL = "file://" + dir
+
+		fakeGitea = &fakeGiteaRepo{
+			repo:   gitea.Repository{Name: "repo", DefaultBranch: "main", Created: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+			commit: originHead,
+		}
+		giteaServer = httptest.NewServer(fakeGitea)
+		kubeClient = fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "push-creds", Namespace: "pattern-ns"},
+			Data:       map[string][]byte{"username": []byte("pusher"), "password": []byte("secret")},
+		})
+		giteaClient, err = newGiteaClient(kubeClient, giteaServer.URL, gitea.SetToken("token"))
+		Expect(err).ToNot(HaveOccurred())
+
+		pattern = &api.Pattern{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"},
+			Spec: api.PatternSpec{
+				GitConfig: api.GitConfig{OriginRepo: originURL, TargetRevision: "main"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		giteaServer.Close()
+		originCommits = map[string]originCommitCheck{}
+		giteaMigrations = map[string]*giteaMigration{}
+	})
+
+	It("should record the imported commit of a regular repository", func() {
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		status := pattern.Status.GitServer
+		Expect(status.Mirror).To(BeFalse())
+		Expect(status.Commit).To(Equal(originHead))
+		Expect(status.OriginCommit).To(Equal(originHead))
+		Expect(status.LastSyncCommit).To(Equal(originHead))
+		Expect(status.LastSyncTime.Time).To(BeTemporally("==", fakeGitea.repo.Created))
+		Expect(status.Diverged).To(BeFalse())
+		Expect(status.Message).To(BeEmpty())
+	})
+
+	It("should follow a tag or a commit sha", func() {
+		_, err := origin.CreateTag("v1.0", plumbing.NewHash(originHead), &git.CreateTagOptions{
+			Message: "Release",
+			Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		fakeGitea.tags = map[string]string{"v1.0": originHead}
+		pattern.Spec.GitConfig.TargetRevision = "v1.0"
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Commit).To(Equal(originHead))
+		Expect(pattern.Status.GitServer.OriginCommit).To(Equal(originHead))
+		Expect(pattern.Status.GitServer.Message).To(BeEmpty())
+
+		pattern.Spec.GitConfig.TargetRevision = originHead
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Commit).To(Equal(originHead))
+		Expect(pattern.Status.GitServer.OriginCommit).To(Equal(originHead))
+
+		pattern.Spec.GitConfig.TargetRevision = "missing"
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(MatchError(ContainSubstring("could not find revision missing")))
+	})
+
+	It("should detect divergence", func() {
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		fakeGitea.commit = "local-change"
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Diverged).To(BeFalse())
+		Expect(pattern.Status.GitServer.Message).To(ContainSubstring("local changes"))
+
+		_, err := createTestCommit(origin, "main", "Upstream commit")
+		Expect(err).ToNot(HaveOccurred())
+		// The origin repository is only asked again once GitServerOriginCheckInterval passed
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Diverged).To(BeFalse())
+		originCommits = map[string]originCommitCheck{}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Diverged).To(BeTrue())
+	})
+
+	It("should refuse to resync over local changes", func() {
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		fakeGitea.commit = "local-change"
+		pattern.Annotations = map[string]string{api.ResyncMirrorAnnotation: "1"}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(fakeGitea.calls).To(BeEmpty())
+		Expect(pattern.Status.GitServer.LastResyncRequest).To(Equal("1"))
+		Expect(pattern.Status.GitServer.Message).To(ContainSubstring("resync refused"))
+	})
+
+	It("should import the repository again as a mirror on resync", func() {
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		pattern.Spec.GitConfig.InClusterGitMirror.PullInterval = "10m0s"
+		pattern.Annotations = map[string]string{api.ResyncMirrorAnnotation: "1"}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(pattern.Status.GitServer.Message).To(ContainSubstring("resync running"))
+		Expect(pattern.Status.GitServer.LastResyncRequest).To(BeEmpty())
+
+		// The new copy replaces the current one once imported
+		Eventually(fakeGitea.getCalls).Should(HaveLen(2))
+		Eventually(func() string {
+			Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+			return pattern.Status.GitServer.LastResyncRequest
+		}).Should(Equal("1"))
+		Expect(fakeGitea.getCalls()).To(Equal([]string{
+			"delete repo-resync",
+			"migrate repo-resync mirror=true",
+			"rename repo to repo-replaced",
+			"rename repo-resync to repo",
+			"delete repo-replaced",
+		}))
+		Expect(pattern.Status.GitServer.LastSyncCommit).To(BeEmpty())
+		Expect(pattern.Status.GitServer.LastSyncTime).To(BeNil())
+
+		// The same request is only handled once
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(fakeGitea.getCalls()).To(HaveLen(6))
+		Expect(fakeGitea.getCalls()[5]).To(Equal("edit interval=10m0s"))
+	})
+
+	It("should leave the repository alone when the resync fails", func() {
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		fakeGitea.migrateFailed = true
+		pattern.Annotations = map[string]string{api.ResyncMirrorAnnotation: "1"}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Eventually(func() error {
+			return syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)
+		}).Should(MatchError(ContainSubstring("left as it was")))
+		Expect(fakeGitea.getCalls()).To(Equal([]string{"delete repo-resync", "migrate repo-resync mirror=false"}))
+		Expect(pattern.Status.GitServer.LastResyncRequest).To(Equal("1"))
+		Expect(pattern.Status.GitServer.LastSyncCommit).To(Equal(originHead))
+	})
+
+	It("should track the mirror sync and trigger it on request", func() {
+		fakeGitea.repo.Mirror = true
+		fakeGitea.repo.MirrorInterval = "8h0m0s"
+		fakeGitea.repo.MirrorUpdated = time.Date(2024, 5, 1, 10, 0, 0, 500, time.UTC)
+		pattern.Spec.GitConfig.InClusterGitMirror.PullInterval = "8h0m0s"
+		pattern.Annotations = map[string]string{api.ResyncMirrorAnnotation: "now"}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(fakeGitea.calls).To(Equal([]string{"mirror-sync"}))
+		status := pattern.Status.GitServer
+		Expect(status.Mirror).To(BeTrue())
+		Expect(status.LastSyncTime.Time).To(BeTemporally("==", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+		Expect(status.LastSyncCommit).To(Equal(originHead))
+	})
+
+	It("should add the push mirror once", func() {
+		pattern.Spec.GitConfig.InClusterGitMirror = api.InClusterGitMirror{
+			PushRepo:            "https://github.com/example/backup.git",
+			PushSecret:          "push-creds",
+			PushSecretNamespace: "pattern-ns",
+		}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(fakeGitea.calls).To(Equal([]string{"push-mirror https://github.com/example/backup.git pusher 8h0m0s"}))
+
+		fakeGitea.pushMirrors = []*gitea.PushMirrorResponse{{RemoteAddress: "https://github.com/example/backup.git", LastError: "denied"}}
+		Expect(syncGiteaRepository(kubeClient, giteaClient, pattern, originURL, nil)).To(Succeed())
+		Expect(fakeGitea.calls).To(HaveLen(1))
+		Expect(pattern.Status.GitServer.Message).To(ContainSubstring("denied"))
+	})
+})
+
+// blockingGiteaOperations imports repositories once release is closed
+type blockingGiteaOperations struct {
+	release chan struct{}
+	err     error
+	calls   int
+}
+
+func (b *blockingGiteaOperations) MigrateGiteaRepo(_ kubernetes.Interface, _, _, _, _ string) (bool, string, error) {
+	b.calls++
+	<-b.release
+	return b.err == nil, "", b.err
+}
+
+var _ = Describe("migrateRepository", func() {
+	var (
+		fakeGitea   *fakeGiteaRepo
+		giteaServer *httptest.Server
+		giteaClient *gitea.Client
+		operations  *blockingGiteaOperations
+		backend     *giteaGitServer
+		status      *api.GitServerStatus
+	)
+
+	BeforeEach(func() {
+		fakeGitea = &fakeGiteaRepo{repo: gitea.Repository{Name: "repo"}, missing: true}
+		giteaServer = httptest.NewServer(fakeGitea)
+		var err error
+		giteaClient, err = newGiteaClient(nil, giteaServer.URL, gitea.SetToken("token"))
+		Expect(err).ToNot(HaveOccurred())
+		operations = &blockingGiteaOperations{release: make(chan struct{})}
+		backend = &giteaGitServer{r: &PatternReconciler{giteaOperations: operations}}
+		status = &api.GitServerStatus{}
+	})
+
+	AfterEach(func() {
+		giteaServer.Close()
+		giteaMigrations = map[string]*giteaMigration{}
+	})
+
+	migrate := func() (bool, error) {
+		repo, err := backend.migrateRepository(giteaClient, "token", giteaServer.URL, "repo", "https://github.com/example/repo.git", "", status)
+		return repo != nil, err
+	}
+
+	It("should import in the background and report progress", func() {
+		Expect(migrate()).To(BeFalse())
+		Expect(status.MigrationPhase).To(Equal(api.GitServerMigrationRunning))
+		Expect(status.MigrationStartTime).ToNot(BeNil())
+		Expect(migrate()).To(BeFalse())
+
+		// The import creates the repository
+		fakeGitea.mu.Lock()
+		fakeGitea.missing = false
+		fakeGitea.mu.Unlock()
+		close(operations.release)
+		Eventually(migrate).Should(BeTrue())
+		Expect(status.MigrationPhase).To(Equal(api.GitServerMigrationSucceeded))
+		Expect(operations.calls).To(Equal(1))
+	})
+
+	It("should report the start time when the status of the first pass was lost", func() {
+		Expect(migrate()).To(BeFalse())
+		started := status.MigrationStartTime
+		Expect(started).ToNot(BeNil())
+
+		*status = api.GitServerStatus{}
+		Expect(migrate()).To(BeFalse())
+		Expect(status.MigrationPhase).To(Equal(api.GitServerMigrationRunning))
+		Expect(status.MigrationStartTime).To(Equal(started))
+		close(operations.release)
+	})
+
+	It("should report failures and retry", func() {
+		operations.err = fmt.Errorf("clone failed")
+		close(operations.release)
+		Expect(migrate()).To(BeFalse())
+		Eventually(func() error {
+			_, err := migrate()
+			return err
+		}).Should(MatchError(ContainSubstring("clone failed")))
+		Expect(status.MigrationPhase).To(Equal(api.GitServerMigrationFailed))
+
+		Expect(migrate()).To(BeFalse())
+		Expect(status.MigrationPhase).To(Equal(api.GitServerMigrationRunning))
+	})
+
+	It("should not import existing repositories", func() {
+		fakeGitea.missing = false
+		Expect(migrate()).To(BeTrue())
+		Expect(operations.calls).To(BeZero())
+	})
+})
+
+var _ = Describe("grantGiteaReadAccess", func() {
+	var (
+		fakeGitea   *fakeGiteaRepo
+		giteaServer *httptest.Server
+		giteaClient *gitea.Client
+		repo        *gitea.Repository
+		credentials *giteaCredentials
+	)
+	repoURL := "https://gitea.example.com/gitea_admin/repo"
+
+	BeforeEach(func() {
+		repo = &gitea.Repository{ID: 1, Name: "repo", Created: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+		fakeGitea = &fakeGiteaRepo{repo: *repo}
+		giteaServer = httptest.NewServer(fakeGitea)
+		var err error
+		giteaClient, err = newGiteaClient(nil, giteaServer.URL, gitea.SetToken("token"))
+		Expect(err).ToNot(HaveOccurred())
+		credentials = &giteaCredentials{ReaderUser: GiteaReaderUser, ReaderToken: "reader-token"}
+	})
+
+	AfterEach(func() {
+		giteaServer.Close()
+		giteaReadAccess = map[string]string{}
+	})
+
+	It("should only grant the read access once", func() {
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, repo, credentials)).To(Succeed())
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, repo, credentials)).To(Succeed())
+		Expect(fakeGitea.getCalls()).To(Equal([]string{"collaborator " + GiteaReaderUser}))
+	})
+
+	It("should grant it again when the reader credentials change", func() {
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, repo, credentials)).To(Succeed())
+		credentials.ReaderToken = "rotated-token"
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, repo, credentials)).To(Succeed())
+		Expect(fakeGitea.getCalls()).To(HaveLen(2))
+	})
+
+	It("should grant it again when a resync replaced the repository", func() {
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, repo, credentials)).To(Succeed())
+		resynced := &gitea.Repository{ID: 2, Name: "repo", Created: repo.Created.Add(time.Hour)}
+		Expect(grantGiteaReadAccess(giteaClient, repoURL, resynced, credentials)).To(Succeed())
+		Expect(fakeGitea.getCalls()).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,145 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// GitServerBackend is an in-cluster git server hosting a copy of the upstream pattern
+// repository, so that users do not need to fork it. The reconciler only talks to this
+// interface and does not know which git server is in use.
+type GitServerBackend interface {
+	// Name of the backend, as used in the "gitServer.backend" operator setting
+	Name() string
+	// Provision deploys the git server. It is called on every reconcile loop and must be
+	// idempotent. It returns an error while the server is not ready yet.
+	Provision(p *api.Pattern) error
+	// ImportRepository imports upstreamURL into the git server, unless it is already there,
+	// and returns the url the pattern is deployed from
+	ImportRepository(p *api.Pattern, upstreamURL string) (string, error)
+	// SyncRepository keeps the imported repository in sync with upstreamURL according to
+	// p.Spec.GitConfig.InClusterGitMirror and records its state in p.Status.GitServer
+	SyncRepository(p *api.Pattern, upstreamURL string) error
+	// RepositoryCredentials returns the data of the argo repository secret argo uses to read
+	// the imported repository, or nil when no credentials are needed
+	RepositoryCredentials(p *api.Pattern) (map[string][]byte, error)
+}
+
+// gitServerBackends holds the known git server backends, keyed by name
+var gitServerBackends = map[string]func(r *PatternReconciler, patternsOperatorConfig PatternsOperatorConfig) GitServerBackend{
+	GiteaGitServerBackend: newGiteaGitServer,
+}
+
+// isInClusterGitServerEnabled returns true when the pattern asks for an in-cluster git server.
+// Patterns created before InClusterGitServer was honored only set OriginRepo and have false
+// stored by the CRD default, so OriginRepo still implies it whatever InClusterGitServer says.
+func isInClusterGitServerEnabled(p *api.Pattern) bool {
+	if p.Spec.GitConfig.InClusterGitServer != nil && *p.Spec.GitConfig.InClusterGitServer {
+		return true
+	}
+	return p.Spec.GitConfig.OriginRepo != ""
+}
+
+// gitServerUpstream returns the repository imported into the in-cluster git server: OriginRepo,
+// or TargetRepo when the pattern has no separate upstream
+func gitServerUpstream(p *api.Pattern) string {
+	if p.Spec.GitConfig.OriginRepo != "" {
+		return p.Spec.GitConfig.OriginRepo
+	}
+	return p.Spec.GitConfig.TargetRepo
+}
+
+// getGitServerStatus returns the git server status of p, creating it when needed
+func getGitServerStatus(p *api.Pattern) *api.GitServerStatus {
+	if p.Status.GitServer == nil {
+		p.Status.GitServer = &api.GitServerStatus{}
+	}
+	return p.Status.GitServer
+}
+
+// newGitServerBackend returns the git server backend selected in the operator config
+func (r *PatternReconciler) newGitServerBackend(patternsOperatorConfig PatternsOperatorConfig) (GitServerBackend, error) {
+	name := patternsOperatorConfig.getStringValue("gitServer.backend")
+	newBackend, ok := gitServerBackends[name]
+	if !ok {
+		known := make([]string, 0, len(gitServerBackends))
+		for k := range gitServerBackends {
+			known = append(known, k)
+		}
+		slices.Sort(known)
+		return nil, fmt.Errorf("unknown git server backend %q, must be one of: %s", name, strings.Join(known, ", "))
+	}
+	return newBackend(r, patternsOperatorConfig), nil
+}
+
+// reconcileInClusterGitServer provisions the in-cluster git server, imports the upstream repository
+// into it, deploys the pattern from the imported repository and keeps it in sync. The spec is left
+// untouched, the imported repository is recorded in status.effectiveTargetRepo. It returns
+// true when the status changed
+func (r *PatternReconciler) reconcileInClusterGitServer(input *api.Pattern, patternsOperatorConfig PatternsOperatorConfig) (bool, error) {
+	upstream := gitServerUpstream(input)
+	if upstream == "" {
+		return false, fmt.Errorf("inClusterGitServer requires originRepo or targetRepo to be set to the upstream repository")
+	}
+
+	backend, err := r.newGitServerBackend(patternsOperatorConfig)
+	if err != nil {
+		return false, err
+	}
+	before := input.Status.GitServer.DeepCopy()
+	beforeRepo := input.Status.EffectiveTargetRepo
+	if err = backend.Provision(input); err != nil {
+		return false, err
+	}
+
+	repoURL, err := backend.ImportRepository(input, upstream)
+	if err != nil {
+		return false, fmt.Errorf("%s: could not import %s: %v", backend.Name(), upstream, err)
+	}
+	if input.Status.EffectiveTargetRepo != repoURL {
+		log.Printf("Imported %s into the in-cluster %s git server: %s", upstream, backend.Name(), repoURL)
+		input.Status.EffectiveTargetRepo = repoURL
+	}
+
+	if err = r.copyInClusterGitCredentials(backend, input, getClusterWideArgoNamespace()); err != nil {
+		return false, err
+	}
+
+	// Syncing is best effort, problems are reported in the status without blocking the deployment
+	if err = backend.SyncRepository(input, upstream); err != nil {
+		log.Printf("Could not sync the in-cluster repository: %v", err)
+		getGitServerStatus(input).Message = err.Error()
+	}
+	return beforeRepo != input.Status.EffectiveTargetRepo || !equality.Semantic.DeepEqual(before, input.Status.GitServer), nil
+}
+
+// copyInClusterGitCredentials creates the argo repository secret for the in-cluster git server
+// in destNamespace
+func (r *PatternReconciler) copyInClusterGitCredentials(backend GitServerBackend, input *api.Pattern, destNamespace string) error {
+	data, err := backend.RepositoryCredentials(input)
+	if err != nil || data == nil {
+		return err
+	}
+	return r.createOrUpdateRepositorySecret(destNamespace, InClusterGitCredentialsSecretName, data)
+}
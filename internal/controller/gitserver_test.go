@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeGitServer records the calls made by the reconciler
+type fakeGitServer struct {
+	provisionErr error
+	imported     []string
+	syncCommit   string
+}
+
+func (f *fakeGitServer) Name() string {
+	return "fake"
+}
+
+func (f *fakeGitServer) Provision(_ *api.Pattern) error {
+	return f.provisionErr
+}
+
+func (f *fakeGitServer) ImportRepository(_ *api.Pattern, upstreamURL string) (string, error) {
+	f.imported = append(f.imported, upstreamURL)
+	return "https://git.in-cluster/patterns/repo", nil
+}
+
+func (f *fakeGitServer) RepositoryCredentials(_ *api.Pattern) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeGitServer) SyncRepository(p *api.Pattern, _ string) error {
+	if f.syncCommit == "" {
+		return fmt.Errorf("sync failed")
+	}
+	p.Status.GitServer = &api.GitServerStatus{Commit: f.syncCommit}
+	return nil
+}
+
+var _ = Describe("In-cluster git server", func() {
+	var reconciler *PatternReconciler
+	var pattern *api.Pattern
+	var backend *fakeGitServer
+	var config PatternsOperatorConfig
+
+	BeforeEach(func() {
+		nsOperators := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		reconciler = newFakeReconciler(nsOperators, buildPatternManifest())
+		pattern = &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), patternNamespaced, pattern)).To(Succeed())
+
+		backend = &fakeGitServer{}
+		gitServerBackends["fake"] = func(*PatternReconciler, PatternsOperatorConfig) GitServerBackend {
+			return backend
+		}
+		config = PatternsOperatorConfig{"gitServer.backend": "fake"}
+	})
+
+	AfterEach(func() {
+		delete(gitServerBackends, "fake")
+	})
+
+	Context("isInClusterGitServerEnabled", func() {
+		It("should follow InClusterGitServer", func() {
+			enabled := true
+			Expect(isInClusterGitServerEnabled(&api.Pattern{Spec: api.PatternSpec{GitConfig: api.GitConfig{InClusterGitServer: &enabled}}})).To(BeTrue())
+			Expect(isInClusterGitServerEnabled(&api.Pattern{})).To(BeFalse())
+		})
+
+		It("should be implied by OriginRepo", func() {
+			Expect(isInClusterGitServerEnabled(&api.Pattern{Spec: api.PatternSpec{GitConfig: api.GitConfig{
+				OriginRepo: originURL,
+			}}})).To(BeTrue())
+		})
+
+		It("should stay enabled by OriginRepo with the false stored by the CRD default", func() {
+			disabled := false
+			Expect(isInClusterGitServerEnabled(&api.Pattern{Spec: api.PatternSpec{GitConfig: api.GitConfig{
+				InClusterGitServer: &disabled,
+				OriginRepo:         originURL,
+			}}})).To(BeTrue())
+			Expect(isInClusterGitServerEnabled(&api.Pattern{Spec: api.PatternSpec{GitConfig: api.GitConfig{
+				InClusterGitServer: &disabled,
+			}}})).To(BeFalse())
+		})
+	})
+
+	Context("newGitServerBackend", func() {
+		It("should default to gitea", func() {
+			b, err := reconciler.newGitServerBackend(PatternsOperatorConfig{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Name()).To(Equal(GiteaGitServerBackend))
+		})
+
+		It("should fail on an unknown backend", func() {
+			_, err := reconciler.newGitServerBackend(PatternsOperatorConfig{"gitServer.backend": "svn"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake, gitea"))
+		})
+	})
+
+	Context("reconcileInClusterGitServer", func() {
+		It("should import the origin repo and deploy from it without touching the spec", func() {
+			targetRepo := pattern.Spec.GitConfig.TargetRepo
+			changed, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(backend.imported).To(Equal([]string{originURL}))
+			Expect(pattern.Status.EffectiveTargetRepo).To(Equal("https://git.in-cluster/patterns/repo"))
+			Expect(pattern.Spec.GitConfig.TargetRepo).To(Equal(targetRepo))
+			Expect(getTargetRepo(pattern)).To(Equal("https://git.in-cluster/patterns/repo"))
+			repoURL, _ := getPatternRepo(pattern)
+			Expect(repoURL).To(Equal("https://git.in-cluster/patterns/repo"))
+
+			stored := &api.Pattern{}
+			Expect(reconciler.Get(context.Background(), patternNamespaced, stored)).To(Succeed())
+			Expect(stored.Spec.GitConfig.TargetRepo).To(Equal(targetRepo))
+		})
+
+		It("should wait for the git server to be provisioned", func() {
+			backend.provisionErr = fmt.Errorf("waiting for the git server")
+			_, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).To(MatchError("waiting for the git server"))
+			Expect(backend.imported).To(BeEmpty())
+		})
+
+		It("should keep importing the origin repo of patterns stored with InClusterGitServer false", func() {
+			disabled := false
+			pattern.Spec.GitConfig.InClusterGitServer = &disabled
+			Expect(isInClusterGitServerEnabled(pattern)).To(BeTrue())
+			_, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backend.imported).To(Equal([]string{originURL}))
+			Expect(getTargetRepo(pattern)).To(Equal("https://git.in-cluster/patterns/repo"))
+		})
+
+		It("should import the target repo without an origin repo", func() {
+			enabled := true
+			pattern.Spec.GitConfig.InClusterGitServer = &enabled
+			pattern.Spec.GitConfig.OriginRepo = ""
+			_, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backend.imported).To(Equal([]string{pattern.Spec.GitConfig.TargetRepo}))
+			Expect(getTargetRepo(pattern)).To(Equal("https://git.in-cluster/patterns/repo"))
+		})
+
+		It("should require an upstream repo", func() {
+			pattern.Spec.GitConfig.OriginRepo = ""
+			pattern.Spec.GitConfig.TargetRepo = ""
+			_, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).To(MatchError(ContainSubstring("requires originRepo or targetRepo")))
+			Expect(backend.imported).To(BeEmpty())
+		})
+
+		It("should report status changes from the sync", func() {
+			backend.syncCommit = "abcd"
+			changed, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(pattern.Status.GitServer.Commit).To(Equal("abcd"))
+
+			changed, err = reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+		})
+
+		It("should not fail the reconcile when the sync fails", func() {
+			changed, err := reconciler.reconcileInClusterGitServer(pattern, config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(pattern.Status.GitServer.Message).To(Equal("sync failed"))
+		})
+	})
+})
@@ -0,0 +1,187 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// helmRepoIndex is the subset of a helm repository index.yaml we care about
+type helmRepoIndex struct {
+	Entries map[string][]struct {
+		Version string `json:"version"`
+	} `json:"entries"`
+}
+
+// newHelmRepositorySecretData turns the user provided credentials into the data of an argo
+// helm repository secret (https://argo-cd.readthedocs.io/en/stable/operator-manual/declarative-setup/#helm)
+func newHelmRepositorySecretData(repoURL string, credentials map[string][]byte) map[string][]byte {
+	data := make(map[string][]byte, len(credentials)+2)
+	for k, v := range credentials {
+		data[k] = v
+	}
+	data["type"] = []byte("helm")
+	data["url"] = []byte(repoURL)
+	// Argo expects OCI helm repositories without the scheme
+	if isOCIURL(repoURL) {
+		data["url"] = []byte(strings.TrimPrefix(repoURL, OCIScheme))
+		data["enableOCI"] = []byte("true")
+	}
+	return data
+}
+
+// isOCIHelmRepository returns true when the credentials describe an OCI registry
+func isOCIHelmRepository(credentials map[string][]byte) bool {
+	return strings.EqualFold(string(credentials["enableOCI"]), "true")
+}
+
+// getHelmRepoIndex downloads and parses the index.yaml of a classic (non-OCI) helm repository
+func getHelmRepoIndex(fullClient kubernetes.Interface, repoURL string, credentials map[string][]byte) (*helmRepoIndex, error) {
+	transport := getHTTPSTransport(fullClient)
+	if cert, key := getTLSClientCert(credentials); cert != nil {
+		clientCert, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse helm repository client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	httpClient := &nethttp.Client{
+		Transport: transport,
+		Timeout:   ContextTimeout,
+	}
+
+	indexURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	req, err := nethttp.NewRequestWithContext(context.Background(), nethttp.MethodGet, indexURL, nethttp.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if detectGitAuthType(credentials) == GitAuthPassword {
+		req.SetBasicAuth(string(credentials["username"]), string(credentials["password"]))
+	}
+
+	resp, err := httpClient.Do(req) //nolint:gosec // URL comes from the Pattern spec
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch %s: %w", indexURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, fmt.Errorf("could not fetch %s: %s", indexURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	index := &helmRepoIndex{}
+	if err = yaml.Unmarshal(body, index); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", indexURL, err)
+	}
+	return index, nil
+}
+
+// helmChartVersions returns the versions of chart in the index
+func helmChartVersions(index *helmRepoIndex, chart string) ([]string, error) {
+	entries, ok := index.Entries[chart]
+	if !ok || len(entries) == 0 {
+		return nil, fmt.Errorf("chart %q not found in helm repository", chart)
+	}
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+	}
+	return versions, nil
+}
+
+// isChartVersionConstraint returns false when version is an exact semver version, which needs
+// no resolution against the helm repository
+func isChartVersionConstraint(version string) bool {
+	_, err := semver.StrictNewVersion(version)
+	return err != nil
+}
+
+// How long the versions of a chart listed by a helm repository are reused before asking it again
+const ChartVersionsCacheTTL = 5 * time.Minute
+
+// chartVersionsCheck holds the versions of a chart listed by its helm repository
+type chartVersionsCheck struct {
+	versions  []string
+	checkedAt time.Time
+}
+
+// chartVersions caches the versions of the charts, keyed by the url of the chart, so that the
+// helm repositories are not asked on every reconcile
+var (
+	chartVersionsMutex sync.Mutex
+	chartVersions      = map[string]chartVersionsCheck{}
+)
+
+// getChartVersions returns the versions of the chart at chartURL, calling list at most every
+// ChartVersionsCacheTTL. Failures are not cached, fixed credentials are used on the next reconcile
+func getChartVersions(chartURL string, list func() ([]string, error)) ([]string, error) {
+	chartVersionsMutex.Lock()
+	check, found := chartVersions[chartURL]
+	chartVersionsMutex.Unlock()
+	if found && time.Since(check.checkedAt) < ChartVersionsCacheTTL {
+		return check.versions, nil
+	}
+
+	versions, err := list()
+	if err != nil {
+		return nil, err
+	}
+	chartVersionsMutex.Lock()
+	chartVersions[chartURL] = chartVersionsCheck{versions: versions, checkedAt: time.Now()}
+	chartVersionsMutex.Unlock()
+	return versions, nil
+}
+
+// highestMatchingVersion returns the highest of the given versions that satisfies the
+// constraint. Versions that are not valid semver are ignored.
+func highestMatchingVersion(versions []string, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid chart version %q: %w", constraint, err)
+	}
+
+	var best *semver.Version
+	var bestVersion string
+	for _, v := range versions {
+		parsed, err := semver.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if c.Check(parsed) && (best == nil || parsed.GreaterThan(best)) {
+			best = parsed
+			bestVersion = v
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no chart version matches %q", constraint)
+	}
+	return bestVersion, nil
+}
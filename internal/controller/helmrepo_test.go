@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+const testHelmIndex = `apiVersion: v1
+entries:
+  clustergroup:
+  - name: clustergroup
+    version: 0.8.10
+  - name: clustergroup
+    version: 0.9.2
+  - name: clustergroup
+    version: 0.9.13
+  - name: clustergroup
+    version: 0.9.14-rc1
+  - name: clustergroup
+    version: 0.10.0
+  gitea:
+  - name: gitea
+    version: 0.0.3
+`
+
+var _ = Describe("newHelmRepositorySecretData", func() {
+	It("should turn the credentials into an argo helm repository secret", func() {
+		credentials := map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+		}
+		data := newHelmRepositorySecretData("https://charts.example.com/", credentials)
+		Expect(data).To(HaveKeyWithValue("type", []byte("helm")))
+		Expect(data).To(HaveKeyWithValue("url", []byte("https://charts.example.com/")))
+		Expect(data).To(HaveKeyWithValue("username", []byte("user")))
+		Expect(data).To(HaveKeyWithValue("password", []byte("pass")))
+		// The source secret must not be modified
+		Expect(credentials).ToNot(HaveKey("type"))
+	})
+
+	It("should keep enableOCI for OCI registries", func() {
+		credentials := map[string][]byte{
+			"enableOCI": []byte("true"),
+		}
+		data := newHelmRepositorySecretData("quay.io/example/charts", credentials)
+		Expect(data).To(HaveKeyWithValue("enableOCI", []byte("true")))
+		Expect(isOCIHelmRepository(data)).To(BeTrue())
+		Expect(isOCIHelmRepository(nil)).To(BeFalse())
+	})
+})
+
+var _ = Describe("highestMatchingVersion", func() {
+	versions := []string{"0.8.10", "0.9.2", "0.9.13", "0.9.14-rc1", "0.10.0", "not-a-version"}
+
+	It("should return the highest version matching a wildcard", func() {
+		Expect(highestMatchingVersion(versions, "0.9.*")).To(Equal("0.9.13"))
+		Expect(highestMatchingVersion(versions, "0.8.*")).To(Equal("0.8.10"))
+	})
+
+	It("should return an exact version", func() {
+		Expect(highestMatchingVersion(versions, "0.9.2")).To(Equal("0.9.2"))
+	})
+
+	It("should fail when nothing matches", func() {
+		_, err := highestMatchingVersion(versions, "1.0.*")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail on an invalid constraint", func() {
+		_, err := highestMatchingVersion(versions, "main")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("getHelmRepoIndex", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+			user, pass, ok := req.BasicAuth()
+			if !ok || user != "user" || pass != "pass" {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			if req.URL.Path != "/charts/index.yaml" {
+				w.WriteHeader(nethttp.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(testHelmIndex))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should fetch the index with the credentials", func() {
+		credentials := map[string][]byte{
+			"username": []byte("user"),
+			"password": []byte("pass"),
+		}
+		index, err := getHelmRepoIndex(nil, server.URL+"/charts/", credentials)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(helmChartVersions(index, ClusterGroupChartName)).To(ContainElement("0.9.13"))
+		_, err = helmChartVersions(index, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail without credentials", func() {
+		_, err := getHelmRepoIndex(nil, server.URL+"/charts", nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("401"))
+	})
+})
+
+var _ = Describe("helm repository credentials", func() {
+	var reconciler *PatternReconciler
+	var pattern *api.Pattern
+	var server *httptest.Server
+	var requests int
+
+	BeforeEach(func() {
+		requests = 0
+		server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+			requests++
+			if user, _, ok := req.BasicAuth(); !ok || user != "user" {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(testHelmIndex))
+		}))
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-creds", Namespace: "pattern-ns"},
+			Data: map[string][]byte{
+				"username": []byte("user"),
+				"password": []byte("pass"),
+			},
+		}
+		reconciler = &PatternReconciler{
+			fullClient: kubeclient.NewSimpleClientset(credentials),
+		}
+		enabled := true
+		pattern = &api.Pattern{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"},
+			Spec: api.PatternSpec{
+				ClusterGroupName: "hub",
+				MultiSourceConfig: api.MultiSourceConfig{
+					Enabled:                            &enabled,
+					HelmRepoUrl:                        server.URL,
+					ClusterGroupChartVersion:           "0.9.*",
+					HelmRepoCredentialsSecret:          "helm-creds",
+					HelmRepoCredentialsSecretNamespace: "pattern-ns",
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should copy the credentials as an argo helm repository secret", func() {
+		Expect(reconciler.copyHelmRepoSecret(pattern, "vp-gitops")).To(Succeed())
+		secret, err := reconciler.fullClient.CoreV1().Secrets("vp-gitops").Get(context.Background(), HelmRepoCredentialsSecretName, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secret.Labels).To(HaveKeyWithValue("argocd.argoproj.io/secret-type", "repository"))
+		Expect(secret.Data).To(HaveKeyWithValue("type", []byte("helm")))
+		Expect(secret.Data).To(HaveKeyWithValue("url", []byte(server.URL)))
+		Expect(secret.Data).To(HaveKeyWithValue("username", []byte("user")))
+	})
+
+	It("should fail when the credentials secret does not exist", func() {
+		pattern.Spec.MultiSourceConfig.HelmRepoCredentialsSecret = "missing"
+		Expect(reconciler.copyHelmRepoSecret(pattern, "vp-gitops")).ToNot(Succeed())
+	})
+
+	It("should resolve the clustergroup chart version with the credentials", func() {
+		changed, err := reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(pattern.Status.ClusterGroupChartVersion).To(Equal("0.9.13"))
+
+		changed, err = reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		// The versions of the repository are reused
+		Expect(requests).To(Equal(1))
+	})
+
+	It("should record an exact version without asking the repository", func() {
+		pattern.Spec.MultiSourceConfig.ClusterGroupChartVersion = "0.9.7"
+		Expect(isChartVersionConstraint("0.9.7")).To(BeFalse())
+		Expect(isChartVersionConstraint("0.9")).To(BeTrue())
+		changed, err := reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(pattern.Status.ClusterGroupChartVersion).To(Equal("0.9.7"))
+		Expect(requests).To(BeZero())
+	})
+
+	It("should ask the repository again once the cached versions expire", func() {
+		_, err := reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		chartURL := server.URL + "/" + ClusterGroupChartName
+		chartVersionsMutex.Lock()
+		check := chartVersions[chartURL]
+		check.checkedAt = time.Now().Add(-ChartVersionsCacheTTL)
+		chartVersions[chartURL] = check
+		chartVersionsMutex.Unlock()
+
+		_, err = reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(Equal(2))
+	})
+
+	It("should not reuse a failure once the credentials are fixed", func() {
+		secrets := reconciler.fullClient.CoreV1().Secrets("pattern-ns")
+		secret, err := secrets.Get(context.Background(), "helm-creds", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		secret.Data["username"] = []byte("other")
+		_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).To(MatchError(ContainSubstring("401")))
+
+		secret.Data["username"] = []byte("user")
+		_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pattern.Status.ClusterGroupChartVersion).To(Equal("0.9.13"))
+		Expect(requests).To(Equal(2))
+	})
+
+	It("should leave OCI registries to argo", func() {
+		secret, err := reconciler.fullClient.CoreV1().Secrets("pattern-ns").Get(context.Background(), "helm-creds", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		secret.Data["enableOCI"] = []byte("true")
+		_, err = reconciler.fullClient.CoreV1().Secrets("pattern-ns").Update(context.Background(), secret, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		changed, err := reconciler.resolveClusterGroupChartVersion(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(pattern.Status.ClusterGroupChartVersion).To(BeEmpty())
+	})
+})
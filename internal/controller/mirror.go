@@ -0,0 +1,302 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/console"
+	operatorv1alpha1 "github.com/openshift/api/operator/v1alpha1"
+	configclient "github.com/openshift/client-go/config/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// registryMirrors holds the rewrites applied to images and chart urls when the operator runs
+// in disconnected mode
+type registryMirrors struct {
+	Enabled bool
+	// Images maps image repository prefixes (e.g. registry.redhat.io/ubi9) to their mirror
+	Images map[string]string
+	// URLs maps url prefixes (e.g. https://charts.validatedpatterns.io) to their mirror
+	URLs map[string]string
+	// Verified is true once the mirrors passed the reachability preflight
+	Verified bool
+}
+
+// activeMirrors holds the disconnected mode configuration. It is set by detectMirrors()
+// during reconciliation and is disabled by default, in which case nothing is rewritten.
+// The preflight and the resource builders use it concurrently, always go through getMirrors()
+// and setMirrors()
+var (
+	activeMirrorsMutex sync.RWMutex
+	activeMirrors      = registryMirrors{}
+)
+
+func init() {
+	// The catalog deployment lives in its own package and is also created at startup
+	console.ImageMirror = func(image string) string {
+		return getMirrors().rewriteImage(image)
+	}
+}
+
+// getMirrors returns the disconnected mode configuration detected by the latest reconcile
+func getMirrors() registryMirrors {
+	activeMirrorsMutex.RLock()
+	defer activeMirrorsMutex.RUnlock()
+	return activeMirrors
+}
+
+// setMirrors replaces the disconnected mode configuration. The preflight result is kept as long as
+// the mirrors do not change. It returns true when they changed
+func setMirrors(m registryMirrors) bool {
+	activeMirrorsMutex.Lock()
+	defer activeMirrorsMutex.Unlock()
+	if m.equal(activeMirrors) {
+		m.Verified = activeMirrors.Verified
+		activeMirrors = m
+		return false
+	}
+	activeMirrors = m
+	return true
+}
+
+// setMirrorsVerified records that m passed the preflight, unless the mirrors changed meanwhile
+func setMirrorsVerified(m registryMirrors) {
+	activeMirrorsMutex.Lock()
+	defer activeMirrorsMutex.Unlock()
+	if m.equal(activeMirrors) {
+		activeMirrors.Verified = true
+	}
+}
+
+func imageContentSourcePolicyGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "operator.openshift.io", Version: "v1alpha1", Resource: "imagecontentsourcepolicies"}
+}
+
+// parseMirrorMap parses the "disconnected.mirrors" operator setting: one source=mirror pair
+// per line or comma separated. Sources containing a scheme are url mirrors, the rest image mirrors
+func parseMirrorMap(value string) (images, urls map[string]string, err error) {
+	images = map[string]string{}
+	urls = map[string]string{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		source, mirror, found := strings.Cut(entry, "=")
+		source, mirror = strings.TrimSpace(source), strings.TrimSpace(mirror)
+		if !found || source == "" || mirror == "" {
+			return nil, nil, fmt.Errorf("invalid mirror entry %q, expected source=mirror", entry)
+		}
+		if strings.Contains(source, "://") {
+			urls[strings.TrimSuffix(source, "/")] = strings.TrimSuffix(mirror, "/")
+		} else {
+			images[source] = mirror
+		}
+	}
+	return images, urls, nil
+}
+
+// detectMirrors builds the disconnected mode configuration from the operator config and from the
+// ImageDigestMirrorSets, ImageTagMirrorSets and ImageContentSourcePolicies of the cluster. The mirrors
+// in the operator config take precedence over the cluster ones.
+func detectMirrors(configClient configclient.Interface, dynamicClient dynamic.Interface, patternsOperatorConfig PatternsOperatorConfig) error {
+	if !patternsOperatorConfig.getBoolValue("disconnected.enabled") {
+		setMirrors(registryMirrors{})
+		return nil
+	}
+
+	images, urls, err := parseMirrorMap(patternsOperatorConfig.getStringValue("disconnected.mirrors"))
+	if err != nil {
+		return err
+	}
+
+	idmsList, err := configClient.ConfigV1().ImageDigestMirrorSets().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		logOnce(fmt.Sprintf("Could not list ImageDigestMirrorSets: %v", err))
+	} else {
+		for i := range idmsList.Items {
+			for _, m := range idmsList.Items[i].Spec.ImageDigestMirrors {
+				if _, ok := images[m.Source]; !ok && len(m.Mirrors) > 0 {
+					images[m.Source] = string(m.Mirrors[0])
+				}
+			}
+		}
+	}
+
+	// The images we deploy are mostly pulled by tag, which only the ImageTagMirrorSets cover
+	itmsList, err := configClient.ConfigV1().ImageTagMirrorSets().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		logOnce(fmt.Sprintf("Could not list ImageTagMirrorSets: %v", err))
+	} else {
+		for i := range itmsList.Items {
+			for _, m := range itmsList.Items[i].Spec.ImageTagMirrors {
+				if _, ok := images[m.Source]; !ok && len(m.Mirrors) > 0 {
+					images[m.Source] = string(m.Mirrors[0])
+				}
+			}
+		}
+	}
+
+	icspList, err := dynamicClient.Resource(imageContentSourcePolicyGVR()).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		logOnce(fmt.Sprintf("Could not list ImageContentSourcePolicies: %v", err))
+	} else {
+		for i := range icspList.Items {
+			var icsp operatorv1alpha1.ImageContentSourcePolicy
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(icspList.Items[i].Object, &icsp); err != nil {
+				continue
+			}
+			for _, m := range icsp.Spec.RepositoryDigestMirrors {
+				if _, ok := images[m.Source]; !ok && len(m.Mirrors) > 0 {
+					images[m.Source] = m.Mirrors[0]
+				}
+			}
+		}
+	}
+
+	if setMirrors(registryMirrors{Enabled: true, Images: images, URLs: urls}) {
+		logOnce(fmt.Sprintf("Disconnected mode enabled with %d image and %d url mirrors", len(images), len(urls)))
+	}
+	return nil
+}
+
+func (m registryMirrors) equal(other registryMirrors) bool {
+	return m.Enabled == other.Enabled && mapsEqual(m.Images, other.Images) && mapsEqual(m.URLs, other.URLs)
+}
+
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// longestPrefix returns the longest key of mirrors that is a prefix of s ending at a boundary
+func longestPrefix(mirrors map[string]string, s string, boundary func(rest string) bool) string {
+	best := ""
+	for source := range mirrors {
+		if strings.HasPrefix(s, source) && len(source) > len(best) && boundary(s[len(source):]) {
+			best = source
+		}
+	}
+	return best
+}
+
+// rewriteImage returns the mirrored location of image, or image itself when no mirror matches
+func (m registryMirrors) rewriteImage(image string) string {
+	if !m.Enabled {
+		return image
+	}
+	source := longestPrefix(m.Images, image, func(rest string) bool {
+		return rest == "" || strings.ContainsAny(rest[:1], "/:@")
+	})
+	if source == "" {
+		return image
+	}
+	return m.Images[source] + strings.TrimPrefix(image, source)
+}
+
+// rewriteURL returns the mirrored location of a chart or repository url, or url itself when
+// no mirror matches
+func (m registryMirrors) rewriteURL(repoURL string) string {
+	if !m.Enabled {
+		return repoURL
+	}
+	source := longestPrefix(m.URLs, repoURL, func(rest string) bool {
+		return rest == "" || rest[0] == '/'
+	})
+	if source == "" {
+		return repoURL
+	}
+	return m.URLs[source] + strings.TrimPrefix(repoURL, source)
+}
+
+// operatorImages returns the images the operator deploys itself, the only ones it rewrites to their mirror
+func operatorImages(operatorConfigMap *corev1.ConfigMap) []string {
+	return []string{ArgoInitContainerImage, console.CatalogImage(operatorConfigMap)}
+}
+
+// preflightURLs returns the endpoints that must answer for the mirrors of images and of the chart urls
+// to be usable. The other image mirrors of the cluster are left to the cluster
+func (m registryMirrors) preflightURLs(images []string) []string {
+	var endpoints []string
+	for _, image := range images {
+		if mirrored := m.rewriteImage(image); mirrored != image {
+			registry, _, _ := strings.Cut(mirrored, "/")
+			endpoints = append(endpoints, "https://"+registry+"/v2/")
+		}
+	}
+	for _, mirror := range m.URLs {
+		if isOCIURL(mirror) {
+			registry, _, _ := strings.Cut(strings.TrimPrefix(mirror, OCIScheme), "/")
+			endpoints = append(endpoints, "https://"+registry+"/v2/")
+		} else {
+			endpoints = append(endpoints, mirror)
+		}
+	}
+	sort.Strings(endpoints)
+	return slices.Compact(endpoints)
+}
+
+// preflightMirrors checks that the mirrors of images and of the chart urls answer. Any http response
+// counts, since registries reply 401 to anonymous requests. The result is cached until the mirrors change.
+func preflightMirrors(fullClient kubernetes.Interface, images []string) error {
+	mirrors := getMirrors()
+	if !mirrors.Enabled || mirrors.Verified {
+		return nil
+	}
+	httpClient := &nethttp.Client{
+		Transport: getHTTPSTransport(fullClient),
+		Timeout:   ContextTimeout,
+	}
+	var unreachable []string
+	for _, endpoint := range mirrors.preflightURLs(images) {
+		req, err := nethttp.NewRequestWithContext(context.Background(), nethttp.MethodGet, endpoint, nethttp.NoBody)
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%v)", endpoint, err))
+			continue
+		}
+		resp, err := httpClient.Do(req) //nolint:gosec // URL comes from the operator config
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%v)", endpoint, err))
+			continue
+		}
+		resp.Body.Close()
+	}
+	if len(unreachable) > 0 {
+		return fmt.Errorf("mirrors not reachable: %s", strings.Join(unreachable, ", "))
+	}
+	setMirrorsVerified(mirrors)
+	return nil
+}
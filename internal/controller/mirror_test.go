@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	configclient "github.com/openshift/client-go/config/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var _ = Describe("Disconnected mirrors", func() {
+	AfterEach(func() {
+		setMirrors(registryMirrors{})
+	})
+
+	Context("parseMirrorMap", func() {
+		It("should split image and url mirrors", func() {
+			images, urls, err := parseMirrorMap(`registry.redhat.io/ubi9=mirror.local:5000/ubi9
+https://charts.validatedpatterns.io/=https://nexus.local/charts/, oci://quay.io/vp=oci://mirror.local:5000/vp`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(images).To(Equal(map[string]string{"registry.redhat.io/ubi9": "mirror.local:5000/ubi9"}))
+			Expect(urls).To(Equal(map[string]string{
+				"https://charts.validatedpatterns.io": "https://nexus.local/charts",
+				"oci://quay.io/vp":                    "oci://mirror.local:5000/vp",
+			}))
+		})
+
+		It("should reject invalid entries", func() {
+			_, _, err := parseMirrorMap("registry.redhat.io")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("detectMirrors", func() {
+		var configClient *configclient.Clientset
+		var dynamicClient *dynamicfake.FakeDynamicClient
+
+		BeforeEach(func() {
+			idms := &configv1.ImageDigestMirrorSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "idms"},
+				Spec: configv1.ImageDigestMirrorSetSpec{
+					ImageDigestMirrors: []configv1.ImageDigestMirrors{
+						{Source: "registry.redhat.io/ubi9", Mirrors: []configv1.ImageMirror{"idms.local/ubi9"}},
+						{Source: "registry.redhat.io/openshift-gitops-1", Mirrors: []configv1.ImageMirror{"idms.local/gitops"}},
+					},
+				},
+			}
+			itms := &configv1.ImageTagMirrorSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "itms"},
+				Spec: configv1.ImageTagMirrorSetSpec{
+					ImageTagMirrors: []configv1.ImageTagMirrors{
+						{Source: "registry.redhat.io/ubi9", Mirrors: []configv1.ImageMirror{"itms.local/ubi9"}},
+						{Source: "registry.redhat.io/rhel9", Mirrors: []configv1.ImageMirror{"itms.local/rhel9"}},
+					},
+				},
+			}
+			configClient = configclient.NewSimpleClientset(idms, itms)
+			icsp := &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "operator.openshift.io/v1alpha1",
+				"kind":       "ImageContentSourcePolicy",
+				"metadata":   map[string]any{"name": "icsp"},
+				"spec": map[string]any{
+					"repositoryDigestMirrors": []any{
+						map[string]any{"source": "quay.io/validatedpatterns", "mirrors": []any{"icsp.local/vp"}},
+					},
+				},
+			}}
+			dynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				imageContentSourcePolicyGVR(): "ImageContentSourcePolicyList",
+			}, icsp)
+		})
+
+		It("should do nothing unless disconnected mode is enabled", func() {
+			Expect(detectMirrors(configClient, dynamicClient, PatternsOperatorConfig{})).To(Succeed())
+			Expect(getMirrors().Enabled).To(BeFalse())
+			Expect(getMirrors().rewriteImage("registry.redhat.io/ubi9/ubi-minimal:latest")).To(Equal("registry.redhat.io/ubi9/ubi-minimal:latest"))
+		})
+
+		It("should merge the cluster mirrors with the operator config ones", func() {
+			Expect(detectMirrors(configClient, dynamicClient, PatternsOperatorConfig{
+				"disconnected.enabled": "true",
+				"disconnected.mirrors": "registry.redhat.io/ubi9=config.local/ubi9",
+			})).To(Succeed())
+			Expect(getMirrors().Images).To(Equal(map[string]string{
+				"registry.redhat.io/ubi9":               "config.local/ubi9",
+				"registry.redhat.io/openshift-gitops-1": "idms.local/gitops",
+				"registry.redhat.io/rhel9":              "itms.local/rhel9",
+				"quay.io/validatedpatterns":             "icsp.local/vp",
+			}))
+		})
+
+		It("should keep the preflight result while the mirrors do not change", func() {
+			config := PatternsOperatorConfig{"disconnected.enabled": "true"}
+			Expect(detectMirrors(configClient, dynamicClient, config)).To(Succeed())
+			setMirrorsVerified(getMirrors())
+			Expect(detectMirrors(configClient, dynamicClient, config)).To(Succeed())
+			Expect(getMirrors().Verified).To(BeTrue())
+
+			config["disconnected.mirrors"] = "quay.io=other.local"
+			Expect(detectMirrors(configClient, dynamicClient, config)).To(Succeed())
+			Expect(getMirrors().Verified).To(BeFalse())
+		})
+
+		It("should fail on an invalid mirror map", func() {
+			Expect(detectMirrors(configClient, dynamicClient, PatternsOperatorConfig{
+				"disconnected.enabled": "true",
+				"disconnected.mirrors": "garbage",
+			})).ToNot(Succeed())
+		})
+	})
+
+	Context("rewrites", func() {
+		BeforeEach(func() {
+			setMirrors(registryMirrors{
+				Enabled: true,
+				Images: map[string]string{
+					"registry.redhat.io":      "mirror.local/rh",
+					"registry.redhat.io/ubi9": "mirror.local/ubi9",
+				},
+				URLs: map[string]string{
+					"https://charts.validatedpatterns.io": "https://nexus.local/charts",
+					"oci://quay.io/vp":                    "oci://mirror.local/vp",
+				},
+			})
+		})
+
+		It("should rewrite images on the longest matching prefix", func() {
+			Expect(getMirrors().rewriteImage("registry.redhat.io/ubi9/ubi-minimal:latest")).To(Equal("mirror.local/ubi9/ubi-minimal:latest"))
+			Expect(getMirrors().rewriteImage("registry.redhat.io/ubi8/ubi:latest")).To(Equal("mirror.local/rh/ubi8/ubi:latest"))
+			Expect(getMirrors().rewriteImage("registry.redhat.io/ubi9-micro:latest")).To(Equal("mirror.local/rh/ubi9-micro:latest"))
+			Expect(getMirrors().rewriteImage("quay.io/foo/bar@sha256:abcd")).To(Equal("quay.io/foo/bar@sha256:abcd"))
+		})
+
+		It("should rewrite urls on path boundaries", func() {
+			Expect(getMirrors().rewriteURL("https://charts.validatedpatterns.io/")).To(Equal("https://nexus.local/charts/"))
+			Expect(getMirrors().rewriteURL("https://charts.validatedpatterns.io")).To(Equal("https://nexus.local/charts"))
+			Expect(getMirrors().rewriteURL("https://charts.validatedpatterns.io.example.com/")).To(Equal("https://charts.validatedpatterns.io.example.com/"))
+			Expect(getMirrors().rewriteURL("oci://quay.io/vp/mcg")).To(Equal("oci://mirror.local/vp/mcg"))
+		})
+
+		It("should be applied to the generated resources", func() {
+			enabled := true
+			p := &api.Pattern{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"},
+				Spec: api.PatternSpec{
+					ClusterGroupName: "hub",
+					GitConfig:        api.GitConfig{TargetRepo: "https://github.com/validatedpatterns/mcg", TargetRevision: "main"},
+					MultiSourceConfig: api.MultiSourceConfig{
+						Enabled:                  &enabled,
+						HelmRepoUrl:              "https://charts.validatedpatterns.io/",
+						ClusterGroupChartVersion: "0.9.*",
+					},
+					GitOpsConfig: &api.GitOpsConfig{},
+				},
+			}
+			app := newMultiSourceApplication(p)
+			Expect(app.Spec.Sources[1].RepoURL).To(Equal("https://nexus.local/charts/"))
+			Expect(app.Spec.Sources[1].Helm.Parameters).To(ContainElement(HaveField("Value", "https://nexus.local/charts/")))
+
+			gitea := newArgoGiteaApplication(p, PatternsOperatorConfig{})
+			Expect(gitea.Spec.Source.RepoURL).To(Equal("https://nexus.local/charts/"))
+
+			argo := newArgoCD("argo", "vp-gitops", PatternsOperatorConfig{})
+			Expect(argo.Spec.Repo.InitContainers[0].Image).To(Equal("mirror.local/ubi9/ubi-minimal:latest"))
+		})
+	})
+
+	Context("preflightMirrors", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+				w.WriteHeader(nethttp.StatusUnauthorized)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should accept any http answer", func() {
+			setMirrors(registryMirrors{Enabled: true, URLs: map[string]string{"https://charts.validatedpatterns.io": server.URL}})
+			Expect(preflightMirrors(nil, nil)).To(Succeed())
+			Expect(getMirrors().Verified).To(BeTrue())
+		})
+
+		It("should only check the image mirrors of the images the operator deploys", func() {
+			mirrors := registryMirrors{Enabled: true, Images: map[string]string{
+				"registry.redhat.io/ubi9": "mirror.local/ubi9",
+				"quay.io/other":           "unreachable.local/other",
+			}}
+			Expect(mirrors.preflightURLs(operatorImages(nil))).To(Equal([]string{"https://mirror.local/v2/"}))
+		})
+
+		It("should fail when a mirror does not answer", func() {
+			url := server.URL
+			server.Close()
+			setMirrors(registryMirrors{Enabled: true, URLs: map[string]string{"https://charts.validatedpatterns.io": url}})
+			err := preflightMirrors(nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(url))
+			Expect(getMirrors().Verified).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-cd/v3/util/io/files"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"k8s.io/client-go/kubernetes"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+const (
+	// OCIScheme is the prefix of urls pointing to an OCI registry
+	OCIScheme = "oci://"
+	// OCIDefaultVersion is the tag used for the pattern OCI artifact when none is specified
+	OCIDefaultVersion = "latest"
+	// OCIMaxExtractedSize caps the size of the extracted pattern OCI artifact
+	OCIMaxExtractedSize = 512 * 1024 * 1024
+	// OCIPullTimeout is the maximum time to download the pattern OCI artifact
+	OCIPullTimeout = 5 * time.Minute
+	// PatternOCICredentialsSecretName is the name of the argo repository secret for the pattern OCI artifact
+	PatternOCICredentialsSecretName = "vp-pattern-oci-credentials" //nolint:gosec
+)
+
+// Layer media types we know how to extract
+var ociContentMediaTypes = []string{
+	ocispec.MediaTypeImageLayerGzip,
+	"application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+}
+
+func isOCIURL(url string) bool {
+	return strings.HasPrefix(url, OCIScheme)
+}
+
+// newOCIRepositorySecretData turns the user provided credentials into the data of an argo
+// OCI repository secret for the pattern OCI artifact
+func newOCIRepositorySecretData(repoURL string, credentials map[string][]byte) map[string][]byte {
+	data := make(map[string][]byte, len(credentials)+2)
+	for k, v := range credentials {
+		data[k] = v
+	}
+	data["type"] = []byte("oci")
+	data["url"] = []byte(repoURL)
+	return data
+}
+
+// newOCIRepository returns an oras client for the OCI repository at url (with or without
+// the oci:// prefix). credentials follow the argo repository secret fields
+func newOCIRepository(fullClient kubernetes.Interface, url string, credentials map[string][]byte) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(strings.TrimPrefix(url, OCIScheme))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCI repository %q: %w", url, err)
+	}
+	repo.PlainHTTP = strings.EqualFold(string(credentials["insecureOCIForceHttp"]), "true")
+
+	transport := getHTTPSTransport(fullClient)
+	if cert, key := getTLSClientCert(credentials); cert != nil {
+		clientCert, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse OCI registry client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	repo.Client = &auth.Client{
+		Client: &nethttp.Client{
+			Transport: transport,
+		},
+		Credential: auth.StaticCredential(repo.Reference.Registry, auth.Credential{
+			Username: string(credentials["username"]),
+			Password: string(credentials["password"]),
+		}),
+	}
+	return repo, nil
+}
+
+// getOCITags lists all the tags of an OCI repository. By convention helm replaces the
+// '+' of semver build metadata with '_' in OCI tags, so we convert them back
+func getOCITags(ctx context.Context, repo *remote.Repository) ([]string, error) {
+	var tags []string
+	err := repo.Tags(ctx, "", func(page []string) error {
+		for _, tag := range page {
+			tags = append(tags, strings.ReplaceAll(tag, "_", "+"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list tags of %s: %w", repo.Reference.String(), err)
+	}
+	return tags, nil
+}
+
+// resolveOCIVersion resolves version to an existing tag of the repository. version is
+// either a tag or a semver range, in which case the highest matching tag is returned
+func resolveOCIVersion(ctx context.Context, repo *remote.Repository, version string) (string, error) {
+	tags, err := getOCITags(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	return resolveTagVersion(tags, version)
+}
+
+// resolveTagVersion returns version when it is one of the tags, the highest tag that satisfies it
+// otherwise
+func resolveTagVersion(tags []string, version string) (string, error) {
+	if slices.Contains(tags, version) {
+		return version, nil
+	}
+	return highestMatchingVersion(tags, version)
+}
+
+// pullOCIArtifact downloads the content layer of the artifact tagged with tag and
+// extracts it into directory, replacing any previous content
+func pullOCIArtifact(ctx context.Context, repo *remote.Repository, tag, directory string) error {
+	_, manifestReader, err := repo.FetchReference(ctx, strings.ReplaceAll(tag, "+", "_"))
+	if err != nil {
+		return fmt.Errorf("could not fetch manifest of %s:%s: %w", repo.Reference.String(), tag, err)
+	}
+	defer manifestReader.Close()
+
+	var manifest ocispec.Manifest
+	if err = json.NewDecoder(manifestReader).Decode(&manifest); err != nil {
+		return fmt.Errorf("could not parse manifest of %s:%s: %w", repo.Reference.String(), tag, err)
+	}
+
+	var contentLayers []ocispec.Descriptor
+	for _, layer := range manifest.Layers {
+		if slices.Contains(ociContentMediaTypes, layer.MediaType) {
+			contentLayers = append(contentLayers, layer)
+		}
+	}
+	if len(contentLayers) != 1 {
+		return fmt.Errorf("expected a single content layer in %s:%s, got %d", repo.Reference.String(), tag, len(contentLayers))
+	}
+
+	layerReader, err := repo.Fetch(ctx, contentLayers[0])
+	if err != nil {
+		return fmt.Errorf("could not fetch content of %s:%s: %w", repo.Reference.String(), tag, err)
+	}
+	defer layerReader.Close()
+
+	if err = os.RemoveAll(directory); err != nil {
+		return err
+	}
+	if err = files.Untgz(directory, io.LimitReader(layerReader, contentLayers[0].Size), OCIMaxExtractedSize, false); err != nil {
+		return fmt.Errorf("could not extract %s:%s: %w", repo.Reference.String(), tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeOCIRegistry serves a single repository with one artifact pushed under several tags
+type fakeOCIRegistry struct {
+	repository string
+	tags       []string
+	manifest   []byte
+	layer      []byte
+}
+
+func newFakeOCIRegistry(repository string, tags []string, content map[string]string) *fakeOCIRegistry {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range content {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(data))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+	layer := buf.Bytes()
+
+	config := []byte("{}")
+	manifest, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeEmptyJSON,
+			Digest:    digest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+		Layers: []ocispec.Descriptor{{
+			MediaType: ocispec.MediaTypeImageLayerGzip,
+			Digest:    digest.FromBytes(layer),
+			Size:      int64(len(layer)),
+		}},
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return &fakeOCIRegistry{repository: repository, tags: tags, manifest: manifest, layer: layer}
+}
+
+func (f *fakeOCIRegistry) ServeHTTP(w nethttp.ResponseWriter, req *nethttp.Request) {
+	prefix := "/v2/" + f.repository + "/"
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		w.WriteHeader(nethttp.StatusNotFound)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+	switch {
+	case path == "tags/list":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": f.repository, "tags": f.tags})
+	case strings.HasPrefix(path, "manifests/"):
+		reference := strings.TrimPrefix(path, "manifests/")
+		found := reference == digest.FromBytes(f.manifest).String()
+		for _, tag := range f.tags {
+			found = found || tag == reference
+		}
+		if !found {
+			w.WriteHeader(nethttp.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(f.manifest).String())
+		_, _ = w.Write(f.manifest)
+	case path == "blobs/"+digest.FromBytes(f.layer).String():
+		_, _ = w.Write(f.layer)
+	default:
+		w.WriteHeader(nethttp.StatusNotFound)
+	}
+}
+
+var _ = Describe("OCI registries", func() {
+	var server *httptest.Server
+	var registry *fakeOCIRegistry
+	var credentials map[string][]byte
+
+	BeforeEach(func() {
+		registry = newFakeOCIRegistry("patterns/mcg", []string{"latest", "1.0.0", "1.1.0", "1.2.0_build.1", "2.0.0"}, map[string]string{
+			"values-global.yaml": "global:\n  pattern: mcg\n",
+		})
+		server = httptest.NewServer(registry)
+		credentials = map[string][]byte{"insecureOCIForceHttp": []byte("true")}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	ociURL := func(repository string) string {
+		return OCIScheme + strings.TrimPrefix(server.URL, "http://") + "/" + repository
+	}
+
+	Context("newOCIRepositorySecretData", func() {
+		It("should turn the credentials into an argo oci repository secret", func() {
+			data := newOCIRepositorySecretData("oci://quay.io/patterns/mcg", map[string][]byte{"username": []byte("user")})
+			Expect(data).To(HaveKeyWithValue("type", []byte("oci")))
+			Expect(data).To(HaveKeyWithValue("url", []byte("oci://quay.io/patterns/mcg")))
+			Expect(data).To(HaveKeyWithValue("username", []byte("user")))
+		})
+
+		It("should strip the scheme of OCI helm repositories", func() {
+			data := newHelmRepositorySecretData("oci://quay.io/patterns", nil)
+			Expect(data).To(HaveKeyWithValue("url", []byte("quay.io/patterns")))
+			Expect(data).To(HaveKeyWithValue("enableOCI", []byte("true")))
+		})
+	})
+
+	Context("resolveOCIVersion", func() {
+		It("should resolve tags and semver ranges", func() {
+			repo, err := newOCIRepository(nil, ociURL("patterns/mcg"), credentials)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(resolveOCIVersion(context.Background(), repo, "latest")).To(Equal("latest"))
+			Expect(resolveOCIVersion(context.Background(), repo, "1.0.0")).To(Equal("1.0.0"))
+			Expect(resolveOCIVersion(context.Background(), repo, "1.x")).To(Equal("1.2.0+build.1"))
+			_, err = resolveOCIVersion(context.Background(), repo, "3.x")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail on a missing repository", func() {
+			repo, err := newOCIRepository(nil, ociURL("patterns/missing"), credentials)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = resolveOCIVersion(context.Background(), repo, "latest")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("pullOCIArtifact", func() {
+		It("should extract the content layer and replace the previous content", func() {
+			repo, err := newOCIRepository(nil, ociURL("patterns/mcg"), credentials)
+			Expect(err).ToNot(HaveOccurred())
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "stale.yaml"), []byte("stale"), 0o600)).To(Succeed())
+
+			Expect(pullOCIArtifact(context.Background(), repo, "1.2.0+build.1", dir)).To(Succeed())
+			Expect(filepath.Join(dir, "values-global.yaml")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "stale.yaml")).ToNot(BeAnExistingFile())
+
+			Expect(pullOCIArtifact(context.Background(), repo, "9.9.9", dir)).ToNot(Succeed())
+		})
+	})
+
+	Context("PatternReconciler", func() {
+		var reconciler *PatternReconciler
+		var pattern *api.Pattern
+
+		BeforeEach(func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "oci-creds", Namespace: "pattern-ns"},
+				Data:       credentials,
+			}
+			reconciler = &PatternReconciler{
+				fullClient: kubeclient.NewSimpleClientset(secret),
+			}
+			enabled := true
+			pattern = &api.Pattern{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"},
+				Spec: api.PatternSpec{
+					ClusterGroupName: "hub",
+					MultiSourceConfig: api.MultiSourceConfig{
+						Enabled:                            &enabled,
+						HelmRepoUrl:                        ociURL("patterns"),
+						PatternOCIUrl:                      ociURL("patterns/mcg"),
+						PatternOCIVersion:                  "1.0.*",
+						HelmRepoCredentialsSecret:          "oci-creds",
+						HelmRepoCredentialsSecretNamespace: "pattern-ns",
+					},
+				},
+				Status: api.PatternStatus{
+					LocalCheckoutPath: filepath.Join(GinkgoT().TempDir(), "checkout"),
+				},
+			}
+		})
+
+		It("should pull the pattern OCI artifact into the local checkout path", func() {
+			Expect(reconciler.getLocalGit(pattern)).To(BeEmpty())
+			Expect(pattern.Status.PatternOCIVersion).To(Equal("1.0.0"))
+			Expect(filepath.Join(pattern.Status.LocalCheckoutPath, "values-global.yaml")).To(BeAnExistingFile())
+		})
+
+		It("should require multisource", func() {
+			*pattern.Spec.MultiSourceConfig.Enabled = false
+			_, err := reconciler.getLocalGit(pattern)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should resolve the clustergroup chart version against the OCI tags", func() {
+			registry.repository = "patterns/clustergroup"
+			pattern.Spec.MultiSourceConfig.ClusterGroupChartVersion = "1.1.*"
+			changed, err := reconciler.resolveClusterGroupChartVersion(pattern)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(pattern.Status.ClusterGroupChartVersion).To(Equal("1.1.0"))
+		})
+
+		It("should create the argo repository secrets", func() {
+			Expect(reconciler.copyHelmRepoSecret(pattern, "vp-gitops")).To(Succeed())
+			helmSecret, err := reconciler.fullClient.CoreV1().Secrets("vp-gitops").Get(context.Background(), HelmRepoCredentialsSecretName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(helmSecret.Data).To(HaveKeyWithValue("enableOCI", []byte("true")))
+			ociSecret, err := reconciler.fullClient.CoreV1().Secrets("vp-gitops").Get(context.Background(), PatternOCICredentialsSecretName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ociSecret.Data).To(HaveKeyWithValue("type", []byte("oci")))
+		})
+
+		It("should only create the helm secret for anonymous registries", func() {
+			pattern.Spec.MultiSourceConfig.HelmRepoCredentialsSecret = ""
+			Expect(needsHelmRepoSecret(pattern)).To(BeTrue())
+			Expect(reconciler.copyHelmRepoSecret(pattern, "vp-gitops")).To(Succeed())
+			_, err := reconciler.fullClient.CoreV1().Secrets("vp-gitops").Get(context.Background(), PatternOCICredentialsSecretName, metav1.GetOptions{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
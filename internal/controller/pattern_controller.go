@@ -17,25 +17,21 @@ limitations under the License.
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
 	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/console"
 
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -43,6 +39,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -71,6 +68,10 @@ import (
 
 const ReconcileLoopRequeueTime = 180 * time.Second
 
+// How soon the next step runs once a reconcile step succeeded. Status writes do not trigger a
+// reconcile, see SetupWithManager
+const ReconcileStepRequeueTime = time.Second
+
 // PatternReconciler reconciles a Pattern object
 type PatternReconciler struct {
 	client.Client
@@ -89,6 +90,9 @@ type PatternReconciler struct {
 	operatorClient  operatorclient.OperatorV1Interface
 	gitOperations   GitOperations
 	giteaOperations GiteaOperations
+	acmSearch       acmsearch.Client
+	recorder        record.EventRecorder
+	restMapper      meta.RESTMapper
 
 	mgr                ctrl.Manager
 	ctrl               crcontroller.Controller
@@ -101,20 +105,28 @@ type PatternReconciler struct {
 //+kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=list;get
 //+kubebuilder:rbac:groups=config.openshift.io,resources=ingresses,verbs=list;get
 //+kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=list;get
+//+kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=list;get
+//+kubebuilder:rbac:groups=config.openshift.io,resources=imagedigestmirrorsets;imagetagmirrorsets,verbs=list;get
+//+kubebuilder:rbac:groups=operator.openshift.io,resources=imagecontentsourcepolicies,verbs=list;get
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=list;watch;delete;update;get;create;patch
 //+kubebuilder:rbac:groups=console.openshift.io,resources=consolelinks,verbs=get;list;create;update;patch;delete
 //+kubebuilder:rbac:groups=argoproj.io,resources=argocds,verbs=list;watch;get;create;update;patch;delete
-//+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=list;get;create;update;patch;delete
+//+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=list;watch;get;create;update;patch;delete
+//+kubebuilder:rbac:groups=argoproj.io,resources=appprojects,verbs=get;create;update;delete
 //+kubebuilder:rbac:groups=operators.coreos.com,resources=subscriptions,verbs=list;get;create;update;patch;delete
 //+kubebuilder:rbac:groups=operators.coreos.com,resources=operatorgroups,verbs=list;get;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="operator.open-cluster-management.io",resources=multiclusterhubs,verbs=get;list
 //+kubebuilder:rbac:groups=operator.openshift.io,resources="openshiftcontrollermanagers",resources=openshiftcontrollermanagers,verbs=get;list
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;patch;delete;watch
 //+kubebuilder:rbac:groups="view.open-cluster-management.io",resources=managedclusterviews,verbs=create
-//+kubebuilder:rbac:groups="cluster.open-cluster-management.io",resources=managedclusters,verbs=list;delete
+//+kubebuilder:rbac:groups="cluster.open-cluster-management.io",resources=managedclusters,verbs=list;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;patch
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;patch
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -168,8 +180,35 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		patternsOperatorConfig = operatorConfigMap.Data
 	}
 
-	if err := console.CreateOrUpdateCatalog(ctx, r.Client, operatorConfigMap); err != nil {
-		return r.actionPerformed(instance, "unable to create/update catalog deployment", err)
+	// -- Paused: leave everything the operator manages as it is, hand edits included, until the pause is lifted.
+	// A paused pattern that is deleted is still finalized, the pause must not keep the deletion stuck
+	if instance.DeletionTimestamp.IsZero() {
+		if reason := pausedReason(instance, patternsOperatorConfig); reason != "" {
+			return r.reconcilePaused(instance, reason)
+		} else if r.resumeReconcile(instance) {
+			return r.actionPerformed(instance, "resumed reconciliation", nil)
+		}
+	}
+
+	// -- Detect the cluster-wide proxy so that git, helm, ACM search and analytics traffic honor it
+	detectClusterProxy(r.configClient)
+
+	// -- Detect the image and chart mirrors when running disconnected and make sure they answer.
+	// A pattern being deleted deploys nothing, it keeps the mirrors it had
+	if instance.DeletionTimestamp.IsZero() {
+		if err = detectMirrors(r.configClient, r.dynamicClient, patternsOperatorConfig); err != nil {
+			return r.actionPerformed(instance, "invalid disconnected mirror configuration", err)
+		}
+		if err = preflightMirrors(r.fullClient, operatorImages(operatorConfigMap)); err != nil {
+			return r.actionPerformed(instance, "checking disconnected mirrors", err)
+		}
+	}
+
+	// A pattern being deleted may be tearing the catalog down
+	if instance.DeletionTimestamp.IsZero() {
+		if err := console.CreateOrUpdateCatalog(ctx, r.Client, operatorConfigMap); err != nil {
+			return r.actionPerformed(instance, "unable to create/update catalog deployment", err)
+		}
 	}
 
 	// Remove the ArgoCD application on deletion
@@ -180,8 +219,10 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			err = r.Update(context.TODO(), instance)
 			return r.actionPerformed(instance, "updated finalizer", err)
 		}
-	} else if err = r.finalizeObject(instance); err != nil {
+	} else if err = r.finalizeObject(instance, patternsOperatorConfig); err != nil {
 		return r.actionPerformed(instance, "finalize", err)
+	} else if err = r.teardownOperator(instance, patternsOperatorConfig); err != nil {
+		return r.actionPerformed(instance, "operator teardown", err)
 	} else {
 		log.Printf("Removing finalizer from %s\n", instance.Name)
 		controllerutil.RemoveFinalizer(instance, api.PatternFinalizer)
@@ -281,13 +322,27 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return r.actionPerformed(qualifiedInstance, "copying clusterwide git auth secret to namespaced argo", err)
 		}
 	}
+	// Copy the helm repository credentials to the clusterwide argo namespace
+	if needsHelmRepoSecret(qualifiedInstance) {
+		if err = r.copyHelmRepoSecret(qualifiedInstance, getClusterWideArgoNamespace()); err != nil {
+			return r.actionPerformed(qualifiedInstance, "copying helm repository credentials to clusterwide argo", err)
+		}
+	}
 
-	// If you specified OriginRepo then we automatically spawn a gitea instance via a special argo gitea application
-	if qualifiedInstance.Spec.GitConfig.OriginRepo != "" {
-		giteaErr := r.createGiteaInstance(qualifiedInstance, patternsOperatorConfig)
-		if giteaErr != nil {
-			return r.actionPerformed(qualifiedInstance, "error created gitea instance", giteaErr)
+	// Spawn the in-cluster git server and import the upstream repository into it
+	if isInClusterGitServerEnabled(qualifiedInstance) {
+		changed, gitServerErr := r.reconcileInClusterGitServer(qualifiedInstance, patternsOperatorConfig)
+		if gitServerErr != nil {
+			return r.actionPerformed(qualifiedInstance, "error reconciling in-cluster git server", gitServerErr)
+		}
+		if changed {
+			return r.actionPerformed(qualifiedInstance, "updated in-cluster git server status", nil)
 		}
+	} else if qualifiedInstance.Status.EffectiveTargetRepo != "" || qualifiedInstance.Status.GitServer != nil {
+		// The in-cluster git server was disabled, go back to TargetRepo
+		qualifiedInstance.Status.EffectiveTargetRepo = ""
+		qualifiedInstance.Status.GitServer = nil
+		return r.actionPerformed(qualifiedInstance, "disabled in-cluster git server", nil)
 	}
 
 	ret, err := r.getLocalGit(qualifiedInstance)
@@ -295,9 +350,32 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return r.actionPerformed(qualifiedInstance, ret, err)
 	}
 
+	// Pin the applications to the last healthy revision when the current one keeps them degraded
+	if changed, rollbackErr := r.reconcileRollback(qualifiedInstance); rollbackErr != nil {
+		return r.actionPerformed(qualifiedInstance, "reconciling rollback policy", rollbackErr)
+	} else if changed {
+		return r.actionPerformed(qualifiedInstance, "updated rollback status", nil)
+	}
+
+	// Resolve the clustergroup chart locally so a wrong version or bad credentials show up in the status.
+	// This is best effort, argo does its own resolution of the version constraint
+	if *qualifiedInstance.Spec.MultiSourceConfig.Enabled && qualifiedInstance.Spec.MultiSourceConfig.ClusterGroupGitRepoUrl == "" {
+		if changed, resolveErr := r.resolveClusterGroupChartVersion(qualifiedInstance); resolveErr != nil {
+			r.logger.Info("Could not resolve the clustergroup chart version", "error", resolveErr.Error())
+		} else if changed {
+			return r.actionPerformed(qualifiedInstance, "resolved clustergroup chart version", nil)
+		}
+	}
+
 	targetApp := newArgoApplication(qualifiedInstance)
 	_ = controllerutil.SetOwnerReference(qualifiedInstance, targetApp, r.Scheme)
 	app, err := getApplication(r.argoClient, applicationName(qualifiedInstance), clusterWideNS)
+	// The AppProject of the maintenance windows exists before the app of apps is moved to it
+	if changed, windowsErr := r.reconcileMaintenanceWindows(qualifiedInstance, app); windowsErr != nil {
+		return r.actionPerformed(qualifiedInstance, "reconciling maintenance windows", windowsErr)
+	} else if changed {
+		return r.actionPerformed(qualifiedInstance, "updated maintenance windows status", nil)
+	}
 	if app == nil {
 		log.Printf("App not found: %s\n", err.Error())
 		err = createApplication(r.argoClient, targetApp, clusterWideNS)
@@ -325,17 +403,42 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return r.actionPerformed(qualifiedInstance, "copying clusterwide git auth secret to namespaced argo", err)
 		}
 	}
+	if isInClusterGitServerEnabled(qualifiedInstance) {
+		backend, backendErr := r.newGitServerBackend(patternsOperatorConfig)
+		if backendErr == nil {
+			backendErr = r.copyInClusterGitCredentials(backend, qualifiedInstance, applicationName(qualifiedInstance))
+		}
+		if backendErr != nil {
+			return r.actionPerformed(qualifiedInstance, "copying in-cluster git server credentials to namespaced argo", backendErr)
+		}
+	}
+	if needsHelmRepoSecret(qualifiedInstance) {
+		if err = r.copyHelmRepoSecret(qualifiedInstance, applicationName(qualifiedInstance)); err != nil {
+			return r.actionPerformed(qualifiedInstance, "copying helm repository credentials to namespaced argo", err)
+		}
+	}
+	// On-demand refresh and sync of the applications, the only way to sync them with the manual sync policy
+	if changed, syncErr := r.reconcileSyncRequest(qualifiedInstance, app); syncErr != nil {
+		return r.actionPerformed(qualifiedInstance, "requesting application sync", syncErr)
+	} else if changed {
+		return r.actionPerformed(qualifiedInstance, "updated application sync status", nil)
+	}
+
+	// Remember which managed clusters the applications created, the DetachOwned policy detaches them
+	if haveACMHub(r) {
+		if err = r.labelOwnedManagedClusters(context.TODO(), qualifiedInstance); err != nil {
+			return r.actionPerformed(qualifiedInstance, "labeling owned managed clusters", err)
+		}
+	}
+
 	// Perform validation of the site values file(s)
 	if err = r.postValidation(qualifiedInstance); err != nil {
 		return r.actionPerformed(qualifiedInstance, "validation", err)
 	}
 
-	// Update CR if necessary
-	var fUpdate bool
-	fUpdate, err = r.updatePatternCRDetails(qualifiedInstance)
-
-	if err == nil && fUpdate {
-		r.logger.Info("Pattern CR Updated")
+	// Publish what deleting the pattern would remove, when asked to
+	if r.updateDeletionPreview(qualifiedInstance, app) {
+		return r.actionPerformed(qualifiedInstance, "updated deletion preview", nil)
 	}
 
 	// Report loop completion statistics (fire-and-forget, don't interrupt reconcile completion)
@@ -346,7 +449,7 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if qualifiedInstance.Status.LastStep != "reconcile complete" || qualifiedInstance.Status.LastError != "" {
 		qualifiedInstance.Status.LastStep = "reconcile complete"
 		qualifiedInstance.Status.LastError = ""
-		if updateErr := r.Client.Status().Update(context.TODO(), qualifiedInstance); updateErr != nil {
+		if updateErr := r.patchStatus(qualifiedInstance); updateErr != nil {
 			r.logger.Error(updateErr, "Failed to update Pattern status")
 		}
 	}
@@ -355,6 +458,12 @@ func (r *PatternReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		Requeue:      false,
 		RequeueAfter: ReconcileLoopRequeueTime,
 	}
+	// Follow a requested sync closely, pipelines wait on it
+	if qualifiedInstance.Status.Sync != nil && qualifiedInstance.Status.Sync.FinishedAt == nil {
+		result.RequeueAfter = SyncStatusRequeueTime
+	}
+	result.RequeueAfter = maintenanceWindowRequeue(qualifiedInstance.Status.MaintenanceWindow, result.RequeueAfter)
+	result.RequeueAfter = rollbackRequeue(qualifiedInstance, result.RequeueAfter)
 
 	return result, nil
 }
@@ -436,102 +545,6 @@ func (r *PatternReconciler) reconcileGitOpsSubscription(qualifiedInstance *api.P
 	return false, ctrl.Result{}, nil
 }
 
-func (r *PatternReconciler) createGiteaInstance(input *api.Pattern, patternsOperatorConfig PatternsOperatorConfig) error {
-	gitConfig := input.Spec.GitConfig
-	clusterWideNS := getClusterWideArgoNamespace()
-	// The reason we create the vp-gitea namespace and and the
-	// gitea-admin-secret is because otherwise it takes and extremely long time
-	// to reconcile everything because the reconcile loop will be waiting a long time
-	// for the namespace to show up and then the pod will take quite a while to retry
-	// with the gitea-admin-secret mounted into it
-	if !haveNamespace(r.Client, GiteaNamespace) {
-		err := createNamespace(r.fullClient, GiteaNamespace)
-		if err != nil {
-			return fmt.Errorf("error creating %s namespace: %v", GiteaNamespace, err)
-		}
-	}
-	var giteaAdminPassword string
-	giteaAdminPassword, err := GenerateRandomPassword(GiteaDefaultPasswordLen, DefaultRandRead)
-	if err != nil {
-		return fmt.Errorf("error Generating gitea_admin password: %v", err)
-	}
-
-	secretData := map[string][]byte{
-		"username": []byte(GiteaAdminUser),
-		"password": []byte(giteaAdminPassword),
-	}
-	giteaAdminSecret := newSecret(GiteaAdminSecretName, GiteaNamespace, secretData, nil)
-	err = r.Create(context.Background(), giteaAdminSecret)
-	if err != nil && !kerrors.IsAlreadyExists(err) {
-		return fmt.Errorf("could not create Gitea Admin Secret: %v", err)
-	}
-
-	log.Printf("Origin repo is set, creating gitea instance: %s", gitConfig.OriginRepo)
-	giteaApp := newArgoGiteaApplication(input, patternsOperatorConfig)
-	_ = controllerutil.SetOwnerReference(input, giteaApp, r.Scheme)
-	app, err := getApplication(r.argoClient, GiteaApplicationName, clusterWideNS)
-	if app == nil {
-		log.Printf("Gitea app not found: %s\n", err.Error())
-		err = createApplication(r.argoClient, giteaApp, clusterWideNS)
-		return fmt.Errorf("create gitea application: %v", err)
-	} else if ownedBySame(giteaApp, app) {
-		// Check values
-		changed, errApp := updateApplication(r.argoClient, giteaApp, app, clusterWideNS)
-		if changed {
-			if errApp != nil {
-				input.Status.Version = 1 + input.Status.Version
-			}
-			_ = DropLocalGitPaths()
-
-			return fmt.Errorf("updated gitea application: %v", errApp)
-		}
-	} else {
-		// Someone manually removed the owner ref
-		return fmt.Errorf("we no longer own Application %q", giteaApp.Name)
-	}
-	if !haveNamespace(r.Client, GiteaNamespace) {
-		return fmt.Errorf("waiting for giteanamespace creation")
-	}
-
-	// Here we need to call the gitea migration bits
-	// Let's get the GiteaServer route
-	giteaRouteURL, routeErr := getRoute(r.routeClient, GiteaRouteName, GiteaNamespace)
-	if routeErr != nil {
-		return fmt.Errorf("GiteaServer route not ready: %v", routeErr)
-	}
-	// Extract the repository name from the original target repo
-	upstreamRepoName, repoErr := extractRepositoryName(gitConfig.OriginRepo)
-	if repoErr != nil {
-		return fmt.Errorf("error getting target Repo URL: %v", repoErr)
-	}
-
-	giteaRepoURL := fmt.Sprintf("%s/%s/%s", giteaRouteURL, GiteaAdminUser, upstreamRepoName)
-	secret, secretErr := getSecret(r.fullClient, GiteaAdminSecretName, GiteaNamespace)
-	if secretErr != nil {
-		return fmt.Errorf("error getting gitea Admin Secret: %v", secretErr)
-	}
-
-	// Let's attempt to migrate the repo to Gitea
-	_, _, err = r.giteaOperations.MigrateGiteaRepo(r.fullClient, string(secret.Data["username"]),
-		string(secret.Data["password"]),
-		input.Spec.GitConfig.OriginRepo,
-		giteaRouteURL)
-	if err != nil {
-		return fmt.Errorf("GiteaServer Migrate Repository Error: %v", err)
-	}
-
-	// Migrate Repo has been done.
-	// Replace the Target Repo with new Gitea Repo URL
-	// and update the pattern CR
-	input.Spec.GitConfig.TargetRepo = giteaRepoURL
-	err = r.Update(context.Background(), input)
-	if err != nil {
-		return fmt.Errorf("update CR Target Repo: %v", err)
-	}
-
-	return nil
-}
-
 func (r *PatternReconciler) preValidation(input *api.Pattern) error {
 	// TARGET_REPO=$(shell git remote show origin | grep Push | sed -e 's/.*URL:[[:space:]]*//' -e 's%:[a-z].*@%@%' -e 's%:%/%' -e 's%git@%https://%' )
 	gc := input.Spec.GitConfig
@@ -540,8 +553,11 @@ func (r *PatternReconciler) preValidation(input *api.Pattern) error {
 			return err
 		}
 	}
-	if gc.TargetRepo != "" {
-		return validGitRepoURL(gc.TargetRepo)
+	if targetRepo := getTargetRepo(input); targetRepo != "" {
+		return validGitRepoURL(targetRepo)
+	}
+	if input.Spec.MultiSourceConfig.PatternOCIUrl != "" {
+		return nil
 	}
 	return fmt.Errorf("TargetRepo cannot be empty")
 	// Check the url is reachable
@@ -625,7 +641,7 @@ func (r *PatternReconciler) applyDefaults(input *api.Pattern) (*api.Pattern, err
 	}
 
 	if output.Spec.GitConfig.Hostname == "" {
-		hostname, err := extractGitFQDNHostname(output.Spec.GitConfig.TargetRepo)
+		hostname, err := extractGitFQDNHostname(getTargetRepo(output))
 		if err != nil {
 			hostname = ""
 		}
@@ -642,8 +658,15 @@ func (r *PatternReconciler) applyDefaults(input *api.Pattern) (*api.Pattern, err
 	if output.Spec.MultiSourceConfig.HelmRepoUrl == "" {
 		output.Spec.MultiSourceConfig.HelmRepoUrl = "https://charts.validatedpatterns.io/"
 	}
+	if output.Spec.MultiSourceConfig.HelmRepoCredentialsSecret != "" && output.Spec.MultiSourceConfig.HelmRepoCredentialsSecretNamespace == "" {
+		output.Spec.MultiSourceConfig.HelmRepoCredentialsSecretNamespace = output.Namespace
+	}
+	if output.Spec.GitConfig.InClusterGitMirror.PushSecret != "" && output.Spec.GitConfig.InClusterGitMirror.PushSecretNamespace == "" {
+		output.Spec.GitConfig.InClusterGitMirror.PushSecretNamespace = output.Namespace
+	}
 
-	localCheckoutPath := getLocalGitPath(output.Spec.GitConfig.TargetRepo)
+	patternRepoURL, _ := getPatternRepo(output)
+	localCheckoutPath := getLocalGitPath(patternRepoURL)
 	if localCheckoutPath != output.Status.LocalCheckoutPath {
 		_ = DropLocalGitPaths()
 	}
@@ -652,21 +675,6 @@ func (r *PatternReconciler) applyDefaults(input *api.Pattern) (*api.Pattern, err
 	return output, nil
 }
 
-func (r *PatternReconciler) updateDeletionPhase(instance *api.Pattern, phase api.PatternDeletionPhase) error {
-	log.Printf("Updating deletion phase to '%s'", phase)
-	instance.Status.DeletionPhase = phase
-	if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
-		return fmt.Errorf("failed to update deletion phase: %w", err)
-	}
-
-	// Re-fetch to get updated status
-	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(instance), instance); err != nil {
-		return fmt.Errorf("failed to re-fetch pattern after phase update: %w", err)
-	}
-
-	return nil
-}
-
 func (r *PatternReconciler) deleteSpokeApps(targetApp, app *argoapi.Application, namespace string) error {
 	log.Printf("Deletion phase: %s - checking if all child applications are gone from spoke", api.DeleteSpokeChildApps)
 
@@ -695,19 +703,59 @@ func (r *PatternReconciler) deleteSpokeApps(targetApp, app *argoapi.Application,
 	}
 
 	// Check if all child applications are gone from spoke
-	allGone, err := r.checkSpokeApplicationsGone(false)
+	remaining, err := r.remainingSpokeApplications(false)
 	if err != nil {
 		return fmt.Errorf("error checking child applications: %w", err)
 	}
 
-	if !allGone {
-		return fmt.Errorf("waiting for child applications to be deleted from spoke clusters")
+	if len(remaining) > 0 {
+		return &deletionWaitError{message: "waiting for child applications to be deleted from spoke clusters", waitingOn: remaining}
+	}
+
+	return nil
+}
+
+func (r *PatternReconciler) deleteSpokeAppOfApps(targetApp, app *argoapi.Application, namespace string) error {
+	log.Printf("Deletion phase: %s - checking if the app of apps are gone from spoke", api.DeleteSpoke)
+
+	changed, errUpdate := updateApplication(r.argoClient, targetApp, app, namespace)
+	if errUpdate != nil {
+		return fmt.Errorf("failed to update application %q for spoke app of apps deletion: %v", app.Name, errUpdate)
+	}
+	if changed {
+		return fmt.Errorf("updated application %q for spoke app of apps deletion", app.Name)
+	}
+
+	if err := syncApplication(r.argoClient, app, false); err != nil {
+		return err
+	}
+
+	childApps, err := getChildApplications(r.argoClient, app)
+	if err != nil {
+		return err
+	}
+
+	// We need to prune policies from acm, to initiate app of apps removal from spoke
+	for _, childApp := range childApps { //nolint:gocritic // rangeValCopy: each iteration copies 992 bytes
+		if err := syncApplication(r.argoClient, &childApp, true); err != nil {
+			return err
+		}
+	}
+
+	// Check if app of apps are gone from spoke
+	remaining, err := r.remainingSpokeApplications(true)
+	if err != nil {
+		return fmt.Errorf("error checking applications: %w", err)
+	}
+
+	if len(remaining) > 0 {
+		return &deletionWaitError{message: "waiting for the app of apps to be deleted from spoke clusters", waitingOn: remaining}
 	}
 
 	return nil
 }
 
-func (r *PatternReconciler) deleteHubApps(targetApp, app *argoapi.Application, namespace string) error {
+func (r *PatternReconciler) deleteHubApps(p *api.Pattern, targetApp, app *argoapi.Application, namespace string) error {
 	log.Printf("Deletion phase: %s - deleting child apps from hub", api.DeleteHubChildApps)
 
 	childApps, err := getChildApplications(r.argoClient, app)
@@ -718,23 +766,30 @@ func (r *PatternReconciler) deleteHubApps(targetApp, app *argoapi.Application, n
 	if len(childApps) == 0 {
 		return nil
 	}
-	// Delete managed clusters (excluding local-cluster)
+	// Delete the managed clusters selected by the ManagedClusterPolicy of the pattern (excluding local-cluster)
 	// These must be removed before hub deletion can proceed because ACM won't delete properly if they exist
 	// we do not care about the error, since we might be on a standalone cluster
 	// Only do this if the pattern is in charge of the acm hub
 
 	if haveACMHub(r) {
-		managedClusters, _ := r.listManagedClusters(context.Background())
+		managedClusters, _ := r.listManagedClusters(context.Background(), p)
 
 		if len(managedClusters) > 0 {
-			deletedCount, err := r.deleteManagedClusters(context.TODO())
+			deletedCount, err := r.deleteManagedClusters(context.TODO(), p)
 			if err != nil {
 				return fmt.Errorf("failed to delete managed clusters: %w", err)
 			}
 
 			if deletedCount > 0 {
 				log.Printf("Deleted %d managed cluster(s), waiting for them to be fully removed", deletedCount)
-				return fmt.Errorf("deleted %d managed cluster(s), waiting for removal to complete before proceeding with hub deletion", deletedCount)
+				var waitingOn []string
+				for _, name := range managedClusters {
+					waitingOn = append(waitingOn, fmt.Sprintf("ManagedCluster %s", name))
+				}
+				return &deletionWaitError{
+					message:   fmt.Sprintf("deleted %d managed cluster(s), waiting for removal to complete before proceeding with hub deletion", deletedCount),
+					waitingOn: waitingOn,
+				}
 			}
 		}
 	}
@@ -752,10 +807,14 @@ func (r *PatternReconciler) deleteHubApps(targetApp, app *argoapi.Application, n
 		return err
 	}
 
-	return fmt.Errorf("waiting %d hub child applications to be removed", len(childApps))
+	var waitingOn []string
+	for i := range childApps {
+		waitingOn = append(waitingOn, fmt.Sprintf("%s/%s", childApps[i].Namespace, childApps[i].Name))
+	}
+	return &deletionWaitError{message: fmt.Sprintf("waiting %d hub child applications to be removed", len(childApps)), waitingOn: waitingOn}
 }
 
-func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
+func (r *PatternReconciler) finalizeObject(instance *api.Pattern, patternsOperatorConfig PatternsOperatorConfig) error {
 	log.Printf("Finalizing pattern object")
 
 	// The object is being deleted and, if prune is enabled, we want to delete all the dependent objects in cascade
@@ -767,6 +826,12 @@ func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
 			log.Printf("\n\x1b[31;1m\tCannot cleanup the ArgoCD application of an invalid pattern: %s\x1b[0m\n", err.Error())
 			return nil
 		}
+		// The phases update the status of qualifiedInstance, keep the caller's copy current since it
+		// records the outcome of the finalizer afterwards
+		defer func() {
+			instance.ResourceVersion = qualifiedInstance.ResourceVersion
+			instance.Status = qualifiedInstance.Status
+		}()
 		// Ensure detection has run for the finalize path
 		detectArgoNamespace(r.dynamicClient)
 		ns := getClusterWideArgoNamespace()
@@ -788,6 +853,15 @@ func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
 		// Initialize deletion phase if not set
 		if qualifiedInstance.Status.DeletionPhase == api.InitializeDeletion {
 			log.Printf("Initializing deletion phase")
+			// Orphan the retained resources before anything is pruned
+			retained, err := r.retainResources(qualifiedInstance, app)
+			if err != nil {
+				return fmt.Errorf("failed to retain resources: %w", err)
+			}
+			if qualifiedInstance.Status.Deletion == nil {
+				qualifiedInstance.Status.Deletion = &api.PatternDeletionStatus{}
+			}
+			qualifiedInstance.Status.Deletion.Retained = retained
 			if haveACMHub(r) {
 				if err := r.updateDeletionPhase(qualifiedInstance, api.DeleteSpokeChildApps); err != nil {
 					return err
@@ -805,7 +879,10 @@ func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
 		// Phase 1: Delete child applications from spoke clusters
 		if qualifiedInstance.Status.DeletionPhase == api.DeleteSpokeChildApps {
 			if err := r.deleteSpokeApps(targetApp, app, ns); err != nil {
-				return err
+				// The spoke applications cannot be reached from the hub, forcing the phase just moves on
+				if escalation, err := r.escalateDeletionPhase(qualifiedInstance, patternsOperatorConfig, err); escalation == deletionWait {
+					return err
+				}
 			}
 
 			if err := r.updateDeletionPhase(qualifiedInstance, api.DeleteSpoke); err != nil {
@@ -817,35 +894,12 @@ func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
 
 		// Phase 2: Delete app of apps from spoke
 		if qualifiedInstance.Status.DeletionPhase == api.DeleteSpoke {
-			changed, errUpdate := updateApplication(r.argoClient, targetApp, app, ns)
-			if errUpdate != nil {
-				return fmt.Errorf("failed to update application %q for spoke app of apps deletion: %v", app.Name, errUpdate)
-			}
-			if changed {
-				return fmt.Errorf("updated application %q for spoke app of apps deletion", app.Name)
-			}
-
-			if err := syncApplication(r.argoClient, app, false); err != nil {
-				return err
-			}
-
-			childApps, err := getChildApplications(r.argoClient, app)
-			if err != nil {
-				return err
-			}
-
-			// We need to prune policies from acm, to initiate app of apps removal from spoke
-			for _, childApp := range childApps { //nolint:gocritic // rangeValCopy: each iteration copies 992 bytes
-				if err := syncApplication(r.argoClient, &childApp, true); err != nil {
+			if err := r.deleteSpokeAppOfApps(targetApp, app, ns); err != nil {
+				if escalation, err := r.escalateDeletionPhase(qualifiedInstance, patternsOperatorConfig, err); escalation == deletionWait {
 					return err
 				}
 			}
 
-			// Check if app of apps are gone from spoke
-			if _, err = r.checkSpokeApplicationsGone(true); err != nil {
-				return fmt.Errorf("error checking applications: %w", err)
-			}
-
 			if err := r.updateDeletionPhase(qualifiedInstance, api.DeleteHubChildApps); err != nil {
 				return err
 			}
@@ -855,8 +909,17 @@ func (r *PatternReconciler) finalizeObject(instance *api.Pattern) error {
 
 		// Phase 3: Delete applications from hub
 		if qualifiedInstance.Status.DeletionPhase == api.DeleteHubChildApps {
-			if err := r.deleteHubApps(targetApp, app, ns); err != nil {
-				return err
+			if err := r.deleteHubApps(qualifiedInstance, targetApp, app, ns); err != nil {
+				escalation, err := r.escalateDeletionPhase(qualifiedInstance, patternsOperatorConfig, err)
+				if escalation == deletionWait {
+					return err
+				}
+				// Removing the argo finalizers orphans whatever argo did not prune, only an explicit request does it
+				if escalation == deletionForced {
+					if err := r.forceRemoveChildApplications(qualifiedInstance, app); err != nil {
+						return err
+					}
+				}
 			}
 
 			if err := r.updateDeletionPhase(qualifiedInstance, api.DeleteHub); err != nil {
@@ -916,13 +979,20 @@ func (r *PatternReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if r.routeClient, err = routeclient.NewForConfig(r.config); err != nil {
 		return err
 	}
+	if r.acmSearch, err = acmsearch.NewFromEnvironment(clusterProxyFunc); err != nil {
+		return err
+	}
 	r.gitOperations = &GitOperationsImpl{}
 	r.giteaOperations = &GiteaOperationsImpl{}
+	r.recorder = mgr.GetEventRecorderFor("patterns-operator")
+	r.restMapper = mgr.GetRESTMapper()
 	r.mgr = mgr
 
 	var ctrlErr error
 	r.ctrl, ctrlErr = ctrl.NewControllerManagedBy(mgr).
-		For(&api.Pattern{}).
+		// Only spec and annotation changes, the status written by the reconcile loop and the applications
+		// controller would otherwise trigger a reconcile of its own
+		For(&api.Pattern{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		// Use Watches instead of Owns: EnqueueRequestForOwner runs RESTMapping on the owner ref; failures
 		// there enqueue nothing and can be hard to spot. We only care about the operator config ConfigMap
 		// and a singleton Pattern in the operator namespace (enforced by webhook), so map directly.
@@ -1028,7 +1098,7 @@ func (r *PatternReconciler) onReconcileErrorWithRequeue(p *api.Pattern, reason s
 		log.Printf("\x1b[34;1m\tReconcile step %q complete\x1b[0m\n", reason)
 	}
 
-	updateErr := r.Client.Status().Update(context.TODO(), p)
+	updateErr := r.patchStatus(p)
 	if updateErr != nil {
 		r.logger.Error(updateErr, "Failed to update Pattern status")
 		return reconcile.Result{}, updateErr
@@ -1041,6 +1111,24 @@ func (r *PatternReconciler) onReconcileErrorWithRequeue(p *api.Pattern, reason s
 	return reconcile.Result{}, err
 }
 
+// patchStatus stores the status of p with a merge patch against the stored pattern. The fields the
+// applications controller maintains are taken from the stored pattern, a stale copy never reverts them
+func (r *PatternReconciler) patchStatus(p *api.Pattern) error {
+	stored := &api.Pattern{}
+	if err := r.Get(context.TODO(), client.ObjectKeyFromObject(p), stored); err != nil {
+		return err
+	}
+	patched := stored.DeepCopy()
+	patched.Status = *p.Status.DeepCopy()
+	keepApplicationsStatus(&patched.Status, &stored.Status)
+	if err := r.Client.Status().Patch(context.TODO(), patched, client.MergeFrom(stored)); err != nil {
+		return err
+	}
+	p.Status = patched.Status
+	p.ResourceVersion = patched.ResourceVersion
+	return nil
+}
+
 // detectArgoNamespace determines whether this is a legacy upgrade or greenfield deploy
 // by checking if the legacy ArgoCD CR exists. Sets the package-level active ArgoCD
 // namespace/name accordingly.
@@ -1064,233 +1152,29 @@ func (r *PatternReconciler) actionPerformed(p *api.Pattern, reason string, err e
 		delay := time.Minute * 2
 		return r.onReconcileErrorWithRequeue(p, reason, err, &delay)
 	}
-	return r.onReconcileErrorWithRequeue(p, reason, err, nil)
-}
-
-// updatePatternCRDetails compares the current CR Status.Applications array
-// against the instance.Status.Applications array.
-// Returns true if the CR was updated else it returns false
-func (r *PatternReconciler) updatePatternCRDetails(input *api.Pattern) (bool, error) {
-	fUpdateCR := false
-	var labelFilter = "validatedpatterns.io/pattern=" + input.Name
-
-	// Copy just the applications
-	// Used to compare against input which will be updated with
-	// current application status
-	existingApplications := input.Status.DeepCopy().Applications
-
-	// Retrieving all applications that contain the label
-	// oc get Applications -A -l validatedpatterns.io/pattern=<pattern-name>
-	//
-	// The VP framework adds the label to each application it creates.
-	applications, err := r.argoClient.ArgoprojV1alpha1().Applications("").List(context.Background(),
-		metav1.ListOptions{
-			LabelSelector: labelFilter,
-		})
-	if err != nil {
-		return false, err
-	}
-
-	// Reset the array
-	input.Status.Applications = nil
-
-	// Loop through the Pattern Applications and append the details to the Applications array
-	// into input
-	for _, app := range applications.Items { //nolint:gocritic // rangeValCopy: each iteration copies 936 bytes
-		// Add Application information to ApplicationInfo struct
-		var applicationInfo = api.PatternApplicationInfo{
-			Name:            app.Name,
-			Namespace:       app.Namespace,
-			AppHealthStatus: string(app.Status.Health.Status),
-			AppSyncStatus:   string(app.Status.Sync.Status),
-		}
-
-		// Now let's append the Application Information
-		input.Status.Applications = append(input.Status.Applications, applicationInfo)
-	}
-
-	// Check to see if the Pattern CR has a list of Applications
-	// If it doesn't and we have a list of Applications
-	// Let's update the Pattern CR and set the update flag to true
-	if len(existingApplications) != len(input.Status.Applications) {
-		fUpdateCR = true
-	} else {
-		// Compare the array items in the CR for the applications
-		// with the current instance array
-		for _, value := range input.Status.Applications {
-			for _, existingValue := range existingApplications {
-				// Check if AppSyncStatus or AppHealthStatus have been updated
-				if value.Name == existingValue.Name &&
-					(value.AppSyncStatus != existingValue.AppSyncStatus ||
-						value.AppHealthStatus != existingValue.AppHealthStatus) {
-					fUpdateCR = true
-					break // We found a difference break out
-				}
-			}
-		}
-	}
-
-	// Update the Pattern CR if difference was found
-	if fUpdateCR {
-		input.Status.LastStep = `update pattern application status`
-		// Now let's update the CR with the application status data.
-		err := r.Client.Status().Update(context.Background(), input)
-		if err != nil {
-			return false, err
-		}
-		return true, nil
-	}
-
-	return false, nil
+	delay := ReconcileStepRequeueTime
+	return r.onReconcileErrorWithRequeue(p, reason, err, &delay)
 }
 
-// checkSpokeApplicationsGone checks if all applications are gone from spoke clusters
-// passing appOfApps true will check the app of app instead of child apps
+// remainingSpokeApplications returns the applications, as namespace/name in cluster, that are still
+// present on the spoke clusters. Passing appOfApps true returns the app of apps instead of the child apps
 // The operator runs on the hub cluster and needs to check spoke clusters through ACM Search Service
-// Returns true if all child applications are gone, false otherwise
-func (r *PatternReconciler) checkSpokeApplicationsGone(appOfApps bool) (bool, error) {
-	// Running locally: use localhost with env var set to "https://localhost:4010/searchapi/graphql" and port-forward
-	// User should run: kubectl port-forward -n open-cluster-management svc/search-search-api 4010:4010
-	searchURL := os.Getenv("ACM_SEARCH_API_URL")
-	if searchURL == "" {
-		searchNamespace := "open-cluster-management" // Default namespace for ACM
-		searchURL = fmt.Sprintf("https://search-search-api.%s.svc.cluster.local:4010/searchapi/graphql", searchNamespace)
-	}
-
-	parsedURL, err := url.Parse(searchURL)
-	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") {
-		return false, fmt.Errorf("invalid search API URL: %s", searchURL)
-	}
-	cleanURL := url.URL{
-		Scheme: parsedURL.Scheme,
-		Host:   parsedURL.Host,
-		Path:   parsedURL.Path,
-	}
-
-	token := os.Getenv("ACM_SEARCH_API_TOKEN")
-	if token == "" {
-		var tokenBytes []byte
-		var err error
-
-		tokenPath := "/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec
-
-		if tokenBytes, err = os.ReadFile(tokenPath); err != nil {
-			return false, fmt.Errorf("failed to read serviceaccount token: %w", err)
-		}
-		token = string(tokenBytes)
-	}
-
-	// Build GraphQL query to search for Applications
+func (r *PatternReconciler) remainingSpokeApplications(appOfApps bool) ([]string, error) {
 	// Filter out local-cluster apps and app of apps (based on namespace)
 	ns := []string{fmt.Sprintf("!%s", getClusterWideArgoNamespace())}
 	if appOfApps {
 		ns = []string{getClusterWideArgoNamespace()}
 	}
-	query := map[string]any{
-		"operationName": "searchResult",
-		"query":         "query searchResult($input: [SearchInput]) { searchResult: search(input: $input) { items related { kind items } } }",
-		"variables": map[string]any{
-			"input": []map[string]any{
-				{
-					"filters": []map[string]any{
-						{
-							"property": "apigroup",
-							"values":   []string{"argoproj.io"},
-						},
-						{
-							"property": "kind",
-							"values":   []string{"Application"},
-						},
-						{
-							"property": "cluster",
-							"values":   []string{"!local-cluster"},
-						},
-						{
-							"property": "namespace",
-							"values":   ns,
-						},
-					},
-					"relatedKinds": []string{"Application"},
-					"limit":        20000,
-				},
-			},
-		},
-	}
-
-	// Marshal query to JSON
-	queryJSON, err := json.Marshal(query)
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal GraphQL query: %w", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(context.Background(), "POST", cleanURL.String(), bytes.NewBuffer(queryJSON))
-	if err != nil {
-		return false, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	// Create HTTP client
-	// Use insecure TLS (self-signed certs)
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, //nolint:gosec
-			},
-		},
-	}
-
-	// Make the request
-	resp, err := httpClient.Do(req) //nolint:gosec // URL is constructed from a known internal service endpoint
-	if err != nil {
-		return false, fmt.Errorf("failed to make HTTP request to search service: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	apps, err := r.acmSearch.Applications(context.Background(), acmsearch.ApplicationQuery{Namespaces: ns})
 	if err != nil {
-		return false, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("search service returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse JSON response
-	type SearchAPIResponse struct {
-		Data struct {
-			SearchResult []struct {
-				Items []struct {
-					Name      string `json:"name"`
-					Namespace string `json:"namespace"`
-					Cluster   string `json:"cluster"`
-				} `json:"items"`
-			} `json:"searchResult"`
-		} `json:"data"`
-	}
-	var searchResponse SearchAPIResponse
-	if err := json.Unmarshal(body, &searchResponse); err != nil {
-		return false, fmt.Errorf("failed to parse JSON response: %w", err)
-	}
-
-	var remote_app_names []string
-	if searchResult := searchResponse.Data.SearchResult; len(searchResult) > 0 {
-		for _, item := range searchResult[0].Items {
-			remote_app_names = append(remote_app_names, fmt.Sprintf("%s/%s in %s", item.Namespace, item.Name, item.Cluster))
-		}
+		return nil, err
 	}
 
-	if len(remote_app_names) != 0 {
-		return false, fmt.Errorf("spoke cluster apps still exist: %s", remote_app_names)
+	var remoteAppNames []string
+	for _, item := range apps {
+		remoteAppNames = append(remoteAppNames, fmt.Sprintf("%s/%s in %s", item.Namespace, item.Name, item.Cluster))
 	}
-
-	return true, nil
+	return remoteAppNames, nil
 }
 
 func (r *PatternReconciler) authGitFromSecret(namespace, secret string) (map[string][]byte, error) {
@@ -1307,8 +1191,45 @@ func (r *PatternReconciler) copyAuthGitSecret(secretNamespace, secretName, destN
 	if err != nil {
 		return err
 	}
-	newSecretCopy := newSecret(destSecretName, destNamespace, sourceSecret, map[string]string{"argocd.argoproj.io/secret-type": "repository"})
-	_, err = r.fullClient.CoreV1().Secrets(destNamespace).Get(context.TODO(), destSecretName, metav1.GetOptions{})
+	return r.createOrUpdateRepositorySecret(destNamespace, destSecretName, sourceSecret)
+}
+
+// needsHelmRepoSecret returns true when argo needs a repository secret for HelmRepoUrl, either
+// because it is private or because it is an OCI registry, which argo cannot detect by itself
+func needsHelmRepoSecret(p *api.Pattern) bool {
+	return p.Spec.MultiSourceConfig.HelmRepoCredentialsSecret != "" || isOCIURL(p.Spec.MultiSourceConfig.HelmRepoUrl)
+}
+
+func (r *PatternReconciler) getHelmRepoCredentials(p *api.Pattern) (map[string][]byte, error) {
+	if p.Spec.MultiSourceConfig.HelmRepoCredentialsSecret == "" {
+		return nil, nil
+	}
+	return r.authGitFromSecret(p.Spec.MultiSourceConfig.HelmRepoCredentialsSecretNamespace, p.Spec.MultiSourceConfig.HelmRepoCredentialsSecret)
+}
+
+// copyHelmRepoSecret copies the helm repository credentials of the pattern to destNamespace
+// as an argo helm repository secret for HelmRepoUrl and, when the pattern is sourced from
+// a private OCI artifact, as an argo OCI repository secret for PatternOCIUrl
+func (r *PatternReconciler) copyHelmRepoSecret(p *api.Pattern, destNamespace string) error {
+	credentials, err := r.getHelmRepoCredentials(p)
+	if err != nil {
+		return err
+	}
+	data := newHelmRepositorySecretData(getHelmRepoURL(p), credentials)
+	if err = r.createOrUpdateRepositorySecret(destNamespace, HelmRepoCredentialsSecretName, data); err != nil {
+		return err
+	}
+	if p.Spec.MultiSourceConfig.PatternOCIUrl != "" && credentials != nil {
+		patternRepoURL, _ := getPatternRepo(p)
+		data = newOCIRepositorySecretData(patternRepoURL, credentials)
+		return r.createOrUpdateRepositorySecret(destNamespace, PatternOCICredentialsSecretName, data)
+	}
+	return nil
+}
+
+func (r *PatternReconciler) createOrUpdateRepositorySecret(destNamespace, destSecretName string, data map[string][]byte) error {
+	newSecretCopy := newSecret(destSecretName, destNamespace, data, map[string]string{"argocd.argoproj.io/secret-type": "repository"})
+	_, err := r.fullClient.CoreV1().Secrets(destNamespace).Get(context.TODO(), destSecretName, metav1.GetOptions{})
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Resource does not exist, create it
@@ -1326,9 +1247,104 @@ func (r *PatternReconciler) copyAuthGitSecret(secretNamespace, secretName, destN
 	return err
 }
 
+// resolveClusterGroupChartVersion resolves the clustergroup chart version constraint against
+// the helm repository, using the helm repository credentials when present, and records it in
+// the status. An exact version is recorded as it is and the versions in the repository are
+// cached for ChartVersionsCacheTTL. Returns true when the status changed
+func (r *PatternReconciler) resolveClusterGroupChartVersion(p *api.Pattern) (bool, error) {
+	version := getClusterGroupChartVersion(p)
+	if isChartVersionConstraint(version) {
+		credentials, err := r.getHelmRepoCredentials(p)
+		if err != nil {
+			return false, err
+		}
+
+		helmRepoURL := getHelmRepoURL(p)
+		chartURL := strings.TrimSuffix(helmRepoURL, "/") + "/" + ClusterGroupChartName
+		var versions []string
+		switch {
+		case isOCIURL(helmRepoURL):
+			versions, err = getChartVersions(chartURL, func() ([]string, error) {
+				repo, repoErr := newOCIRepository(r.fullClient, chartURL, credentials)
+				if repoErr != nil {
+					return nil, repoErr
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), ContextTimeout)
+				defer cancel()
+				return getOCITags(ctx, repo)
+			})
+		case isOCIHelmRepository(credentials):
+			// OCI registries configured via enableOCI only, we leave the resolution to argo
+			return false, nil
+		default:
+			versions, err = getChartVersions(chartURL, func() ([]string, error) {
+				index, indexErr := getHelmRepoIndex(r.fullClient, helmRepoURL, credentials)
+				if indexErr != nil {
+					return nil, indexErr
+				}
+				return helmChartVersions(index, ClusterGroupChartName)
+			})
+		}
+		if err != nil {
+			return false, err
+		}
+		if version, err = resolveTagVersion(versions, version); err != nil {
+			return false, err
+		}
+	}
+	if p.Status.ClusterGroupChartVersion == version {
+		return false, nil
+	}
+	p.Status.ClusterGroupChartVersion = version
+	return true, nil
+}
+
+// getLocalOCI resolves the version of the pattern OCI artifact and extracts it into the local
+// checkout path, so the rest of the reconcile loop can treat it like a git checkout
+func (r *PatternReconciler) getLocalOCI(p *api.Pattern) (string, error) {
+	ociURL, _ := getPatternRepo(p)
+	if !isOCIURL(ociURL) {
+		return "validating pattern OCI url", fmt.Errorf("patternOCIUrl must start with %s: %s", OCIScheme, ociURL)
+	}
+	if !*p.Spec.MultiSourceConfig.Enabled {
+		return "validating pattern OCI url", fmt.Errorf("patternOCIUrl requires multiSourceConfig.enabled")
+	}
+	credentials, err := r.getHelmRepoCredentials(p)
+	if err != nil {
+		return "obtaining OCI registry credentials from secret", err
+	}
+	repo, err := newOCIRepository(r.fullClient, ociURL, credentials)
+	if err != nil {
+		return "creating OCI registry client", err
+	}
+
+	requested := p.Spec.MultiSourceConfig.PatternOCIVersion
+	if requested == "" {
+		requested = OCIDefaultVersion
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), OCIPullTimeout)
+	defer cancel()
+	version, err := resolveOCIVersion(ctx, repo, requested)
+	if err != nil {
+		return "resolving pattern OCI version", err
+	}
+
+	if _, statErr := os.Stat(p.Status.LocalCheckoutPath); os.IsNotExist(statErr) || version != p.Status.PatternOCIVersion {
+		fmt.Printf("Pulling %s:%s into %s\n", ociURL, version, p.Status.LocalCheckoutPath)
+		if err = pullOCIArtifact(ctx, repo, version, p.Status.LocalCheckoutPath); err != nil {
+			return "pulling pattern OCI artifact", err
+		}
+	}
+	p.Status.PatternOCIVersion = version
+	return "", nil
+}
+
 func (r *PatternReconciler) getLocalGit(p *api.Pattern) (string, error) {
 	var gitAuthSecret map[string][]byte
 	var err error
+	if p.Spec.MultiSourceConfig.PatternOCIUrl != "" {
+		return r.getLocalOCI(p)
+	}
 	fmt.Printf("getLocalGit: %s", p.Status.LocalCheckoutPath)
 	if p.Spec.GitConfig.TokenSecret != "" {
 		if gitAuthSecret, err = r.authGitFromSecret(p.Spec.GitConfig.TokenSecretNamespace, p.Spec.GitConfig.TokenSecret); err != nil {
@@ -1345,9 +1361,10 @@ func (r *PatternReconciler) getLocalGit(p *api.Pattern) (string, error) {
 		fmt.Printf("Error while appending trusted-ca-bundle configmap to file: %v", err)
 	}
 
+	targetRepo := getTargetRepo(p)
 	gitDir := filepath.Join(p.Status.LocalCheckoutPath, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		err = cloneRepo(r.fullClient, r.gitOperations, p.Spec.GitConfig.TargetRepo, p.Status.LocalCheckoutPath, gitAuthSecret)
+		err = cloneRepo(r.fullClient, r.gitOperations, targetRepo, p.Status.LocalCheckoutPath, gitAuthSecret)
 		if err != nil {
 			return "cloning pattern repo", err
 		}
@@ -1356,19 +1373,19 @@ func (r *PatternReconciler) getLocalGit(p *api.Pattern) (string, error) {
 		if err != nil {
 			return "getting remote URL pattern repo", err
 		}
-		if localURL != p.Spec.GitConfig.TargetRepo {
+		if localURL != targetRepo {
 			fmt.Printf("Locally cloned URL is different from what is in the Spec, blowing away the folder and recloning")
 			err = os.RemoveAll(gitDir)
 			if err != nil {
 				return "failed to remove locally cloned folder", err
 			}
-			err = cloneRepo(r.fullClient, r.gitOperations, p.Spec.GitConfig.TargetRepo, p.Status.LocalCheckoutPath, gitAuthSecret)
+			err = cloneRepo(r.fullClient, r.gitOperations, targetRepo, p.Status.LocalCheckoutPath, gitAuthSecret)
 			if err != nil {
 				return "cloning pattern repo after removal", err
 			}
 		}
 	}
-	if err := checkoutRevision(r.fullClient, r.gitOperations, p.Spec.GitConfig.TargetRepo, p.Status.LocalCheckoutPath,
+	if err := checkoutRevision(r.fullClient, r.gitOperations, targetRepo, p.Status.LocalCheckoutPath,
 		p.Spec.GitConfig.TargetRevision, gitAuthSecret); err != nil {
 		return "checkout target revision", err
 	}
@@ -19,10 +19,14 @@ package controllers
 import (
 	"context"
 	"os"
+	"time"
 
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-logr/logr"
 	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	v1 "github.com/openshift/api/config/v1"
@@ -35,11 +39,17 @@ import (
 	kubeclient "k8s.io/client-go/kubernetes/fake"
 
 	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -91,6 +101,251 @@ var _ = Describe("pattern controller", func() {
 	})
 })
 
+var _ = Describe("Pattern deletion phases", func() {
+	var reconciler *PatternReconciler
+	var search *acmsearch.FakeServer
+	var argoClient *argofake.Clientset
+	var recorder *record.FakeRecorder
+	var operatorConfig PatternsOperatorConfig
+	spokeChildApp := acmsearch.Application{Name: "config-demo", Namespace: "config-demo", Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Healthy"}
+	spokeAppOfApps := acmsearch.Application{Name: "main-region-one", Namespace: ApplicationNamespace, Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Healthy"}
+
+	newDynamicClient := func(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "multiclusterhubs"}: "MultiClusterHubList",
+			{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}:   "ManagedClusterList",
+		}, objects...)
+	}
+
+	BeforeEach(func() {
+		pattern := buildPatternManifest()
+		pattern.Annotations = map[string]string{api.PruneAnnotation: "true"}
+		reconciler = newFakeReconciler()
+		reconciler.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pattern).WithStatusSubresource(&api.Pattern{}).Build()
+		recorder = reconciler.recorder.(*record.FakeRecorder)
+		operatorConfig = nil
+		reconciler.dynamicClient = newDynamicClient(&unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "operator.open-cluster-management.io/v1",
+			"kind":       "MultiClusterHub",
+			"metadata": map[string]any{
+				"name":        "multiclusterhub",
+				"namespace":   "open-cluster-management",
+				"annotations": map[string]any{"patterns.gitops.validatedpatterns.io/managed": "true"},
+			},
+		}})
+
+		qualified, err := reconciler.applyDefaults(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		app := newArgoApplication(qualified)
+		// The finalizer compares the owner references of an application built the same way
+		_ = controllerutil.SetOwnerReference(qualified, app, scheme.Scheme)
+		app.Namespace = ApplicationNamespace
+		argoClient = argofake.NewSimpleClientset(app)
+		reconciler.argoClient = argoClient
+
+		search = acmsearch.NewFakeServer("test-token", spokeChildApp, spokeAppOfApps)
+		reconciler.acmSearch, err = acmsearch.New(acmsearch.Options{URL: search.SearchURL(), Token: "test-token"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if search != nil {
+			search.Close()
+		}
+	})
+
+	// finalize runs the finalizer until the deletion phase changes, which can take a few
+	// passes since each one performs at most one action, and returns the last error
+	finalize := func() (api.PatternDeletionPhase, error) {
+		var err error
+		p := &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), patternNamespaced, p)).To(Succeed())
+		start := p.Status.DeletionPhase
+		for range 5 {
+			err = reconciler.finalizeObject(p, operatorConfig)
+			Expect(reconciler.Get(context.Background(), patternNamespaced, p)).To(Succeed())
+			if err == nil || p.Status.DeletionPhase != start {
+				break
+			}
+		}
+		return p.Status.DeletionPhase, err
+	}
+
+	It("should wait for the spoke applications to go before cleaning up the hub", func() {
+		phase, err := finalize()
+		Expect(phase).To(Equal(api.DeleteSpokeChildApps))
+		Expect(err).To(MatchError(ContainSubstring("initialized deletion phase")))
+
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteSpokeChildApps))
+		Expect(err).To(MatchError(ContainSubstring("config-demo/config-demo in region-one")))
+
+		search.SetApplications(spokeAppOfApps)
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(err).To(MatchError(ContainSubstring("transitioning to DeleteSpoke phase")))
+
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(err).To(MatchError(ContainSubstring("main-region-one in region-one")))
+
+		search.SetApplications()
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		Expect(err).To(MatchError(ContainSubstring("transitioning to DeleteHubChildApps phase")))
+
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteHub))
+		Expect(err).To(MatchError(ContainSubstring("transitioning to DeleteHub phase")))
+
+		_, err = finalize()
+		Expect(err).ToNot(HaveOccurred())
+		apps, err := argoClient.ArgoprojV1alpha1().Applications("").List(context.Background(), metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(apps.Items).To(BeEmpty())
+	})
+
+	It("should report when the search service cannot be queried", func() {
+		search.SetToken("another-token")
+		_, _ = finalize()
+		_, err := finalize()
+		Expect(err).To(MatchError(ContainSubstring(acmsearch.ErrUnauthorized.Error())))
+	})
+
+	getPattern := func() *api.Pattern {
+		p := &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), patternNamespaced, p)).To(Succeed())
+		return p
+	}
+
+	forceDeletion := func(request string) {
+		p := getPattern()
+		p.Annotations[api.ForceDeletionAnnotation] = request
+		Expect(reconciler.Update(context.Background(), p)).To(Succeed())
+	}
+
+	It("should report what the current phase is waiting on", func() {
+		_, _ = finalize()
+		_, err := finalize()
+		Expect(err).To(HaveOccurred())
+
+		deletion := getPattern().Status.Deletion
+		Expect(deletion).ToNot(BeNil())
+		Expect(deletion.WaitingOn).To(Equal([]string{"config-demo/config-demo in region-one"}))
+		Expect(deletion.Message).To(Equal("waiting for child applications to be deleted from spoke clusters"))
+		Expect(deletion.PhaseDeadline).To(BeNil())
+		Expect(recorder.Events).To(BeEmpty())
+
+		operatorConfig = PatternsOperatorConfig{"deletion.spokeChildAppsTimeout": "30m"}
+		_, _ = finalize()
+		deletion = getPattern().Status.Deletion
+		Expect(deletion.PhaseDeadline.Sub(deletion.PhaseStartTime.Time)).To(Equal(30 * time.Minute))
+	})
+
+	It("should move to the next phase when forced", func() {
+		_, _ = finalize()
+		forceDeletion("1")
+		phase, _ := finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(recorder.Events).To(Receive(Equal("Warning DeletionPhaseForced Forcing deletion phase DeleteSpokeChildApps as requested by " +
+			api.ForceDeletionAnnotation + ", still waiting on: config-demo/config-demo in region-one")))
+		Expect(getPattern().Status.Deletion.LastForceRequest).To(Equal("1"))
+
+		// The same request does not force the following phases
+		phase, err := finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(err).To(MatchError(ContainSubstring("main-region-one in region-one")))
+
+		forceDeletion("2")
+		phase, _ = finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+	})
+
+	It("should move to the next phase once it times out", func() {
+		operatorConfig = PatternsOperatorConfig{"deletion.spokeChildAppsTimeout": "1ns"}
+		_, _ = finalize()
+		phase, _ := finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning DeletionPhaseTimedOut Deletion phase DeleteSpokeChildApps timed out after 1ns")))
+
+		operatorConfig = PatternsOperatorConfig{"deletion.spokeTimeout": "0"}
+		phase, _ = finalize()
+		Expect(phase).To(Equal(api.DeleteSpoke))
+		Expect(getPattern().Status.Deletion.PhaseDeadline).To(BeNil())
+	})
+
+	It("should remove the finalizers of the hub child applications when forced", func() {
+		reconciler.dynamicClient = newDynamicClient()
+		qualified, err := reconciler.applyDefaults(getPattern())
+		Expect(err).ToNot(HaveOccurred())
+		parent := applicationName(qualified)
+		child := &argoapi.Application{ObjectMeta: metav1.ObjectMeta{
+			Name:        "config-demo",
+			Namespace:   ApplicationNamespace,
+			Finalizers:  []string{argoapi.ResourcesFinalizerName},
+			Annotations: map[string]string{"argocd.argoproj.io/tracking-id": parent + ":argoproj.io/Application:" + parent + "/config-demo"},
+		}}
+		_, err = argoClient.ArgoprojV1alpha1().Applications(ApplicationNamespace).Create(context.Background(), child, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		phase, _ := finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		Expect(err).To(MatchError(ContainSubstring("vp-gitops/config-demo")))
+
+		forceDeletion("now")
+		phase, _ = finalize()
+		Expect(phase).To(Equal(api.DeleteHub))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning DeletionPhaseForced Forcing deletion phase DeleteHubChildApps")))
+		Expect(recorder.Events).To(Receive(Equal("Warning ApplicationFinalizersRemoved Removed finalizers [" + argoapi.ResourcesFinalizerName +
+			"] of application vp-gitops/config-demo")))
+		_, err = argoClient.ArgoprojV1alpha1().Applications(ApplicationNamespace).Get(context.Background(), "config-demo", metav1.GetOptions{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should keep the finalizers of the hub child applications when the phase times out", func() {
+		reconciler.dynamicClient = newDynamicClient()
+		qualified, err := reconciler.applyDefaults(getPattern())
+		Expect(err).ToNot(HaveOccurred())
+		parent := applicationName(qualified)
+		child := &argoapi.Application{ObjectMeta: metav1.ObjectMeta{
+			Name:        "config-demo",
+			Namespace:   ApplicationNamespace,
+			Finalizers:  []string{argoapi.ResourcesFinalizerName},
+			Annotations: map[string]string{"argocd.argoproj.io/tracking-id": parent + ":argoproj.io/Application:" + parent + "/config-demo"},
+		}}
+		_, err = argoClient.ArgoprojV1alpha1().Applications(ApplicationNamespace).Create(context.Background(), child, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		phase, _ := finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		phase, err = finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		Expect(err).To(MatchError(ContainSubstring("vp-gitops/config-demo")))
+
+		operatorConfig = PatternsOperatorConfig{"deletion.hubChildAppsTimeout": "1ns"}
+		phase, _ = finalize()
+		Expect(phase).To(Equal(api.DeleteHub))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning DeletionPhaseTimedOut Deletion phase DeleteHubChildApps timed out")))
+		Expect(recorder.Events).ToNot(Receive())
+		remaining, err := argoClient.ArgoprojV1alpha1().Applications(ApplicationNamespace).Get(context.Background(), "config-demo", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(remaining.Finalizers).To(Equal([]string{argoapi.ResourcesFinalizerName}))
+	})
+
+	It("should skip the spoke phases without an ACM hub", func() {
+		reconciler.dynamicClient = newDynamicClient()
+		phase, _ := finalize()
+		Expect(phase).To(Equal(api.DeleteHubChildApps))
+		phase, _ = finalize()
+		Expect(phase).To(Equal(api.DeleteHub))
+		_, err := finalize()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(search.Requests()).To(BeZero())
+	})
+})
+
 func newFakeReconciler(initObjects ...runtime.Object) *PatternReconciler {
 	mockctrl := gomock.NewController(GinkgoT())
 	defer mockctrl.Finish()
@@ -124,6 +379,7 @@ func newFakeReconciler(initObjects ...runtime.Object) *PatternReconciler {
 		operatorClient:  operatorclient.NewSimpleClientset(osControlManager).OperatorV1(),
 		AnalyticsClient: AnalyticsInit(true, logr.New(log.NullLogSink{})),
 		gitOperations:   mockGitOps,
+		recorder:        record.NewFakeRecorder(100),
 	}
 }
 
@@ -429,3 +685,28 @@ var _ = Describe("pattern controller - fetching pattern", func() {
 		Expect(err).To(HaveOccurred())
 	})
 })
+
+var _ = Describe("pattern controller - patchStatus", func() {
+	It("should leave the fields of the applications controller alone", func() {
+		stored := buildPatternManifest()
+		stored.Status.ApplicationsHealth = "Healthy"
+		stored.Status.Applications = []api.PatternApplicationInfo{{Name: "config-demo", Namespace: ApplicationNamespace}}
+		setPatternCondition(&stored.Status, api.SpokeDegraded, corev1.ConditionTrue, "region-one is degraded")
+		reconciler := newFakeReconciler()
+		reconciler.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(stored).WithStatusSubresource(&api.Pattern{}).Build()
+
+		// A copy read before the applications controller stored its fields
+		p := buildPatternManifest()
+		p.Status.LastStep = "reconcile complete"
+		setPatternCondition(&p.Status, api.Suspended, corev1.ConditionFalse, "")
+		Expect(reconciler.patchStatus(p)).To(Succeed())
+
+		result := &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), patternNamespaced, result)).To(Succeed())
+		Expect(result.Status.LastStep).To(Equal("reconcile complete"))
+		Expect(result.Status.ApplicationsHealth).To(Equal("Healthy"))
+		Expect(result.Status.Applications).To(HaveLen(1))
+		Expect(result.Status.Conditions).To(ConsistOf(HaveField("Type", api.SpokeDegraded), HaveField("Type", api.Suspended)))
+		Expect(p.Status.Applications).To(HaveLen(1))
+	})
+})
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -23,7 +25,16 @@ var DefaultPatternsOperatorConfig = PatternsOperatorConfig{
 	"gitea.chartName":                      GiteaChartName,
 	"gitea.helmRepoUrl":                    GiteaHelmRepoUrl,
 	"gitea.chartVersion":                   GiteaDefaultChartVersion,
+	"gitServer.backend":                    GiteaGitServerBackend,
 	"catalog.image":                        "",
+	"disconnected.enabled":                 "false",
+	"disconnected.mirrors":                 "",
+	"deletion.spokeChildAppsTimeout":       DefaultSpokeChildAppsDeletionTimeout,
+	"deletion.spokeTimeout":                DefaultSpokeDeletionTimeout,
+	"deletion.hubChildAppsTimeout":         DefaultHubChildAppsDeletionTimeout,
+	"deletion.operatorTeardown":            "false",
+	"deletion.removeGitOpsOperator":        "false",
+	"reconcile.paused":                     "false",
 }
 
 func (g PatternsOperatorConfig) getStringValue(k string) string {
@@ -42,6 +53,19 @@ func (g PatternsOperatorConfig) getBoolValue(k string) bool {
 	}
 }
 
+// getDurationValue parses k as a time.Duration, falling back to the default when it is invalid
+func (g PatternsOperatorConfig) getDurationValue(k string) time.Duration {
+	if v, present := g[k]; present {
+		d, err := time.ParseDuration(v)
+		if err == nil {
+			return d
+		}
+		log.Printf("Invalid duration %q for %s, using the default: %v", v, k, err)
+	}
+	d, _ := time.ParseDuration(DefaultPatternsOperatorConfig[k])
+	return d
+}
+
 // Creates the patterns operator configmap
 // This will include configuration parameters that
 // will allow operator configuration operatorConfigMap corev1.ConfigMap
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -92,6 +94,19 @@ var _ = Describe("PatternsOperatorConfig get values", func() {
 		})
 	})
 
+	Context("getDurationValue", func() {
+		It("should parse the configured duration", func() {
+			config := PatternsOperatorConfig{"deletion.spokeTimeout": "1h"}
+			Expect(config.getDurationValue("deletion.spokeTimeout")).To(Equal(time.Hour))
+		})
+
+		It("should fall back to the default when the duration is invalid", func() {
+			config := PatternsOperatorConfig{"deletion.spokeTimeout": "soon"}
+			Expect(config.getDurationValue("deletion.spokeTimeout")).To(BeZero())
+			Expect(PatternsOperatorConfig{}.getDurationValue("deletion.hubChildAppsTimeout")).To(BeZero())
+		})
+	})
+
 	Context("when config is nil", func() {
 		It("should return the default value", func() {
 			var config PatternsOperatorConfig
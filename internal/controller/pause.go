@@ -0,0 +1,76 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+// Last step reported while the reconciliation is paused
+const pausedStep = "reconcile paused"
+
+// pausedReason returns why the reconciliation of p is paused, or "" when it is not
+func pausedReason(p *api.Pattern, patternsOperatorConfig PatternsOperatorConfig) string {
+	if strings.EqualFold(p.Annotations[api.PausedAnnotation], "true") {
+		return fmt.Sprintf("Reconciliation paused by the %s annotation", api.PausedAnnotation)
+	}
+	if patternsOperatorConfig.getBoolValue("reconcile.paused") {
+		return fmt.Sprintf("Reconciliation of all patterns paused by reconcile.paused in the %s ConfigMap", OperatorConfigMap)
+	}
+	return ""
+}
+
+// reconcilePaused only reports that the reconciliation of p is paused. It keeps requeueing so that
+// lifting the pause is noticed even without an event
+func (r *PatternReconciler) reconcilePaused(p *api.Pattern, reason string) (ctrl.Result, error) {
+	_, condition := getPatternConditionByType(p.Status.Conditions, api.Suspended)
+	suspended := condition != nil && condition.Status == corev1.ConditionTrue
+	if !suspended || condition.Message != reason || p.Status.LastStep != pausedStep || p.Status.LastError != "" {
+		if !suspended {
+			log.Print(reason)
+			r.recorder.Event(p, corev1.EventTypeNormal, "ReconcilePaused", reason)
+		}
+		setPatternCondition(&p.Status, api.Suspended, corev1.ConditionTrue, reason)
+		p.Status.LastStep = pausedStep
+		p.Status.LastError = ""
+		if err := r.patchStatus(p); err != nil {
+			r.logger.Error(err, "Failed to update Pattern status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: ReconcileLoopRequeueTime}, nil
+}
+
+// resumeReconcile clears the Suspended condition of p once the pause is lifted. It returns true when
+// the status of p changed
+func (r *PatternReconciler) resumeReconcile(p *api.Pattern) bool {
+	_, condition := getPatternConditionByType(p.Status.Conditions, api.Suspended)
+	if condition == nil || condition.Status != corev1.ConditionTrue {
+		return false
+	}
+	setPatternCondition(&p.Status, api.Suspended, corev1.ConditionFalse, "")
+	log.Printf("Reconciliation of %s resumed", p.Name)
+	r.recorder.Event(p, corev1.EventTypeNormal, "ReconcileResumed", "Reconciliation resumed")
+	return true
+}
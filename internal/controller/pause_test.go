@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Paused reconciliation", func() {
+	var reconciler *PatternReconciler
+	var recorder *record.FakeRecorder
+	var pattern *api.Pattern
+	patternName := types.NamespacedName{Name: foo, Namespace: namespace}
+
+	reconcile := func() (ctrl.Result, *api.Pattern) {
+		result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: patternName})
+		Expect(err).ToNot(HaveOccurred())
+		p := &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), patternName, p)).To(Succeed())
+		return result, p
+	}
+
+	BeforeEach(func() {
+		pattern = buildPatternManifest()
+		pattern.Annotations = map[string]string{api.PausedAnnotation: "true"}
+		reconciler = newFakeReconciler()
+		reconciler.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pattern).WithStatusSubresource(&api.Pattern{}).Build()
+		recorder = reconciler.recorder.(*record.FakeRecorder)
+	})
+
+	It("should only report the pause in the status", func() {
+		result, p := reconcile()
+		Expect(result.RequeueAfter).To(Equal(ReconcileLoopRequeueTime))
+		Expect(p.Status.LastError).To(BeEmpty())
+		Expect(p.Status.LastStep).To(Equal(pausedStep))
+		_, condition := getPatternConditionByType(p.Status.Conditions, api.Suspended)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring(api.PausedAnnotation))
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal ReconcilePaused")))
+
+		// Nothing new to report on the next passes
+		_, p = reconcile()
+		Expect(p.Status.Conditions).To(HaveLen(1))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should still finalize a paused pattern that is deleted", func() {
+		_, p := reconcile()
+		p.Finalizers = []string{api.PatternFinalizer}
+		Expect(reconciler.Update(context.Background(), p)).To(Succeed())
+		Expect(reconciler.Delete(context.Background(), p)).To(Succeed())
+		<-recorder.Events
+
+		reconciler.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: patternName})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Get(context.Background(), patternName, &api.Pattern{})
+		Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		Expect(recorder.Events).ToNot(Receive(HavePrefix("Normal ReconcilePaused")))
+	})
+
+	It("should honor the global switch of the operator config", func() {
+		Expect(pausedReason(buildPatternManifest(), PatternsOperatorConfig{"reconcile.paused": "true"})).To(ContainSubstring("reconcile.paused"))
+		Expect(pausedReason(buildPatternManifest(), PatternsOperatorConfig{})).To(BeEmpty())
+		Expect(pausedReason(pattern, PatternsOperatorConfig{})).ToNot(BeEmpty())
+	})
+
+	It("should clear the condition when the pause is lifted", func() {
+		_, p := reconcile()
+		delete(p.Annotations, api.PausedAnnotation)
+		Expect(reconciler.Update(context.Background(), p)).To(Succeed())
+		<-recorder.Events
+
+		_, p = reconcile()
+		Expect(p.Status.LastStep).To(Equal("resumed reconciliation"))
+		_, condition := getPatternConditionByType(p.Status.Conditions, api.Suspended)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(recorder.Events).To(Receive(Equal("Normal ReconcileResumed Reconciliation resumed")))
+	})
+})
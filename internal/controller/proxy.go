@@ -0,0 +1,306 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	configclient "github.com/openshift/client-go/config/clientset/versioned"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterProxyName is the name of the cluster-wide config.openshift.io/v1 Proxy resource
+const ClusterProxyName = "cluster"
+
+// clusterProxy holds the effective cluster-wide proxy settings
+type clusterProxy struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// activeClusterProxy holds the cluster-wide proxy configuration. It is set by
+// detectClusterProxy() during reconciliation and is empty when no proxy is configured,
+// in which case we fall back to the proxy environment variables of the operator pod.
+// The transports, the gitea migrations and the status reconciler read it concurrently,
+// always go through getClusterProxy() and setClusterProxy()
+var (
+	activeClusterProxyMutex sync.RWMutex
+	activeClusterProxy      = clusterProxy{}
+)
+
+// getClusterProxy returns the cluster-wide proxy detected by the latest reconcile
+func getClusterProxy() clusterProxy {
+	activeClusterProxyMutex.RLock()
+	defer activeClusterProxyMutex.RUnlock()
+	return activeClusterProxy
+}
+
+// setClusterProxy replaces the cluster-wide proxy and returns the previous one
+func setClusterProxy(p clusterProxy) clusterProxy {
+	activeClusterProxyMutex.Lock()
+	defer activeClusterProxyMutex.Unlock()
+	previous := activeClusterProxy
+	activeClusterProxy = p
+	return previous
+}
+
+func init() {
+	// go-git only knows about socks5 proxies for ssh. Cluster-wide proxies are plain
+	// http(s) proxies, so teach golang.org/x/net/proxy how to tunnel via CONNECT
+	proxy.RegisterDialerType("http", newHTTPConnectDialer)
+	proxy.RegisterDialerType("https", newHTTPConnectDialer)
+}
+
+// detectClusterProxy reads the Proxy "cluster" resource and stores the effective settings
+// with setClusterProxy(). The status is preferred because the cluster network operator
+// adds the cluster internal networks and domains to noProxy there.
+func detectClusterProxy(configClient configclient.Interface) {
+	clusterProxyConfig, err := configClient.ConfigV1().Proxies().Get(context.Background(), ClusterProxyName, metav1.GetOptions{})
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			logOnce(fmt.Sprintf("Could not get cluster proxy configuration: %v", err))
+		}
+		setClusterProxy(clusterProxy{})
+		return
+	}
+
+	p := clusterProxy{
+		HTTPProxy:  clusterProxyConfig.Status.HTTPProxy,
+		HTTPSProxy: clusterProxyConfig.Status.HTTPSProxy,
+		NoProxy:    clusterProxyConfig.Status.NoProxy,
+	}
+	if !p.isSet() {
+		p = clusterProxy{
+			HTTPProxy:  clusterProxyConfig.Spec.HTTPProxy,
+			HTTPSProxy: clusterProxyConfig.Spec.HTTPSProxy,
+			NoProxy:    clusterProxyConfig.Spec.NoProxy,
+		}
+	}
+	if previous := setClusterProxy(p); p.isSet() && p != previous {
+		logOnce(fmt.Sprintf("Using cluster-wide proxy: httpProxy=%q httpsProxy=%q noProxy=%q", p.HTTPProxy, p.HTTPSProxy, p.NoProxy))
+	}
+}
+
+func (p clusterProxy) isSet() bool {
+	return p.HTTPProxy != "" || p.HTTPSProxy != ""
+}
+
+// proxyFunc returns the function to be used as http.Transport.Proxy. It honors the
+// noProxy rules of the cluster proxy.
+func (p clusterProxy) proxyFunc() func(*nethttp.Request) (*url.URL, error) {
+	if !p.isSet() {
+		return nethttp.ProxyFromEnvironment
+	}
+	cfg := &httpproxy.Config{
+		HTTPProxy:  p.HTTPProxy,
+		HTTPSProxy: p.HTTPSProxy,
+		NoProxy:    p.NoProxy,
+	}
+	f := cfg.ProxyFunc()
+	return func(req *nethttp.Request) (*url.URL, error) {
+		return f(req.URL)
+	}
+}
+
+// clusterProxyFunc is a http.Transport.Proxy for long lived clients: it follows the cluster
+// proxy detected by the latest reconcile rather than the one at the time the client was built
+func clusterProxyFunc(req *nethttp.Request) (*url.URL, error) {
+	return getClusterProxy().proxyFunc()(req)
+}
+
+// proxyForGitURL returns the proxy to use for a git url (including the scp-like
+// git@host:org/repo form) or nil when the connection should be direct
+func (p clusterProxy) proxyForGitURL(gitURL string) (*url.URL, error) {
+	endpoint, err := transport.NewEndpoint(gitURL)
+	if err != nil {
+		return nil, err
+	}
+	// ssh has no proxy of its own, it is tunneled through the https proxy like curl and git do
+	scheme := endpoint.Protocol
+	if scheme != "http" {
+		scheme = "https"
+	}
+	host := endpoint.Host
+	if endpoint.Port > 0 {
+		host = net.JoinHostPort(endpoint.Host, fmt.Sprintf("%d", endpoint.Port))
+	}
+	req := &nethttp.Request{URL: &url.URL{Scheme: scheme, Host: host}}
+	return p.proxyFunc()(req)
+}
+
+// giteaProxyHosts returns the comma separated hosts of gitURLs that gitea has to reach through the
+// proxy. Gitea proxies the hosts it is given and nothing else, so the ones the noProxy rules reach
+// directly are left out
+func (p clusterProxy) giteaProxyHosts(gitURLs ...string) string {
+	var hosts []string
+	for _, gitURL := range gitURLs {
+		if gitURL == "" {
+			continue
+		}
+		proxyURL, err := p.proxyForGitURL(gitURL)
+		if err != nil || proxyURL == nil {
+			continue
+		}
+		endpoint, _ := transport.NewEndpoint(gitURL)
+		if !slices.Contains(hosts, endpoint.Host) {
+			hosts = append(hosts, endpoint.Host)
+		}
+	}
+	return strings.Join(hosts, ",")
+}
+
+// gitProxyOptions returns the go-git proxy options for ssh urls. http(s) urls are
+// already handled by the transport returned by getHTTPSTransport()
+func (p clusterProxy) gitProxyOptions(gitURL string) transport.ProxyOptions {
+	endpoint, err := transport.NewEndpoint(gitURL)
+	if err != nil || endpoint.Protocol != "ssh" {
+		return transport.ProxyOptions{}
+	}
+	proxyURL, err := p.proxyForGitURL(gitURL)
+	if err != nil || proxyURL == nil {
+		return transport.ProxyOptions{}
+	}
+	return transport.ProxyOptions{
+		URL: proxyURL.String(),
+	}
+}
+
+// envVars returns the standard proxy environment variables to propagate to the
+// workloads we create
+func (p clusterProxy) envVars() []corev1.EnvVar {
+	var envVars []corev1.EnvVar
+	if p.HTTPProxy != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "HTTP_PROXY", Value: p.HTTPProxy})
+	}
+	if p.HTTPSProxy != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "HTTPS_PROXY", Value: p.HTTPSProxy})
+	}
+	if p.NoProxy != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "NO_PROXY", Value: p.NoProxy})
+	}
+	return envVars
+}
+
+// httpConnectDialer tunnels tcp connections through an http(s) proxy via CONNECT
+type httpConnectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.Dialer
+}
+
+func newHTTPConnectDialer(proxyURL *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &httpConnectDialer{proxyURL: proxyURL, forward: forward}, nil
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		port := "80"
+		if d.proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), port)
+	}
+
+	var conn net.Conn
+	var err error
+	if ctxDialer, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = ctxDialer.DialContext(ctx, network, proxyAddr)
+	} else {
+		conn, err = d.forward.Dial(network, proxyAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: d.proxyURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := &nethttp.Request{
+		Method: nethttp.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: nethttp.Header{},
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := nethttp.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused connection to %s: %s", d.proxyURL.Host, addr, resp.Status)
+	}
+
+	// The server might have already sent data (e.g. the ssh banner) that sits in the reader
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"bufio"
+	"net"
+	nethttp "net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	configclient "github.com/openshift/client-go/config/clientset/versioned/fake"
+	"golang.org/x/net/proxy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Cluster proxy", func() {
+	AfterEach(func() {
+		setClusterProxy(clusterProxy{})
+	})
+
+	Context("detectClusterProxy", func() {
+		It("should use the status of the cluster proxy", func() {
+			clusterProxyConfig := &configv1.Proxy{
+				ObjectMeta: metav1.ObjectMeta{Name: ClusterProxyName},
+				Spec: configv1.ProxySpec{
+					HTTPProxy: "http://spec-proxy:3128",
+				},
+				Status: configv1.ProxyStatus{
+					HTTPProxy:  "http://proxy:3128",
+					HTTPSProxy: "http://proxy:3128",
+					NoProxy:    ".cluster.local,.svc,10.0.0.0/16",
+				},
+			}
+			detectClusterProxy(configclient.NewSimpleClientset(clusterProxyConfig))
+			Expect(getClusterProxy()).To(Equal(clusterProxy{
+				HTTPProxy:  "http://proxy:3128",
+				HTTPSProxy: "http://proxy:3128",
+				NoProxy:    ".cluster.local,.svc,10.0.0.0/16",
+			}))
+		})
+
+		It("should fall back to the spec when the status is not populated", func() {
+			clusterProxyConfig := &configv1.Proxy{
+				ObjectMeta: metav1.ObjectMeta{Name: ClusterProxyName},
+				Spec: configv1.ProxySpec{
+					HTTPSProxy: "http://spec-proxy:3128",
+					NoProxy:    "example.com",
+				},
+			}
+			detectClusterProxy(configclient.NewSimpleClientset(clusterProxyConfig))
+			Expect(getClusterProxy().HTTPSProxy).To(Equal("http://spec-proxy:3128"))
+			Expect(getClusterProxy().NoProxy).To(Equal("example.com"))
+		})
+
+		It("should reset the proxy when the cluster has none", func() {
+			setClusterProxy(clusterProxy{HTTPProxy: "http://stale:3128"})
+			detectClusterProxy(configclient.NewSimpleClientset())
+			Expect(getClusterProxy().isSet()).To(BeFalse())
+		})
+	})
+
+	Context("proxyFunc", func() {
+		It("should honor noProxy", func() {
+			p := clusterProxy{
+				HTTPSProxy: "http://proxy:3128",
+				NoProxy:    ".svc,internal.example.com",
+			}
+			proxyURL, err := p.proxyFunc()(&nethttp.Request{URL: &url.URL{Scheme: "https", Host: "github.com"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL.String()).To(Equal("http://proxy:3128"))
+
+			proxyURL, err = p.proxyFunc()(&nethttp.Request{URL: &url.URL{Scheme: "https", Host: "search-api.ocm.svc"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL).To(BeNil())
+
+			proxyURL, err = p.proxyFunc()(&nethttp.Request{URL: &url.URL{Scheme: "https", Host: "internal.example.com"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proxyURL).To(BeNil())
+		})
+	})
+
+	Context("gitProxyOptions", func() {
+		p := clusterProxy{
+			HTTPSProxy: "http://proxy:3128",
+			NoProxy:    "gitlab.internal",
+		}
+
+		It("should tunnel ssh urls through the https proxy", func() {
+			Expect(p.gitProxyOptions("git@github.com:org/repo.git").URL).To(Equal("http://proxy:3128"))
+			Expect(p.gitProxyOptions("ssh://git@github.com:2222/org/repo.git").URL).To(Equal("http://proxy:3128"))
+		})
+
+		It("should not set proxy options for ssh hosts in noProxy", func() {
+			Expect(p.gitProxyOptions("git@gitlab.internal:org/repo.git").URL).To(BeEmpty())
+		})
+
+		It("should not set proxy options for https urls", func() {
+			Expect(p.gitProxyOptions("https://github.com/org/repo").URL).To(BeEmpty())
+		})
+	})
+
+	Context("envVars", func() {
+		It("should return no variables without a proxy", func() {
+			Expect(clusterProxy{}.envVars()).To(BeEmpty())
+		})
+
+		It("should be propagated to the gitops subscription", func() {
+			setClusterProxy(clusterProxy{
+				HTTPProxy: "http://proxy:3128",
+				NoProxy:   ".svc",
+			})
+			Expect(newSubscriptionEnvVars(false)).To(ContainElements(
+				corev1.EnvVar{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+				corev1.EnvVar{Name: "NO_PROXY", Value: ".svc"},
+			))
+			Expect(newSubscriptionEnvVars(false)).ToNot(ContainElement(HaveField("Name", "HTTPS_PROXY")))
+		})
+	})
+
+	Context("httpConnectDialer", func() {
+		var listener net.Listener
+		var requests chan *nethttp.Request
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			requests = make(chan *nethttp.Request, 1)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				req, err := nethttp.ReadRequest(bufio.NewReader(conn))
+				Expect(err).ToNot(HaveOccurred())
+				requests <- req
+				// Reply and immediately send a banner like an ssh server would
+				_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nSSH-2.0-fake\r\n"))
+			}()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("should tunnel the connection via CONNECT", func() {
+			proxyURL, err := url.Parse("http://user:secret@" + listener.Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+			Expect(err).ToNot(HaveOccurred())
+
+			conn, err := dialer.Dial("tcp", "github.com:22")
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			req := <-requests
+			Expect(req.Method).To(Equal(nethttp.MethodConnect))
+			Expect(req.Host).To(Equal("github.com:22"))
+			Expect(req.Header.Get("Proxy-Authorization")).To(Equal("Basic dXNlcjpzZWNyZXQ="))
+
+			banner, err := bufio.NewReader(conn).ReadString('\n')
+			Expect(err).ToNot(HaveOccurred())
+			Expect(banner).To(Equal("SSH-2.0-fake\r\n"))
+		})
+	})
+})
@@ -0,0 +1,185 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+// How long the applications stay degraded on a new revision before it is rolled back by default
+const defaultRollbackDegradedPeriod = 10 * time.Minute
+
+// getTargetRevision returns the git revision the pattern is deployed from: the revision the rollback
+// policy pinned, if any, TargetRevision otherwise
+func getTargetRevision(p *api.Pattern) string {
+	if p.Status.Rollback != nil && p.Status.Rollback.PinnedRevision != "" {
+		return p.Status.Rollback.PinnedRevision
+	}
+	return p.Spec.GitConfig.TargetRevision
+}
+
+// rollbackEnabled returns true when p opted into the rollback policy. Only git revisions are rolled back,
+// not the pattern OCI artifacts
+func rollbackEnabled(p *api.Pattern) bool {
+	return p.Spec.GitOpsConfig != nil && p.Spec.GitOpsConfig.Rollback != nil && p.Spec.GitOpsConfig.Rollback.Enabled &&
+		p.Spec.MultiSourceConfig.PatternOCIUrl == ""
+}
+
+func rollbackDegradedPeriod(p *api.Pattern) (time.Duration, error) {
+	if p.Spec.GitOpsConfig == nil || p.Spec.GitOpsConfig.Rollback == nil || p.Spec.GitOpsConfig.Rollback.DegradedPeriod == "" {
+		return defaultRollbackDegradedPeriod, nil
+	}
+	period, err := time.ParseDuration(p.Spec.GitOpsConfig.Rollback.DegradedPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rollback degradedPeriod %q: %w", p.Spec.GitOpsConfig.Rollback.DegradedPeriod, err)
+	}
+	return period, nil
+}
+
+// appOfAppsRevision returns the git revision argo compared the app of apps with, as found in
+// Status.Applications. The pattern git repository is the first source of the multi-source applications
+func appOfAppsRevision(p *api.Pattern) string {
+	for _, app := range p.Status.Applications {
+		if app.Name == applicationName(p) && app.Namespace == getClusterWideArgoNamespace() {
+			revision, _, _ := strings.Cut(app.Revision, ",")
+			return revision
+		}
+	}
+	return ""
+}
+
+// reconcileRollback follows the health of the applications of p at each revision of TargetRevision
+// and pins them to the last healthy revision when the current one keeps them degraded. It returns
+// true when the status of p changed
+func (r *PatternReconciler) reconcileRollback(p *api.Pattern) (bool, error) {
+	if !rollbackEnabled(p) {
+		if p.Status.Rollback == nil {
+			return false, nil
+		}
+		if p.Status.Rollback.PinnedRevision != "" {
+			r.releaseRollback(p, "Rollback policy disabled, following TargetRevision again")
+		}
+		p.Status.Rollback = nil
+		return true, nil
+	}
+	period, err := rollbackDegradedPeriod(p)
+	if err != nil {
+		return false, err
+	}
+	// The local checkout follows TargetRevision, regardless of the rollback
+	head, err := repoHash(p.Status.LocalCheckoutPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the revision of %s: %w", p.Spec.GitConfig.TargetRevision, err)
+	}
+	return r.updateRollback(p, head, period, time.Now()), nil
+}
+
+// updateRollback is reconcileRollback once head, the current revision of TargetRevision, is known
+func (r *PatternReconciler) updateRollback(p *api.Pattern, head string, period time.Duration, now time.Time) bool {
+	status := &api.PatternRollbackStatus{}
+	if p.Status.Rollback != nil {
+		status = p.Status.Rollback.DeepCopy()
+	}
+	conditionChanged := false
+
+	if status.PinnedRevision != "" {
+		if head == status.DegradedRevision {
+			return false
+		}
+		// TargetRevision moved on since the rollback, follow it again
+		pinned := status.PinnedRevision
+		status.PinnedRevision = ""
+		status.PinnedAt = nil
+		status.DegradedRevision = ""
+		status.DegradedSince = nil
+		p.Status.Rollback = status
+		r.releaseRollback(p, fmt.Sprintf("%s moved to %s, no longer pinned to %s", p.Spec.GitConfig.TargetRevision, head, pinned))
+		return true
+	}
+
+	switch {
+	case appOfAppsRevision(p) != head:
+		// Argo has yet to compare the applications with the new revision
+	case p.Status.ApplicationsHealth == string(health.HealthStatusHealthy) && p.Status.ApplicationsSync == string(argoapi.SyncStatusCodeSynced):
+		if status.LastHealthyRevision != head {
+			status.LastHealthyRevision = head
+			status.LastHealthyTime = &metav1.Time{Time: now}
+		}
+		status.DegradedRevision = ""
+		status.DegradedSince = nil
+	case p.Status.ApplicationsHealth == string(health.HealthStatusDegraded) && status.LastHealthyRevision != "" && status.LastHealthyRevision != head:
+		if status.DegradedRevision != head || status.DegradedSince == nil {
+			status.DegradedRevision = head
+			status.DegradedSince = &metav1.Time{Time: now}
+		}
+		if now.Sub(status.DegradedSince.Time) < period {
+			break
+		}
+		status.PinnedRevision = status.LastHealthyRevision
+		status.PinnedAt = &metav1.Time{Time: now}
+		message := fmt.Sprintf("Rolled back to %s, %s was degraded for %s. Pinned until %s moves again",
+			status.PinnedRevision, head, period, p.Spec.GitConfig.TargetRevision)
+		log.Print(message)
+		setPatternCondition(&p.Status, api.RolledBack, corev1.ConditionTrue, message)
+		r.recorder.Event(p, corev1.EventTypeWarning, "RolledBack", message)
+		conditionChanged = true
+	default:
+		// Not degraded, or degraded for reasons other than the revision
+		status.DegradedRevision = ""
+		status.DegradedSince = nil
+	}
+
+	if !conditionChanged && equality.Semantic.DeepEqual(p.Status.Rollback, status) {
+		return false
+	}
+	p.Status.Rollback = status
+	return true
+}
+
+// releaseRollback reports that the applications of p follow TargetRevision again
+func (r *PatternReconciler) releaseRollback(p *api.Pattern, message string) {
+	log.Print(message)
+	setPatternCondition(&p.Status, api.RolledBack, corev1.ConditionFalse, message)
+	r.recorder.Event(p, corev1.EventTypeNormal, "RollbackReleased", message)
+}
+
+// rollbackRequeue shortens requeue so that a rollback happens as soon as the degraded period is over
+func rollbackRequeue(p *api.Pattern, requeue time.Duration) time.Duration {
+	status := p.Status.Rollback
+	if status == nil || status.DegradedSince == nil || status.PinnedRevision != "" {
+		return requeue
+	}
+	period, err := rollbackDegradedPeriod(p)
+	if err != nil {
+		return requeue
+	}
+	if until := time.Until(status.DegradedSince.Add(period)); until > 0 && until < requeue {
+		return until + time.Second
+	}
+	return requeue
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"time"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Rollback policy", func() {
+	const good = "1111111111111111111111111111111111111111"
+	const bad = "2222222222222222222222222222222222222222"
+	const fixed = "3333333333333333333333333333333333333333"
+	var reconciler *PatternReconciler
+	var recorder *record.FakeRecorder
+	var pattern *api.Pattern
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// deployed makes argo report the app of apps at revision, with the given overall health
+	deployed := func(revision, healthStatus string) {
+		pattern.Status.Applications = []api.PatternApplicationInfo{{
+			Name:            applicationName(pattern),
+			Namespace:       getClusterWideArgoNamespace(),
+			AppSyncStatus:   "Synced",
+			AppHealthStatus: healthStatus,
+			Revision:        revision + ",0.9.30",
+		}}
+		pattern.Status.ApplicationsSync = "Synced"
+		pattern.Status.ApplicationsHealth = healthStatus
+	}
+
+	BeforeEach(func() {
+		pattern = buildPatternManifest()
+		pattern.Spec.GitConfig.TargetRevision = "main"
+		pattern.Spec.GitOpsConfig = &api.GitOpsConfig{Rollback: &api.RollbackPolicy{Enabled: true}}
+		reconciler = newFakeReconciler()
+		recorder = reconciler.recorder.(*record.FakeRecorder)
+	})
+
+	It("should remember the last healthy revision", func() {
+		deployed(good, "Progressing")
+		Expect(reconciler.updateRollback(pattern, good, time.Minute, now)).To(BeTrue())
+		Expect(pattern.Status.Rollback.LastHealthyRevision).To(BeEmpty())
+
+		deployed(good, "Healthy")
+		Expect(reconciler.updateRollback(pattern, good, time.Minute, now)).To(BeTrue())
+		Expect(pattern.Status.Rollback.LastHealthyRevision).To(Equal(good))
+		Expect(pattern.Status.Rollback.LastHealthyTime.Time).To(Equal(now))
+		Expect(reconciler.updateRollback(pattern, good, time.Minute, now.Add(time.Hour))).To(BeFalse())
+
+		// Argo has not caught up with the new revision yet
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now)).To(BeFalse())
+	})
+
+	It("should pin the last healthy revision after the degraded period", func() {
+		deployed(good, "Healthy")
+		reconciler.updateRollback(pattern, good, time.Minute, now)
+
+		deployed(bad, "Degraded")
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now)).To(BeTrue())
+		Expect(pattern.Status.Rollback.DegradedRevision).To(Equal(bad))
+		Expect(pattern.Status.Rollback.DegradedSince.Time).To(Equal(now))
+		Expect(getTargetRevision(pattern)).To(Equal("main"))
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now.Add(30*time.Second))).To(BeFalse())
+
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now.Add(time.Minute))).To(BeTrue())
+		Expect(pattern.Status.Rollback.PinnedRevision).To(Equal(good))
+		Expect(getTargetRevision(pattern)).To(Equal(good))
+		_, revision := getPatternRepo(pattern)
+		Expect(revision).To(Equal(good))
+		_, condition := getPatternConditionByType(pattern.Status.Conditions, api.RolledBack)
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning RolledBack Rolled back to " + good)))
+
+		// Stays pinned while the branch does not move
+		deployed(good, "Healthy")
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now.Add(time.Hour))).To(BeFalse())
+
+		Expect(reconciler.updateRollback(pattern, fixed, time.Minute, now.Add(time.Hour))).To(BeTrue())
+		Expect(pattern.Status.Rollback.PinnedRevision).To(BeEmpty())
+		Expect(pattern.Status.Rollback.LastHealthyRevision).To(Equal(good))
+		Expect(getTargetRevision(pattern)).To(Equal("main"))
+		_, condition = getPatternConditionByType(pattern.Status.Conditions, api.RolledBack)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal RollbackReleased main moved to " + fixed)))
+	})
+
+	It("should not roll back a revision that recovers or was healthy before", func() {
+		deployed(good, "Healthy")
+		reconciler.updateRollback(pattern, good, time.Minute, now)
+
+		deployed(bad, "Degraded")
+		reconciler.updateRollback(pattern, bad, time.Minute, now)
+		deployed(bad, "Progressing")
+		Expect(reconciler.updateRollback(pattern, bad, time.Minute, now.Add(time.Minute))).To(BeTrue())
+		Expect(pattern.Status.Rollback.DegradedSince).To(BeNil())
+
+		// The last healthy revision itself degrades, there is nothing to go back to
+		deployed(good, "Degraded")
+		reconciler.updateRollback(pattern, good, time.Minute, now)
+		Expect(reconciler.updateRollback(pattern, good, time.Minute, now.Add(time.Hour))).To(BeFalse())
+		Expect(pattern.Status.Rollback.PinnedRevision).To(BeEmpty())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should release the pin when the policy is disabled", func() {
+		pattern.Status.Rollback = &api.PatternRollbackStatus{LastHealthyRevision: good, DegradedRevision: bad, PinnedRevision: good}
+		pattern.Spec.GitOpsConfig.Rollback.Enabled = false
+		changed, err := reconciler.reconcileRollback(pattern)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(pattern.Status.Rollback).To(BeNil())
+		Expect(getTargetRevision(pattern)).To(Equal("main"))
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal RollbackReleased Rollback policy disabled")))
+	})
+
+	It("should validate the degraded period and requeue when it is over", func() {
+		pattern.Spec.GitOpsConfig.Rollback.DegradedPeriod = "soon"
+		_, err := reconciler.reconcileRollback(pattern)
+		Expect(err).To(MatchError(ContainSubstring(`invalid rollback degradedPeriod "soon"`)))
+
+		pattern.Spec.GitOpsConfig.Rollback.DegradedPeriod = "5m"
+		Expect(rollbackRequeue(pattern, ReconcileLoopRequeueTime)).To(Equal(ReconcileLoopRequeueTime))
+		pattern.Status.Rollback = &api.PatternRollbackStatus{DegradedRevision: bad, DegradedSince: &metav1.Time{Time: time.Now().Add(-4 * time.Minute)}}
+		Expect(rollbackRequeue(pattern, ReconcileLoopRequeueTime)).To(BeNumerically("~", time.Minute+time.Second, time.Second))
+	})
+})
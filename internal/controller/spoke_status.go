@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
+)
+
+// How often the applications of the managed clusters are collected through ACM search
+const SpokeStatusInterval = 3 * time.Minute
+
+// updateSpokeStatus refreshes status.clusters from ACM search at most every SpokeStatusInterval.
+// It returns how long to wait before the next refresh, or zero when there is no ACM hub
+func (r *ApplicationStatusReconciler) updateSpokeStatus(p *api.Pattern) time.Duration {
+	key := types.NamespacedName{Name: p.Name, Namespace: p.Namespace}
+	if last, ok := r.lastSpokeSearch[key]; ok && time.Since(last) < SpokeStatusInterval {
+		return SpokeStatusInterval - time.Since(last)
+	}
+
+	if !isManagedACMHub(r.dynamicClient) {
+		delete(r.lastSpokeSearch, key)
+		p.Status.Clusters = nil
+		p.Status.ClustersLastUpdated = nil
+		p.Status.ManagedClusters = nil
+		p.Status.ACMVersion = ""
+		removePatternCondition(&p.Status, api.SpokeDegraded)
+		return 0
+	}
+
+	// Throttle failed searches too, app events must not hammer the search service
+	r.lastSpokeSearch[key] = time.Now()
+	r.updateManagedClustersStatus(p)
+	apps, err := patternSpokeApplications(context.Background(), r.acmSearch, p)
+	if err != nil {
+		log.Printf("Could not collect the spoke cluster applications: %v", err)
+		return SpokeStatusInterval
+	}
+
+	setClustersStatus(&p.Status, apps, getClusterWideArgoNamespace())
+	now := metav1.Now()
+	p.Status.ClustersLastUpdated = &now
+	return SpokeStatusInterval
+}
+
+// updateManagedClustersStatus refreshes status.managedClusters with the clusters imported in the hub
+// and the managedClusterGroup of the pattern each of them is bound to
+func (r *ApplicationStatusReconciler) updateManagedClustersStatus(p *api.Pattern) {
+	var groups []managedClusterGroup
+	if _, err := os.Stat(p.Status.LocalCheckoutPath); p.Status.LocalCheckoutPath != "" && err == nil {
+		values, err := mergeHelmValues(newApplicationValueFiles(p, p.Status.LocalCheckoutPath)...)
+		if err == nil {
+			groups, err = getManagedClusterGroups(values)
+		}
+		if err != nil {
+			log.Printf("Could not read the managedClusterGroups of %s: %v", p.Name, err)
+		}
+	}
+
+	clusters, err := getManagedClusters(context.Background(), r.dynamicClient, p, groups)
+	if err != nil {
+		log.Printf("Could not list the managed clusters: %v", err)
+		return
+	}
+	p.Status.ManagedClusters = clusters
+	p.Status.ACMVersion = getACMVersion(r.dynamicClient)
+}
+
+// patternSpokeApplications returns the applications p deploys on the managed clusters: the clusterGroup
+// applications, named <pattern>-<group> in the argo namespace, and their children in the <pattern>-<group>
+// namespaces. The groups are the managedClusterGroups placing the clusters of status.managedClusters, the
+// applications of the other patterns and teams sharing the clusters are left out
+func patternSpokeApplications(ctx context.Context, search acmsearch.Client, p *api.Pattern) ([]acmsearch.Application, error) {
+	var groupNamespaces []string
+	for i := range p.Status.ManagedClusters {
+		group := p.Status.ManagedClusters[i].ClusterGroup
+		if ns := fmt.Sprintf("%s-%s", p.Name, group); group != "" && !slices.Contains(groupNamespaces, ns) {
+			groupNamespaces = append(groupNamespaces, ns)
+		}
+	}
+	if len(groupNamespaces) == 0 {
+		return nil, nil
+	}
+	sort.Strings(groupNamespaces)
+
+	argoNamespace := getClusterWideArgoNamespace()
+	apps, err := search.Applications(ctx, acmsearch.ApplicationQuery{Namespaces: append([]string{argoNamespace}, groupNamespaces...)})
+	if err != nil {
+		return nil, err
+	}
+	// The argo namespace holds the clusterGroup applications of every pattern
+	return slices.DeleteFunc(apps, func(app acmsearch.Application) bool {
+		return app.Namespace == argoNamespace && !slices.Contains(groupNamespaces, app.Name)
+	}), nil
+}
+
+// setClustersStatus summarizes the spoke applications per cluster and sets the SpokeDegraded
+// condition when the clusterGroup application, the one in argoNamespace, of a cluster is degraded
+func setClustersStatus(status *api.PatternStatus, apps []acmsearch.Application, argoNamespace string) {
+	clusters := map[string]*api.PatternClusterStatus{}
+	for i := range apps {
+		app := &apps[i]
+		cluster, ok := clusters[app.Cluster]
+		if !ok {
+			cluster = &api.PatternClusterStatus{
+				Name:         app.Cluster,
+				SyncStatus:   string(argoapi.SyncStatusCodeSynced),
+				HealthStatus: string(health.HealthStatusHealthy),
+			}
+			clusters[app.Cluster] = cluster
+		}
+		cluster.Applications++
+		if app.SyncStatus != string(argoapi.SyncStatusCodeSynced) {
+			cluster.OutOfSync++
+		}
+		if app.HealthStatus != string(health.HealthStatusHealthy) {
+			cluster.Unhealthy++
+		}
+		cluster.SyncStatus = combineSync(cluster.SyncStatus, app.SyncStatus)
+		cluster.HealthStatus = worseHealth(cluster.HealthStatus, app.HealthStatus)
+		if app.Namespace == argoNamespace {
+			cluster.ClusterGroupSyncStatus = app.SyncStatus
+			cluster.ClusterGroupHealthStatus = app.HealthStatus
+		}
+	}
+
+	status.Clusters = nil
+	var degraded []string
+	for _, cluster := range clusters {
+		status.Clusters = append(status.Clusters, *cluster)
+		if cluster.ClusterGroupHealthStatus == string(health.HealthStatusDegraded) {
+			degraded = append(degraded, cluster.Name)
+		}
+	}
+	sort.Slice(status.Clusters, func(i, j int) bool { return status.Clusters[i].Name < status.Clusters[j].Name })
+	sort.Strings(degraded)
+
+	if len(degraded) > 0 {
+		setPatternCondition(status, api.SpokeDegraded, corev1.ConditionTrue,
+			fmt.Sprintf("clusterGroup application degraded on: %s", strings.Join(degraded, ", ")))
+	} else {
+		setPatternCondition(status, api.SpokeDegraded, corev1.ConditionFalse, "")
+	}
+}
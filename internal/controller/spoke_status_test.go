@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/acmsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// fakeACMSearch returns a fixed set of applications, restricted to the namespaces of the query, or err
+type fakeACMSearch struct {
+	applications []acmsearch.Application
+	err          error
+	searches     int
+}
+
+func (f *fakeACMSearch) Search(context.Context, acmsearch.Query) ([]acmsearch.Item, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeACMSearch) Applications(_ context.Context, query acmsearch.ApplicationQuery) ([]acmsearch.Application, error) {
+	f.searches++
+	var apps []acmsearch.Application
+	for _, app := range f.applications {
+		if len(query.Namespaces) == 0 || slices.Contains(query.Namespaces, app.Namespace) {
+			apps = append(apps, app)
+		}
+	}
+	return apps, f.err
+}
+
+var _ = Describe("Spoke cluster status", func() {
+	spokeApps := []acmsearch.Application{
+		{Name: "region-one", Namespace: "openshift-gitops", Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Healthy"},
+		{Name: "config-demo", Namespace: "region-one", Cluster: "region-one", SyncStatus: "OutOfSync", HealthStatus: "Progressing"},
+		{Name: "region-two", Namespace: "openshift-gitops", Cluster: "region-two", SyncStatus: "Synced", HealthStatus: "Degraded"},
+		{Name: "config-demo", Namespace: "region-two", Cluster: "region-two", SyncStatus: "Synced", HealthStatus: "Healthy"},
+	}
+
+	Context("setClustersStatus", func() {
+		It("should summarize the applications of each cluster", func() {
+			status := &api.PatternStatus{}
+			setClustersStatus(status, spokeApps, "openshift-gitops")
+			Expect(status.Clusters).To(Equal([]api.PatternClusterStatus{
+				{
+					Name:                     "region-one",
+					ClusterGroupSyncStatus:   "Synced",
+					ClusterGroupHealthStatus: "Healthy",
+					SyncStatus:               "OutOfSync",
+					HealthStatus:             "Progressing",
+					Applications:             2,
+					OutOfSync:                1,
+					Unhealthy:                1,
+				},
+				{
+					Name:                     "region-two",
+					ClusterGroupSyncStatus:   "Synced",
+					ClusterGroupHealthStatus: "Degraded",
+					SyncStatus:               "Synced",
+					HealthStatus:             "Degraded",
+					Applications:             2,
+					Unhealthy:                1,
+				},
+			}))
+			Expect(status.Conditions).To(HaveLen(1))
+			Expect(status.Conditions[0].Type).To(Equal(api.SpokeDegraded))
+			Expect(status.Conditions[0].Status).To(Equal(corev1.ConditionTrue))
+			Expect(status.Conditions[0].Message).To(Equal("clusterGroup application degraded on: region-two"))
+		})
+
+		It("should clear the condition once the clusters recover", func() {
+			status := &api.PatternStatus{}
+			setClustersStatus(status, spokeApps, "openshift-gitops")
+			transition := status.Conditions[0].LastTransitionTime
+
+			setClustersStatus(status, spokeApps[:2], "openshift-gitops")
+			Expect(status.Clusters).To(HaveLen(1))
+			Expect(status.Conditions).To(HaveLen(1))
+			Expect(status.Conditions[0].Status).To(Equal(corev1.ConditionFalse))
+			Expect(status.Conditions[0].Message).To(BeEmpty())
+			Expect(status.Conditions[0].LastTransitionTime.Before(&transition)).To(BeFalse())
+		})
+	})
+
+	Context("updateSpokeStatus", func() {
+		var reconciler *ApplicationStatusReconciler
+		var pattern *api.Pattern
+		var search *fakeACMSearch
+
+		newReconciler := func(objects ...runtime.Object) *ApplicationStatusReconciler {
+			gvrMCH := schema.GroupVersionResource{Group: "operator.open-cluster-management.io", Version: "v1", Resource: "multiclusterhubs"}
+			gvrMC := schema.GroupVersionResource{Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters"}
+			return &ApplicationStatusReconciler{
+				dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+					gvrMCH: "MultiClusterHubList",
+					gvrMC:  "ManagedClusterList",
+				}, objects...),
+				lastSpokeSearch: map[types.NamespacedName]time.Time{},
+				acmSearch:       search,
+			}
+		}
+
+		var checkout string
+
+		BeforeEach(func() {
+			argoNamespace := getClusterWideArgoNamespace()
+			search = &fakeACMSearch{applications: []acmsearch.Application{
+				{Name: "test-region-one", Namespace: argoNamespace, Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Healthy"},
+				{Name: "config-demo", Namespace: "test-region-one", Cluster: "region-one", SyncStatus: "OutOfSync", HealthStatus: "Progressing"},
+				// Another pattern and another team deploying on the same cluster
+				{Name: "other-region-one", Namespace: argoNamespace, Cluster: "region-one", SyncStatus: "Synced", HealthStatus: "Degraded"},
+				{Name: "billing", Namespace: "billing", Cluster: "region-one", SyncStatus: "OutOfSync", HealthStatus: "Degraded"},
+			}}
+			checkout = createTempDir("vp-spoke-status")
+			Expect(os.WriteFile(filepath.Join(checkout, "values-hub.yaml"), []byte(`clusterGroup:
+  managedClusterGroups:
+  - name: region-one
+    clusterSelector:
+      matchLabels:
+        clusterGroup: region-one
+`), 0o600)).To(Succeed())
+			pattern = &api.Pattern{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "pattern-ns"},
+				Spec:       api.PatternSpec{ClusterGroupName: "hub"},
+				Status:     api.PatternStatus{LocalCheckoutPath: checkout},
+			}
+			hub := &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "operator.open-cluster-management.io/v1",
+				"kind":       "MultiClusterHub",
+				"metadata": map[string]any{
+					"name":        "multiclusterhub",
+					"namespace":   "open-cluster-management",
+					"annotations": map[string]any{"patterns.gitops.validatedpatterns.io/managed": "true"},
+				},
+				"status": map[string]any{"currentVersion": "2.13.2"},
+			}}
+			spoke := &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "cluster.open-cluster-management.io/v1",
+				"kind":       "ManagedCluster",
+				"metadata":   map[string]any{"name": "region-one", "labels": map[string]any{"clusterGroup": "region-one"}},
+			}}
+			reconciler = newReconciler(hub, spoke)
+		})
+
+		AfterEach(func() {
+			cleanupTempDir(checkout)
+		})
+
+		It("should collect the spoke applications at most once per interval", func() {
+			Expect(reconciler.updateSpokeStatus(pattern)).To(Equal(SpokeStatusInterval))
+			Expect(pattern.Status.Clusters).To(HaveLen(1))
+			Expect(pattern.Status.ClustersLastUpdated).ToNot(BeNil())
+			Expect(pattern.Status.ACMVersion).To(Equal("2.13.2"))
+			Expect(pattern.Status.ManagedClusters).To(Equal([]api.PatternManagedCluster{
+				{Name: "region-one", ClusterGroup: "region-one", Labels: map[string]string{"clusterGroup": "region-one"}, Available: "Unknown"},
+			}))
+
+			requeue := reconciler.updateSpokeStatus(pattern)
+			Expect(requeue).To(BeNumerically(">", 0))
+			Expect(requeue).To(BeNumerically("<=", SpokeStatusInterval))
+			Expect(search.searches).To(Equal(1))
+		})
+
+		It("should leave out the applications of the other patterns and teams", func() {
+			reconciler.updateSpokeStatus(pattern)
+			Expect(pattern.Status.Clusters).To(Equal([]api.PatternClusterStatus{{
+				Name:                     "region-one",
+				ClusterGroupSyncStatus:   "Synced",
+				ClusterGroupHealthStatus: "Healthy",
+				SyncStatus:               "OutOfSync",
+				HealthStatus:             "Progressing",
+				Applications:             2,
+				OutOfSync:                1,
+				Unhealthy:                1,
+			}}))
+			_, condition := getPatternConditionByType(pattern.Status.Conditions, api.SpokeDegraded)
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		})
+
+		It("should not search without clusters placed by the pattern", func() {
+			pattern.Status.LocalCheckoutPath = ""
+			reconciler.updateSpokeStatus(pattern)
+			Expect(search.searches).To(BeZero())
+			Expect(pattern.Status.Clusters).To(BeEmpty())
+		})
+
+		It("should keep the previous status when the search fails", func() {
+			Expect(reconciler.updateSpokeStatus(pattern)).To(Equal(SpokeStatusInterval))
+			reconciler.lastSpokeSearch = map[types.NamespacedName]time.Time{}
+			search.err = fmt.Errorf("search service returned status 503")
+
+			Expect(reconciler.updateSpokeStatus(pattern)).To(Equal(SpokeStatusInterval))
+			Expect(search.searches).To(Equal(2))
+			Expect(pattern.Status.Clusters).To(HaveLen(1))
+		})
+
+		It("should clear the cluster status without an ACM hub", func() {
+			pattern.Status.Clusters = []api.PatternClusterStatus{{Name: "region-one"}}
+			pattern.Status.ManagedClusters = []api.PatternManagedCluster{{Name: "region-one"}}
+			pattern.Status.Conditions = []api.PatternCondition{{Type: api.SpokeDegraded, Status: corev1.ConditionTrue}}
+			reconciler = newReconciler()
+
+			Expect(reconciler.updateSpokeStatus(pattern)).To(BeZero())
+			Expect(search.searches).To(BeZero())
+			Expect(pattern.Status.Clusters).To(BeNil())
+			Expect(pattern.Status.ManagedClusters).To(BeNil())
+			Expect(pattern.Status.Conditions).To(BeEmpty())
+		})
+	})
+})
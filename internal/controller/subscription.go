@@ -82,6 +82,8 @@ func newSubscriptionEnvVars(disableDefaultInstance bool) []corev1.EnvVar {
 			Value: "true",
 		})
 	}
+	// Propagate the cluster-wide proxy so the gitops operator and argo can reach external repos
+	envVars = append(envVars, getClusterProxy().envVars()...)
 	return envVars
 }
 
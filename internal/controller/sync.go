@@ -0,0 +1,281 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argoclient "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+const (
+	// Argo annotation requesting a refresh of an application
+	argoRefreshAnnotation = "argocd.argoproj.io/refresh"
+	// User the syncs requested through the pattern are initiated by
+	syncInitiator = "patterns-operator"
+	// Phase of a requested sync argo has not started yet
+	syncPending = "Pending"
+	// How often the pattern is reconciled while a requested sync runs
+	SyncStatusRequeueTime = 15 * time.Second
+)
+
+// syncOptions are the options of the sync-options annotation
+type syncOptions struct {
+	prune    bool
+	dryRun   bool
+	children bool
+}
+
+func parseSyncOptions(value string) (syncOptions, error) {
+	var options syncOptions
+	for _, option := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(option)) {
+		case "":
+		case "prune":
+			options.prune = true
+		case "dry-run":
+			options.dryRun = true
+		case "children":
+			options.children = true
+		default:
+			return options, fmt.Errorf("unknown option %q in %s, valid ones are prune, dry-run and children", option, api.SyncOptionsAnnotation)
+		}
+	}
+	return options, nil
+}
+
+// operationInProgress returns true when argo is running, or about to run, an operation on app
+func operationInProgress(app *argoapi.Application) bool {
+	return app.Operation != nil || (app.Status.OperationState != nil && !app.Status.OperationState.Phase.Completed())
+}
+
+// requestSync starts a sync of app
+func requestSync(client argoclient.Interface, app *argoapi.Application, options syncOptions) error {
+	app.Operation = &argoapi.Operation{
+		Sync: &argoapi.SyncOperation{
+			Prune:  options.prune,
+			DryRun: options.dryRun,
+		},
+		InitiatedBy: argoapi.OperationInitiator{Username: syncInitiator},
+	}
+	if _, err := client.ArgoprojV1alpha1().Applications(app.Namespace).Update(context.Background(), app, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to request the sync of application %q: %w", app.Name, err)
+	}
+	return nil
+}
+
+// requestRefresh asks argo for a refresh of app, of type normal or hard
+func requestRefresh(client argoclient.Interface, app *argoapi.Application, refresh string) error {
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": map[string]string{argoRefreshAnnotation: refresh}}})
+	if err != nil {
+		return err
+	}
+	_, err = client.ArgoprojV1alpha1().Applications(app.Namespace).Patch(context.Background(), app.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to request the refresh of application %q: %w", app.Name, err)
+	}
+	return nil
+}
+
+// syncApplications returns app, and its child applications when options asks for them
+func (r *PatternReconciler) syncApplications(app *argoapi.Application, options syncOptions) ([]*argoapi.Application, error) {
+	apps := []*argoapi.Application{app}
+	if options.children {
+		childApps, err := getChildApplications(r.argoClient, app)
+		if err != nil {
+			return nil, err
+		}
+		for i := range childApps {
+			apps = append(apps, &childApps[i])
+		}
+	}
+	return apps, nil
+}
+
+// reconcileSyncRequest handles the refresh and sync-requested annotations of p, whose app of apps
+// is app, and follows the sync that was requested. It returns true when the status of p changed
+func (r *PatternReconciler) reconcileSyncRequest(p *api.Pattern, app *argoapi.Application) (bool, error) {
+	options, err := parseSyncOptions(p.Annotations[api.SyncOptionsAnnotation])
+	if err != nil {
+		return false, err
+	}
+
+	if refresh := p.Annotations[api.RefreshAnnotation]; refresh != "" {
+		if refresh != string(argoapi.RefreshTypeNormal) && refresh != string(argoapi.RefreshTypeHard) {
+			return false, fmt.Errorf("invalid %s annotation %q, valid values are normal and hard", api.RefreshAnnotation, refresh)
+		}
+		apps, err := r.syncApplications(app, options)
+		if err != nil {
+			return false, err
+		}
+		for _, a := range apps {
+			if err := requestRefresh(r.argoClient, a, refresh); err != nil {
+				return false, err
+			}
+		}
+		// Consume the annotation, a new refresh is requested by setting it again
+		patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, api.RefreshAnnotation))
+		key := &api.Pattern{ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace}}
+		if err := r.Patch(context.TODO(), key, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return false, fmt.Errorf("failed to remove the %s annotation: %w", api.RefreshAnnotation, err)
+		}
+		delete(p.Annotations, api.RefreshAnnotation)
+		log.Printf("Requested a %s refresh of %d applications", refresh, len(apps))
+	}
+
+	if request := p.Annotations[api.SyncRequestedAnnotation]; request != "" && (p.Status.Sync == nil || p.Status.Sync.Request != request) {
+		return true, r.startSync(p, app, request, options)
+	}
+	if p.Status.Sync != nil && p.Status.Sync.FinishedAt == nil {
+		return r.updateSyncStatus(p)
+	}
+	return false, nil
+}
+
+// startSync requests the sync of app with options and records it in the status of p
+func (r *PatternReconciler) startSync(p *api.Pattern, app *argoapi.Application, request string, options syncOptions) error {
+	apps, err := r.syncApplications(app, options)
+	if err != nil {
+		return err
+	}
+	// Check them all first, argo drops a sync requested while another operation runs
+	for _, a := range apps {
+		if operationInProgress(a) {
+			return fmt.Errorf("waiting for the operation in progress on application %q", a.Name)
+		}
+	}
+
+	now := metav1.Now()
+	sync := &api.PatternSyncStatus{
+		Request:   request,
+		Prune:     options.prune,
+		DryRun:    options.dryRun,
+		Children:  options.children,
+		Phase:     syncPending,
+		StartedAt: &now,
+	}
+	for _, a := range apps {
+		if err := requestSync(r.argoClient, a, options); err != nil {
+			return err
+		}
+		sync.Applications = append(sync.Applications, api.PatternApplicationSync{Name: fmt.Sprintf("%s/%s", a.Namespace, a.Name), Phase: syncPending})
+	}
+	sync.Message = fmt.Sprintf("Requested the sync of %d applications", len(apps))
+	p.Status.Sync = sync
+
+	log.Print(sync.Message)
+	r.recorder.Event(p, corev1.EventTypeNormal, "SyncRequested",
+		fmt.Sprintf("%s (prune: %t, dry-run: %t) for %s %s", sync.Message, options.prune, options.dryRun, api.SyncRequestedAnnotation, request))
+	return nil
+}
+
+// syncPhaseRank orders the phases of a sync from the best to the worst
+var syncPhaseRank = map[string]int{
+	string(synccommon.OperationSucceeded):   0,
+	syncPending:                             1,
+	string(synccommon.OperationRunning):     2,
+	string(synccommon.OperationTerminating): 3,
+	string(synccommon.OperationFailed):      4,
+	string(synccommon.OperationError):       5,
+}
+
+// updateSyncStatus refreshes the outcome of the sync recorded in the status of p from the operation
+// state of its applications. It returns true when the status of p changed
+func (r *PatternReconciler) updateSyncStatus(p *api.Pattern) (bool, error) {
+	sync := p.Status.Sync.DeepCopy()
+	// The operation state of argo has a one second resolution
+	startedAt := sync.StartedAt.Truncate(time.Second)
+
+	done := true
+	phase := string(synccommon.OperationSucceeded)
+	var failed []string
+	for i := range sync.Applications {
+		result := &sync.Applications[i]
+		namespace, name, _ := strings.Cut(result.Name, "/")
+		app, err := r.argoClient.ArgoprojV1alpha1().Applications(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			result.Phase = string(synccommon.OperationError)
+			result.Message = "application not found"
+		} else if err != nil {
+			return false, err
+		} else if state := app.Status.OperationState; state != nil && state.Operation.InitiatedBy.Username == syncInitiator &&
+			!state.StartedAt.Before(&metav1.Time{Time: startedAt}) {
+			result.Phase = string(state.Phase)
+			result.Message = state.Message
+			if i == 0 && state.SyncResult != nil {
+				sync.Revision = state.SyncResult.Revision
+			}
+		}
+
+		if !synccommon.OperationPhase(result.Phase).Completed() {
+			done = false
+		} else if result.Phase != string(synccommon.OperationSucceeded) {
+			failed = append(failed, result.Name)
+		}
+		if syncPhaseRank[result.Phase] > syncPhaseRank[phase] {
+			phase = result.Phase
+		}
+	}
+	// A finished application does not make the sync finished while others still run
+	if !done && synccommon.OperationPhase(phase).Completed() {
+		phase = string(synccommon.OperationRunning)
+	}
+	sync.Phase = phase
+
+	switch {
+	case len(sync.Applications) == 1:
+		if sync.Applications[0].Message != "" {
+			sync.Message = sync.Applications[0].Message
+		}
+	case len(failed) > 0:
+		sync.Message = fmt.Sprintf("Sync failed for %s", strings.Join(failed, ", "))
+	case done:
+		sync.Message = fmt.Sprintf("Synced %d applications", len(sync.Applications))
+	}
+	if done {
+		now := metav1.Now()
+		sync.FinishedAt = &now
+		message := fmt.Sprintf("Sync %s requested by %s %s finished: %s", phase, api.SyncRequestedAnnotation, sync.Request, sync.Message)
+		log.Print(message)
+		if phase == string(synccommon.OperationSucceeded) {
+			r.recorder.Event(p, corev1.EventTypeNormal, "SyncSucceeded", message)
+		} else {
+			r.recorder.Event(p, corev1.EventTypeWarning, "SyncFailed", message)
+		}
+	}
+
+	if equality.Semantic.DeepEqual(p.Status.Sync, sync) {
+		return false, nil
+	}
+	p.Status.Sync = sync
+	return true, nil
+}
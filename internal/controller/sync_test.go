@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Requested syncs", func() {
+	var reconciler *PatternReconciler
+	var recorder *record.FakeRecorder
+	var pattern *api.Pattern
+	var app, child *argoapi.Application
+
+	getApp := func(a *argoapi.Application) *argoapi.Application {
+		found, err := reconciler.argoClient.ArgoprojV1alpha1().Applications(a.Namespace).Get(context.Background(), a.Name, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		return found
+	}
+
+	// finishSync makes argo report the outcome of the sync of a
+	finishSync := func(a *argoapi.Application, phase synccommon.OperationPhase, message string) {
+		found := getApp(a)
+		found.Operation = nil
+		found.Status.OperationState = &argoapi.OperationState{
+			Operation:  argoapi.Operation{InitiatedBy: argoapi.OperationInitiator{Username: syncInitiator}},
+			Phase:      phase,
+			Message:    message,
+			StartedAt:  metav1.Now(),
+			SyncResult: &argoapi.SyncOperationResult{Revision: "abc123"},
+		}
+		_, err := reconciler.argoClient.ArgoprojV1alpha1().Applications(a.Namespace).Update(context.Background(), found, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		pattern = buildPatternManifest()
+		pattern.Annotations = map[string]string{api.SyncRequestedAnnotation: "2026-10-19T10:00:00Z"}
+		app = &argoapi.Application{ObjectMeta: metav1.ObjectMeta{Name: "multicloud-gitops-hub", Namespace: ApplicationNamespace}}
+		child = &argoapi.Application{ObjectMeta: metav1.ObjectMeta{
+			Name:        "config-demo",
+			Namespace:   ApplicationNamespace,
+			Annotations: map[string]string{"argocd.argoproj.io/tracking-id": "multicloud-gitops-hub:argoproj.io/Application:multicloud-gitops-hub/config-demo"},
+		}}
+		reconciler = newFakeReconciler()
+		reconciler.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pattern).Build()
+		reconciler.argoClient = argofake.NewSimpleClientset(app, child)
+		recorder = reconciler.recorder.(*record.FakeRecorder)
+	})
+
+	It("should parse the sync options", func() {
+		options, err := parseSyncOptions("Prune, dry-run,children")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal(syncOptions{prune: true, dryRun: true, children: true}))
+		options, err = parseSyncOptions("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal(syncOptions{}))
+		_, err = parseSyncOptions("prune,force")
+		Expect(err).To(MatchError(ContainSubstring(`unknown option "force"`)))
+	})
+
+	It("should sync the app of apps once per request and report the outcome", func() {
+		changed, err := reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(pattern.Status.Sync.Request).To(Equal("2026-10-19T10:00:00Z"))
+		Expect(pattern.Status.Sync.Phase).To(Equal(syncPending))
+		Expect(pattern.Status.Sync.Applications).To(Equal([]api.PatternApplicationSync{{Name: "vp-gitops/multicloud-gitops-hub", Phase: syncPending}}))
+		Expect(getApp(app).Operation.Sync).To(Equal(&argoapi.SyncOperation{}))
+		Expect(getApp(child).Operation).To(BeNil())
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal SyncRequested Requested the sync of 1 applications (prune: false, dry-run: false)")))
+
+		// Nothing changes until argo runs the sync
+		changed, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		finishSync(app, synccommon.OperationSucceeded, "successfully synced (all tasks run)")
+		changed, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(pattern.Status.Sync.Phase).To(Equal("Succeeded"))
+		Expect(pattern.Status.Sync.Revision).To(Equal("abc123"))
+		Expect(pattern.Status.Sync.Message).To(Equal("successfully synced (all tasks run)"))
+		Expect(pattern.Status.Sync.FinishedAt).ToNot(BeNil())
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal SyncSucceeded")))
+
+		// The same request is not synced again
+		changed, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(getApp(app).Operation).To(BeNil())
+	})
+
+	It("should sync the child applications with the requested options", func() {
+		pattern.Annotations[api.SyncOptionsAnnotation] = "prune,dry-run,children"
+		_, err := reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pattern.Status.Sync.Applications).To(HaveLen(2))
+		Expect(getApp(app).Operation.Sync).To(Equal(&argoapi.SyncOperation{Prune: true, DryRun: true}))
+		Expect(getApp(child).Operation.Sync).To(Equal(&argoapi.SyncOperation{Prune: true, DryRun: true}))
+
+		finishSync(app, synccommon.OperationSucceeded, "successfully synced")
+		_, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pattern.Status.Sync.Phase).To(Equal("Pending"))
+		Expect(pattern.Status.Sync.FinishedAt).To(BeNil())
+
+		finishSync(child, synccommon.OperationFailed, "one or more objects failed to apply")
+		_, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pattern.Status.Sync.Phase).To(Equal("Failed"))
+		Expect(pattern.Status.Sync.Message).To(Equal("Sync failed for vp-gitops/config-demo"))
+		Expect(pattern.Status.Sync.FinishedAt).ToNot(BeNil())
+		<-recorder.Events
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning SyncFailed")))
+	})
+
+	It("should wait for the operation in progress", func() {
+		running := getApp(app)
+		running.Status.OperationState = &argoapi.OperationState{Phase: synccommon.OperationRunning}
+		_, err := reconciler.argoClient.ArgoprojV1alpha1().Applications(app.Namespace).Update(context.Background(), running, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).To(MatchError(ContainSubstring("waiting for the operation in progress")))
+		Expect(pattern.Status.Sync).To(BeNil())
+	})
+
+	It("should request a refresh once", func() {
+		pattern.Annotations = map[string]string{api.RefreshAnnotation: "hard"}
+		Expect(reconciler.Update(context.Background(), pattern)).To(Succeed())
+
+		changed, err := reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(getApp(app).Annotations).To(HaveKeyWithValue(argoRefreshAnnotation, "hard"))
+		Expect(getApp(child).Annotations).ToNot(HaveKey(argoRefreshAnnotation))
+
+		stored := &api.Pattern{}
+		Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(pattern), stored)).To(Succeed())
+		Expect(stored.Annotations).ToNot(HaveKey(api.RefreshAnnotation))
+
+		pattern.Annotations = map[string]string{api.RefreshAnnotation: "soft"}
+		_, err = reconciler.reconcileSyncRequest(pattern, getApp(app))
+		Expect(err).To(MatchError(ContainSubstring("valid values are normal and hard")))
+	})
+})
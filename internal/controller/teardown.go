@@ -0,0 +1,232 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/console"
+)
+
+//+kubebuilder:rbac:groups=operators.coreos.com,resources=clusterserviceversions,verbs=get;delete
+
+// sharedResource is an operator-wide resource that outlives the patterns using it
+type sharedResource struct {
+	gvr       schema.GroupVersionResource
+	kind      string
+	namespace string
+	name      string
+	// Wait for the resource to be gone before removing the next ones
+	wait bool
+	// Set when the resource is already being deleted
+	deleting bool
+}
+
+func (s sharedResource) String() string {
+	if s.namespace == "" {
+		return fmt.Sprintf("%s %s", s.kind, s.name)
+	}
+	return fmt.Sprintf("%s %s/%s", s.kind, s.namespace, s.name)
+}
+
+var (
+	gvrApplication  = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+	gvrArgoCD       = schema.GroupVersionResource{Group: ArgoCDGroup, Version: ArgoCDVersion, Resource: ArgoCDResource}
+	gvrNamespace    = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	gvrConfigMap    = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	gvrSecret       = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	gvrService      = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	gvrDeployment   = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	gvrPlugin       = schema.GroupVersionResource{Group: "console.openshift.io", Version: "v1", Resource: "consoleplugins"}
+	gvrSubscription = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "subscriptions"}
+	gvrCSV          = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "clusterserviceversions"}
+)
+
+// sharedResources returns the operator-wide resources that exist, in the order they are removed:
+// gitea while argo can still prune it, the argo instance, the gitops subscription and finally what
+// the operator created for the console and its own configuration
+func (r *PatternReconciler) sharedResources(patternsOperatorConfig PatternsOperatorConfig) ([]sharedResource, error) {
+	argoNS := getClusterWideArgoNamespace()
+	operatorNS := DetectOperatorNamespace()
+	resources := []sharedResource{
+		{gvr: gvrApplication, kind: "Application", namespace: argoNS, name: GiteaApplicationName, wait: true},
+		{gvr: gvrNamespace, kind: "Namespace", name: GiteaNamespace},
+	}
+	// The legacy openshift-gitops instance belongs to the gitops operator and whoever else uses it
+	if !isLegacyArgoNamespace() {
+		resources = append(resources,
+			sharedResource{gvr: consoleLinkGVR(), kind: "ConsoleLink", name: getClusterWideArgoName() + "-gitops-link"},
+			sharedResource{gvr: gvrArgoCD, kind: "ArgoCD", namespace: argoNS, name: getClusterWideArgoName(), wait: true},
+			sharedResource{gvr: gvrNamespace, kind: "Namespace", name: argoNS})
+	}
+	// So does the gitops subscription in openshift-operators
+	if operatorNS != LegacyOperatorNamespace {
+		name, namespace := DetectGitOpsSubscription()
+		resources = append(resources, sharedResource{gvr: gvrSubscription, kind: "Subscription", namespace: namespace, name: name})
+		// Removing the subscription leaves the gitops operator installed. Deleting its CSV uninstalls
+		// it for every workload of the cluster, argo instances of other teams included, so it takes
+		// its own opt-in
+		if patternsOperatorConfig.getBoolValue("deletion.removeGitOpsOperator") {
+			sub, err := r.dynamicClient.Resource(gvrSubscription).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if err == nil {
+				if csv, _, _ := unstructured.NestedString(sub.Object, "status", "installedCSV"); csv != "" {
+					resources = append(resources, sharedResource{gvr: gvrCSV, kind: "ClusterServiceVersion", namespace: namespace, name: csv})
+				}
+			}
+		}
+	}
+	resources = append(resources,
+		sharedResource{gvr: gvrPlugin, kind: "ConsolePlugin", name: console.PluginName},
+		sharedResource{gvr: gvrDeployment, kind: "Deployment", namespace: operatorNS, name: console.CatalogDeploymentName},
+		sharedResource{gvr: gvrService, kind: "Service", namespace: operatorNS, name: console.CatalogServiceName},
+		sharedResource{gvr: gvrConfigMap, kind: "ConfigMap", namespace: operatorNS, name: console.CatalogConfigMapName},
+		sharedResource{gvr: gvrSecret, kind: "Secret", namespace: operatorNS, name: console.CatalogCertSecretName},
+		// Last, it holds the setting that asked for the teardown
+		sharedResource{gvr: gvrConfigMap, kind: "ConfigMap", namespace: operatorNS, name: OperatorConfigMap})
+
+	var present []sharedResource
+	for _, resource := range resources {
+		obj, err := r.dynamicClient.Resource(resource.gvr).Namespace(resource.namespace).Get(context.TODO(), resource.name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", resource, err)
+		}
+		resource.deleting = obj.GetDeletionTimestamp() != nil
+		present = append(present, resource)
+	}
+	return present, nil
+}
+
+// teardownOperator runs once p is finalized. When p is the last pattern it removes the operator-wide
+// resources if deletion.operatorTeardown is set in the operator config and p was pruned, and otherwise
+// records the ones it leaves in place in the status and events of p
+func (r *PatternReconciler) teardownOperator(p *api.Pattern, patternsOperatorConfig PatternsOperatorConfig) error {
+	var patterns api.PatternList
+	if err := r.List(context.TODO(), &patterns); err != nil {
+		return fmt.Errorf("failed to list the patterns: %w", err)
+	}
+	for i := range patterns.Items {
+		if patterns.Items[i].UID != p.UID {
+			// The shared resources are still in use
+			return nil
+		}
+	}
+
+	detectArgoNamespace(r.dynamicClient)
+	resources, err := r.sharedResources(patternsOperatorConfig)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		names = append(names, resource.String())
+	}
+
+	teardown := patternsOperatorConfig.getBoolValue("deletion.operatorTeardown")
+	prune := strings.EqualFold(p.Annotations[api.PruneAnnotation], "true")
+	if !teardown || !prune {
+		hint := "set deletion.operatorTeardown in the operator config to remove them"
+		if teardown {
+			hint = fmt.Sprintf("the pattern was not deleted with the %s annotation", api.PruneAnnotation)
+		}
+		message := fmt.Sprintf("Left %d shared resources in place, %s: %s", len(names), hint, strings.Join(names, ", "))
+		log.Print(message)
+		r.recorder.Event(p, corev1.EventTypeNormal, "SharedResourcesLeftBehind", message)
+		return r.updateSharedResourcesStatus(p, names, nil, message)
+	}
+
+	// Argo has to be done pruning the applications of the pattern before it goes away
+	apps, err := r.argoClient.ArgoprojV1alpha1().Applications(getClusterWideArgoNamespace()).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", PatternApplicationLabel, p.Name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the applications of %s: %w", p.Name, err)
+	}
+	var waitingOn []string
+	for i := range apps.Items {
+		if apps.Items[i].Name != GiteaApplicationName {
+			waitingOn = append(waitingOn, fmt.Sprintf("%s/%s", apps.Items[i].Namespace, apps.Items[i].Name))
+		}
+	}
+	if len(waitingOn) > 0 {
+		waitErr := &deletionWaitError{message: "waiting for the pattern applications to be removed before the operator teardown", waitingOn: waitingOn}
+		if err := r.updateSharedResourcesStatus(p, names, waitingOn, waitErr.message); err != nil {
+			return err
+		}
+		return waitErr
+	}
+
+	for i, resource := range resources {
+		if !resource.deleting {
+			if resource.gvr == gvrPlugin {
+				if err := console.DisablePlugin(context.TODO(), r.Client); err != nil {
+					return err
+				}
+			}
+			err := r.dynamicClient.Resource(resource.gvr).Namespace(resource.namespace).Delete(context.TODO(), resource.name, metav1.DeleteOptions{})
+			if err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s: %w", resource, err)
+			}
+			message := fmt.Sprintf("Removed %s", resource)
+			log.Print(message)
+			r.recorder.Event(p, corev1.EventTypeNormal, "SharedResourceRemoved", message)
+		}
+		if resource.wait {
+			waitErr := &deletionWaitError{message: "waiting for the operator teardown", waitingOn: []string{resource.String()}}
+			if err := r.updateSharedResourcesStatus(p, names[i:], waitErr.waitingOn, waitErr.message); err != nil {
+				return err
+			}
+			return waitErr
+		}
+	}
+	return nil
+}
+
+// updateSharedResourcesStatus records the shared resources that are left and what the teardown waits on
+func (r *PatternReconciler) updateSharedResourcesStatus(p *api.Pattern, names, waitingOn []string, message string) error {
+	deletion := &api.PatternDeletionStatus{}
+	if p.Status.Deletion != nil {
+		deletion = p.Status.Deletion.DeepCopy()
+	}
+	if slices.Equal(deletion.SharedResources, names) && slices.Equal(deletion.WaitingOn, waitingOn) && deletion.Message == message {
+		return nil
+	}
+	deletion.SharedResources = names
+	deletion.WaitingOn = waitingOn
+	deletion.Message = message
+	p.Status.Deletion = deletion
+	if err := r.patchStatus(p); err != nil {
+		return fmt.Errorf("failed to update the deletion status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	"github.com/hybrid-cloud-patterns/patterns-operator/internal/controller/console"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Operator teardown", func() {
+	var reconciler *PatternReconciler
+	var pattern *api.Pattern
+	var recorder *record.FakeRecorder
+	var config PatternsOperatorConfig
+
+	newObject := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+
+	BeforeEach(func() {
+		activeArgoNamespace = ApplicationNamespace
+		activeArgoName = ClusterWideArgoName
+		pattern = buildPatternManifest()
+		pattern.UID = "pattern-uid"
+		pattern.Annotations = map[string]string{api.PruneAnnotation: "true"}
+		config = PatternsOperatorConfig{"deletion.operatorTeardown": "true"}
+
+		subscription := newObject("operators.coreos.com/v1alpha1", "Subscription", GitOpsDefaultSubscriptionNamespace, GitOpsDefaultPackageName)
+		Expect(unstructured.SetNestedField(subscription.Object, "openshift-gitops-operator.v1.20.0", "status", "installedCSV")).To(Succeed())
+		consoleConfig := &operatorv1.Console{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+		consoleConfig.Spec.Plugins = []string{"other-plugin", console.PluginName}
+
+		reconciler = newFakeReconciler()
+		reconciler.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pattern, consoleConfig).WithStatusSubresource(&api.Pattern{}).Build()
+		recorder = record.NewFakeRecorder(100)
+		reconciler.recorder = recorder
+		reconciler.argoClient = argofake.NewSimpleClientset()
+		reconciler.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			newObject("argoproj.io/v1alpha1", "Application", ApplicationNamespace, GiteaApplicationName),
+			newObject("v1", "Namespace", "", GiteaNamespace),
+			newObject("argoproj.io/v1beta1", "ArgoCD", ApplicationNamespace, ClusterWideArgoName),
+			newObject("v1", "Namespace", "", ApplicationNamespace),
+			subscription,
+			newObject("operators.coreos.com/v1alpha1", "ClusterServiceVersion", GitOpsDefaultSubscriptionNamespace, "openshift-gitops-operator.v1.20.0"),
+			newObject("console.openshift.io/v1", "ConsolePlugin", "", console.PluginName),
+			newObject("apps/v1", "Deployment", suggestedOperatorNamespace, console.CatalogDeploymentName),
+			newObject("v1", "Service", suggestedOperatorNamespace, console.CatalogServiceName),
+			newObject("v1", "ConfigMap", suggestedOperatorNamespace, OperatorConfigMap),
+		)
+	})
+
+	It("should leave the shared resources alone while other patterns exist", func() {
+		other := buildPatternManifest()
+		other.Name = "other"
+		other.UID = "other-uid"
+		Expect(reconciler.Create(context.Background(), other)).To(Succeed())
+
+		Expect(reconciler.teardownOperator(pattern, config)).To(Succeed())
+		Expect(recorder.Events).To(BeEmpty())
+		resources, err := reconciler.sharedResources(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resources).To(HaveLen(9))
+	})
+
+	It("should record what is left behind without the teardown setting", func() {
+		Expect(reconciler.teardownOperator(pattern, PatternsOperatorConfig{})).To(Succeed())
+
+		Expect(pattern.Status.Deletion.SharedResources).To(Equal([]string{
+			"Application vp-gitops/gitea-in-cluster",
+			"Namespace vp-gitea",
+			"ArgoCD vp-gitops/vp-gitops",
+			"Namespace vp-gitops",
+			"Subscription openshift-gitops-operator/openshift-gitops-operator",
+			"ConsolePlugin patterns-operator-console-plugin",
+			"Deployment patterns-operator/patterns-operator-pattern-ui-catalog",
+			"Service patterns-operator/patterns-operator-pattern-ui-catalog",
+			"ConfigMap patterns-operator/patterns-operator-config",
+		}))
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal SharedResourcesLeftBehind Left 9 shared resources in place, set deletion.operatorTeardown")))
+		resources, err := reconciler.sharedResources(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resources).To(HaveLen(9))
+	})
+
+	It("should only uninstall the gitops operator on its own opt-in", func() {
+		csv := "ClusterServiceVersion openshift-gitops-operator/openshift-gitops-operator.v1.20.0"
+		Expect(reconciler.teardownOperator(pattern, PatternsOperatorConfig{})).To(Succeed())
+		Expect(pattern.Status.Deletion.SharedResources).ToNot(ContainElement(csv))
+
+		config["deletion.removeGitOpsOperator"] = "true"
+		resources, err := reconciler.sharedResources(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resources[5].String()).To(Equal(csv))
+	})
+
+	It("should not tear down the operator when the pattern was not pruned", func() {
+		pattern.Annotations = nil
+		Expect(reconciler.teardownOperator(pattern, config)).To(Succeed())
+		Expect(recorder.Events).To(Receive(ContainSubstring("the pattern was not deleted with the " + api.PruneAnnotation + " annotation")))
+		Expect(pattern.Status.Deletion.SharedResources).To(HaveLen(9))
+	})
+
+	It("should wait for the pattern applications to be pruned", func() {
+		app := &argoapi.Application{ObjectMeta: metav1.ObjectMeta{
+			Name:      "multicloud-gitops-hub",
+			Namespace: ApplicationNamespace,
+			Labels:    map[string]string{PatternApplicationLabel: pattern.Name},
+		}}
+		reconciler.argoClient = argofake.NewSimpleClientset(app)
+
+		err := reconciler.teardownOperator(pattern, config)
+		var waitErr *deletionWaitError
+		Expect(errors.As(err, &waitErr)).To(BeTrue())
+		Expect(pattern.Status.Deletion.WaitingOn).To(Equal([]string{"vp-gitops/multicloud-gitops-hub"}))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should remove the shared resources in order", func() {
+		// gitea and then argo are waited on
+		err := reconciler.teardownOperator(pattern, config)
+		Expect(err).To(MatchError(ContainSubstring("Application vp-gitops/gitea-in-cluster")))
+		Expect(pattern.Status.Deletion.WaitingOn).To(Equal([]string{"Application vp-gitops/gitea-in-cluster"}))
+		Expect(recorder.Events).To(Receive(Equal("Normal SharedResourceRemoved Removed Application vp-gitops/gitea-in-cluster")))
+
+		err = reconciler.teardownOperator(pattern, config)
+		Expect(err).To(MatchError(ContainSubstring("ArgoCD vp-gitops/vp-gitops")))
+		Expect(pattern.Status.Deletion.SharedResources[0]).To(Equal("ArgoCD vp-gitops/vp-gitops"))
+
+		Expect(reconciler.teardownOperator(pattern, config)).To(Succeed())
+		resources, err := reconciler.sharedResources(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resources).To(BeEmpty())
+
+		// The gitops operator stays installed
+		_, err = reconciler.dynamicClient.Resource(gvrCSV).Namespace(GitOpsDefaultSubscriptionNamespace).
+			Get(context.Background(), "openshift-gitops-operator.v1.20.0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		consoleConfig := &operatorv1.Console{}
+		Expect(reconciler.Get(context.Background(), client.ObjectKey{Name: "cluster"}, consoleConfig)).To(Succeed())
+		Expect(consoleConfig.Spec.Plugins).To(Equal([]string{"other-plugin"}))
+	})
+})
@@ -85,6 +85,40 @@ func getPatternConditionByType(conditions []api.PatternCondition, conditionType
 	return -1, nil
 }
 
+// setPatternCondition adds or updates the condition of the given type. The update and
+// transition times only change when the condition does, so that setting the same condition
+// again leaves the status untouched
+func setPatternCondition(status *api.PatternStatus, conditionType api.PatternConditionType, conditionStatus corev1.ConditionStatus, message string) {
+	now := metav1.Now()
+	i, condition := getPatternConditionByType(status.Conditions, conditionType)
+	if condition == nil {
+		status.Conditions = append(status.Conditions, api.PatternCondition{
+			Type:               conditionType,
+			Status:             conditionStatus,
+			LastUpdateTime:     now,
+			LastTransitionTime: now,
+			Message:            message,
+		})
+		return
+	}
+	if condition.Status != conditionStatus {
+		status.Conditions[i].Status = conditionStatus
+		status.Conditions[i].LastTransitionTime = now
+		status.Conditions[i].LastUpdateTime = now
+	}
+	if condition.Message != message {
+		status.Conditions[i].Message = message
+		status.Conditions[i].LastUpdateTime = now
+	}
+}
+
+// removePatternCondition drops the condition of the given type if present
+func removePatternCondition(status *api.PatternStatus, conditionType api.PatternConditionType) {
+	if i, _ := getPatternConditionByType(status.Conditions, conditionType); i >= 0 {
+		status.Conditions = append(status.Conditions[:i], status.Conditions[i+1:]...)
+	}
+}
+
 // status:
 //  history:
 //   - completionTime: null
@@ -388,7 +422,7 @@ func getHTTPSTransport(fullClient kubernetes.Interface) *nethttp.Transport {
 		TLSClientConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		},
-		Proxy: nethttp.ProxyFromEnvironment,
+		Proxy: getClusterProxy().proxyFunc(),
 	}
 	var cacerts bytes.Buffer
 	if kuberoot != "" {
@@ -0,0 +1,342 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+)
+
+const (
+	// Argo project of the applications when the pattern has no maintenance windows
+	defaultProject = "default"
+	// Project a child application belonged to before it was moved to the project of the maintenance windows
+	originalProjectAnnotation = "validatedpatterns.io/original-project"
+	// How far ahead the next opening or closing of the maintenance windows is looked for
+	maintenanceWindowHorizon = 366 * 24 * time.Hour
+	// Bounds the search, windows starting every minute would otherwise take long to walk through
+	maintenanceWindowMaxSteps = 10000
+)
+
+// Same schedule format as the argo sync windows
+var maintenanceWindowParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// maintenanceWindow is a parsed api.MaintenanceWindow
+type maintenanceWindow struct {
+	deny     bool
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+func parseMaintenanceWindows(windows []api.MaintenanceWindow) ([]maintenanceWindow, error) {
+	var parsed []maintenanceWindow
+	for i, w := range windows {
+		if w.Kind != "" && w.Kind != "allow" && w.Kind != "deny" {
+			return nil, fmt.Errorf("maintenance window %d: kind %q is neither allow nor deny", i, w.Kind)
+		}
+		schedule, err := maintenanceWindowParser.Parse(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: cannot parse schedule %q: %w", i, w.Schedule, err)
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %d: invalid duration %q", i, w.Duration)
+		}
+		location, err := time.LoadLocation(maintenanceWindowTimeZone(w))
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: unknown time zone %q: %w", i, w.TimeZone, err)
+		}
+		parsed = append(parsed, maintenanceWindow{deny: w.Kind == "deny", schedule: schedule, duration: duration, location: location})
+	}
+	return parsed, nil
+}
+
+func maintenanceWindowTimeZone(w api.MaintenanceWindow) string {
+	if w.TimeZone == "" {
+		return "UTC"
+	}
+	return w.TimeZone
+}
+
+// active returns true when an occurrence of w, which lasts from its start to right before its end, covers t
+func (w maintenanceWindow) active(t time.Time) bool {
+	start := w.schedule.Next(t.In(w.location).Add(-w.duration))
+	return !start.IsZero() && !start.After(t)
+}
+
+// next returns the first time after t an occurrence of w starts or ends, or the zero time when none does
+func (w maintenanceWindow) next(t time.Time) time.Time {
+	local := t.In(w.location)
+	next := w.schedule.Next(local)
+	if start := w.schedule.Next(local.Add(-w.duration)); !start.IsZero() {
+		if end := start.Add(w.duration); next.IsZero() || end.Before(next) {
+			next = end
+		}
+	}
+	return next
+}
+
+// maintenanceWindowsOpen returns true when windows let argo sync automatically at t, with the same rules
+// as the argo sync windows: an active deny window blocks, an active allow window allows and otherwise the
+// syncs are blocked only when there are allow windows
+func maintenanceWindowsOpen(windows []maintenanceWindow, t time.Time) bool {
+	allowed, hasAllow := false, false
+	for _, w := range windows {
+		if !w.deny {
+			hasAllow = true
+		}
+		if w.active(t) {
+			if w.deny {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed || !hasAllow
+}
+
+// nextMaintenanceWindowChange returns the first time after from the windows open or close. It returns false
+// when that does not happen within maintenanceWindowHorizon
+func nextMaintenanceWindowChange(windows []maintenanceWindow, from time.Time) (time.Time, bool) {
+	open := maintenanceWindowsOpen(windows, from)
+	t := from
+	for i := 0; i < maintenanceWindowMaxSteps; i++ {
+		var next time.Time
+		for _, w := range windows {
+			if n := w.next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+		if next.IsZero() || next.Sub(from) > maintenanceWindowHorizon {
+			return time.Time{}, false
+		}
+		if maintenanceWindowsOpen(windows, next) != open {
+			return next, true
+		}
+		t = next
+	}
+	return time.Time{}, false
+}
+
+// maintenanceWindowStatus reports whether windows are open at now and when they next open and close
+func maintenanceWindowStatus(windows []maintenanceWindow, now time.Time) *api.PatternMaintenanceWindowStatus {
+	status := &api.PatternMaintenanceWindowStatus{Open: maintenanceWindowsOpen(windows, now)}
+	change, found := nextMaintenanceWindowChange(windows, now)
+	switch {
+	case !found && status.Open:
+		status.Message = "The windows do not close within a year"
+	case !found:
+		status.Message = "The windows do not open within a year"
+	case status.Open:
+		status.NextWindowEnd = &metav1.Time{Time: change.UTC()}
+	default:
+		status.NextWindowStart = &metav1.Time{Time: change.UTC()}
+		if end, found := nextMaintenanceWindowChange(windows, change); found {
+			status.NextWindowEnd = &metav1.Time{Time: end.UTC()}
+		}
+	}
+	return status
+}
+
+// maintenanceWindowRequeue shortens requeue so that the status is refreshed when the windows open or close
+func maintenanceWindowRequeue(status *api.PatternMaintenanceWindowStatus, requeue time.Duration) time.Duration {
+	if status == nil {
+		return requeue
+	}
+	for _, change := range []*metav1.Time{status.NextWindowStart, status.NextWindowEnd} {
+		if change == nil {
+			continue
+		}
+		if until := time.Until(change.Time); until > 0 && until < requeue {
+			// Land right after the change rather than right before it
+			return until + time.Second
+		}
+	}
+	return requeue
+}
+
+func hasMaintenanceWindows(p *api.Pattern) bool {
+	return p.Spec.GitOpsConfig != nil && len(p.Spec.GitOpsConfig.MaintenanceWindows) > 0
+}
+
+// applicationProject returns the argo project of the app of apps of p. The maintenance windows are dropped
+// while p is deleted, the deletion must not wait for them
+func applicationProject(p *api.Pattern) string {
+	if hasMaintenanceWindows(p) && p.DeletionTimestamp.IsZero() {
+		return applicationName(p)
+	}
+	return defaultProject
+}
+
+// newMaintenanceWindowProject returns the AppProject holding the maintenance windows of p as argo sync
+// windows. Other than that it permits everything, like the default project
+func newMaintenanceWindowProject(p *api.Pattern) *argoapi.AppProject {
+	var windows argoapi.SyncWindows
+	for _, w := range p.Spec.GitOpsConfig.MaintenanceWindows {
+		kind := w.Kind
+		if kind == "" {
+			kind = "allow"
+		}
+		windows = append(windows, &argoapi.SyncWindow{
+			Kind:         kind,
+			Schedule:     w.Schedule,
+			Duration:     w.Duration,
+			Applications: []string{"*"},
+			ManualSync:   w.ManualSync,
+			TimeZone:     maintenanceWindowTimeZone(w),
+			Description:  w.Description,
+		})
+	}
+	return &argoapi.AppProject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      applicationName(p),
+			Namespace: getClusterWideArgoNamespace(),
+			Labels:    map[string]string{PatternApplicationLabel: p.Name},
+		},
+		Spec: argoapi.AppProjectSpec{
+			Description:              fmt.Sprintf("Maintenance windows of pattern %s", p.Name),
+			SourceRepos:              []string{"*"},
+			Destinations:             []argoapi.ApplicationDestination{{Server: "*", Name: "*", Namespace: "*"}},
+			ClusterResourceWhitelist: []argoapi.ClusterResourceRestrictionItem{{Group: "*", Kind: "*"}},
+			SyncWindows:              windows,
+		},
+	}
+}
+
+// reconcileMaintenanceWindows keeps the AppProject holding the maintenance windows of p in line with them
+// and reports on the windows in the status of p. app is the app of apps, nil when it does not exist yet.
+// It returns true when the status of p changed
+func (r *PatternReconciler) reconcileMaintenanceWindows(p *api.Pattern, app *argoapi.Application) (bool, error) {
+	projects := r.argoClient.ArgoprojV1alpha1().AppProjects(getClusterWideArgoNamespace())
+	name := applicationName(p)
+
+	if applicationProject(p) == defaultProject {
+		if p.Status.MaintenanceWindow == nil {
+			return false, nil
+		}
+		// The project goes away once the app of apps left it
+		if app != nil && app.Spec.Project == name {
+			return false, nil
+		}
+		if err := r.moveChildApplications(app, name, false); err != nil {
+			return false, err
+		}
+		if err := projects.Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete AppProject %q: %w", name, err)
+		}
+		log.Printf("Removed the maintenance windows AppProject %s", name)
+		p.Status.MaintenanceWindow = nil
+		return true, nil
+	}
+
+	windows, err := parseMaintenanceWindows(p.Spec.GitOpsConfig.MaintenanceWindows)
+	if err != nil {
+		return false, err
+	}
+	target := newMaintenanceWindowProject(p)
+	_ = controllerutil.SetOwnerReference(p, target, r.Scheme)
+	current, err := projects.Get(context.Background(), name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err = projects.Create(context.Background(), target, metav1.CreateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to create AppProject %q: %w", name, err)
+		}
+		log.Printf("Created the maintenance windows AppProject %s", name)
+	} else if err != nil {
+		return false, err
+	} else if !equality.Semantic.DeepEqual(current.Spec, target.Spec) {
+		current.Spec = target.Spec
+		if _, err = projects.Update(context.Background(), current, metav1.UpdateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to update AppProject %q: %w", name, err)
+		}
+		log.Printf("Updated the maintenance windows AppProject %s", name)
+	}
+	// Once the app of apps is in the project its syncs leave the project of the children alone
+	if app != nil && app.Spec.Project == name {
+		if err = r.moveChildApplications(app, name, true); err != nil {
+			return false, err
+		}
+	}
+
+	status := maintenanceWindowStatus(windows, time.Now())
+	status.Project = fmt.Sprintf("%s/%s", target.Namespace, name)
+	if previous := p.Status.MaintenanceWindow; previous != nil && previous.Open != status.Open {
+		if status.Open {
+			r.recorder.Event(p, corev1.EventTypeNormal, "MaintenanceWindowOpened", "Maintenance window opened, argo syncs the applications")
+		} else {
+			r.recorder.Event(p, corev1.EventTypeNormal, "MaintenanceWindowClosed", "Maintenance window closed, argo holds the syncs back")
+		}
+	}
+	if equality.Semantic.DeepEqual(p.Status.MaintenanceWindow, status) {
+		return false, nil
+	}
+	p.Status.MaintenanceWindow = status
+	return true, nil
+}
+
+// moveChildApplications moves the child applications of the app of apps app to project, the project of the
+// maintenance windows, so that the windows hold their syncs back too. With into false it moves them back to
+// the projects of the clustergroup chart. Only the children in the argo namespace of the project can use it
+func (r *PatternReconciler) moveChildApplications(app *argoapi.Application, project string, into bool) error {
+	if app == nil {
+		return nil
+	}
+	children, err := getChildApplications(r.argoClient, app)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		child := &children[i]
+		original, moved := child.Annotations[originalProjectAnnotation]
+		if child.Namespace != getClusterWideArgoNamespace() || (into && child.Spec.Project == project) || (!into && !moved) {
+			continue
+		}
+		if into {
+			if child.Annotations == nil {
+				child.Annotations = map[string]string{}
+			}
+			child.Annotations[originalProjectAnnotation] = child.Spec.Project
+			child.Spec.Project = project
+		} else {
+			// A sync of the app of apps may have moved it back already
+			if child.Spec.Project == project {
+				child.Spec.Project = original
+			}
+			if child.Spec.Project == "" {
+				child.Spec.Project = defaultProject
+			}
+			delete(child.Annotations, originalProjectAnnotation)
+		}
+		if _, err = r.argoClient.ArgoprojV1alpha1().Applications(child.Namespace).Update(context.Background(), child, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to move application %q to project %q: %w", child.Name, child.Spec.Project, err)
+		}
+		log.Printf("Moved application %s to project %s", child.Name, child.Spec.Project)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	argoapi "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argofake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	api "github.com/hybrid-cloud-patterns/patterns-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Maintenance windows", func() {
+	// Monday 19 October 2026, Europe/Rome is still on summer time (UTC+2)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	parse := func(windows ...api.MaintenanceWindow) []maintenanceWindow {
+		parsed, err := parseMaintenanceWindows(windows)
+		Expect(err).ToNot(HaveOccurred())
+		return parsed
+	}
+	nightly := api.MaintenanceWindow{Schedule: "0 22 * * *", Duration: "2h"}
+
+	It("should reject invalid windows", func() {
+		_, err := parseMaintenanceWindows([]api.MaintenanceWindow{{Kind: "maybe", Schedule: "0 22 * * *", Duration: "2h"}})
+		Expect(err).To(MatchError(ContainSubstring(`kind "maybe"`)))
+		_, err = parseMaintenanceWindows([]api.MaintenanceWindow{{Schedule: "0 22 * *", Duration: "2h"}})
+		Expect(err).To(MatchError(ContainSubstring("cannot parse schedule")))
+		_, err = parseMaintenanceWindows([]api.MaintenanceWindow{{Schedule: "0 22 * * *", Duration: "2 hours"}})
+		Expect(err).To(MatchError(ContainSubstring("invalid duration")))
+		_, err = parseMaintenanceWindows([]api.MaintenanceWindow{{Schedule: "0 22 * * *", Duration: "2h", TimeZone: "Mars/Olympus"}})
+		Expect(err).To(MatchError(ContainSubstring("unknown time zone")))
+	})
+
+	It("should report the next allow window", func() {
+		windows := parse(nightly)
+		Expect(maintenanceWindowsOpen(windows, now)).To(BeFalse())
+		Expect(maintenanceWindowsOpen(windows, at(19, 22, 0))).To(BeTrue())
+		Expect(maintenanceWindowsOpen(windows, at(20, 0, 0))).To(BeFalse())
+
+		status := maintenanceWindowStatus(windows, now)
+		Expect(status.Open).To(BeFalse())
+		Expect(status.NextWindowStart.Time).To(Equal(at(19, 22, 0)))
+		Expect(status.NextWindowEnd.Time).To(Equal(at(20, 0, 0)))
+
+		status = maintenanceWindowStatus(windows, at(19, 23, 0))
+		Expect(status.Open).To(BeTrue())
+		Expect(status.NextWindowStart).To(BeNil())
+		Expect(status.NextWindowEnd.Time).To(Equal(at(20, 0, 0)))
+	})
+
+	It("should honor the time zone of the windows", func() {
+		rome := nightly
+		rome.TimeZone = "Europe/Rome"
+		status := maintenanceWindowStatus(parse(rome), now)
+		Expect(status.NextWindowStart.Time).To(Equal(at(19, 20, 0)))
+	})
+
+	It("should let deny windows override the allow windows", func() {
+		// Saturday 24 October from 23:00 to 01:00 is denied
+		windows := parse(nightly, api.MaintenanceWindow{Kind: "deny", Schedule: "0 23 * * 6", Duration: "2h"})
+		status := maintenanceWindowStatus(windows, at(24, 22, 30))
+		Expect(status.Open).To(BeTrue())
+		Expect(status.NextWindowEnd.Time).To(Equal(at(24, 23, 0)))
+
+		// Only deny windows, the syncs are allowed outside of them
+		status = maintenanceWindowStatus(parse(api.MaintenanceWindow{Kind: "deny", Schedule: "0 23 * * 6", Duration: "2h"}), now)
+		Expect(status.Open).To(BeTrue())
+		Expect(status.NextWindowEnd.Time).To(Equal(at(24, 23, 0)))
+		status = maintenanceWindowStatus(parse(api.MaintenanceWindow{Kind: "deny", Schedule: "0 23 * * 6", Duration: "2h"}), at(24, 23, 30))
+		Expect(status.Open).To(BeFalse())
+		Expect(status.NextWindowStart.Time).To(Equal(at(25, 1, 0)))
+		Expect(status.NextWindowEnd.Time).To(Equal(at(31, 23, 0)))
+	})
+
+	It("should give up on windows that never change", func() {
+		status := maintenanceWindowStatus(parse(api.MaintenanceWindow{Schedule: "* * * * *", Duration: "1h"}), now)
+		Expect(status.Open).To(BeTrue())
+		Expect(status.NextWindowEnd).To(BeNil())
+		Expect(status.Message).To(Equal("The windows do not close within a year"))
+	})
+
+	It("should requeue when the windows change", func() {
+		soon := &metav1.Time{Time: time.Now().Add(time.Minute)}
+		Expect(maintenanceWindowRequeue(nil, ReconcileLoopRequeueTime)).To(Equal(ReconcileLoopRequeueTime))
+		Expect(maintenanceWindowRequeue(&api.PatternMaintenanceWindowStatus{NextWindowStart: soon}, ReconcileLoopRequeueTime)).
+			To(BeNumerically("~", time.Minute+time.Second, time.Second))
+		Expect(maintenanceWindowRequeue(&api.PatternMaintenanceWindowStatus{Open: true}, ReconcileLoopRequeueTime)).To(Equal(ReconcileLoopRequeueTime))
+	})
+
+	Context("AppProject", func() {
+		var reconciler *PatternReconciler
+		var pattern *api.Pattern
+
+		getProject := func() (*argoapi.AppProject, error) {
+			return reconciler.argoClient.ArgoprojV1alpha1().AppProjects(getClusterWideArgoNamespace()).
+				Get(context.Background(), applicationName(pattern), metav1.GetOptions{})
+		}
+
+		BeforeEach(func() {
+			pattern = buildPatternManifest()
+			pattern.Spec.GitOpsConfig = &api.GitOpsConfig{MaintenanceWindows: []api.MaintenanceWindow{nightly}}
+			reconciler = newFakeReconciler()
+			reconciler.argoClient = argofake.NewSimpleClientset()
+		})
+
+		It("should hold the windows in the project of the app of apps", func() {
+			changed, err := reconciler.reconcileMaintenanceWindows(pattern, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(pattern.Status.MaintenanceWindow.Project).To(Equal(getClusterWideArgoNamespace() + "/" + applicationName(pattern)))
+
+			project, err := getProject()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(project.Spec.SyncWindows).To(Equal(argoapi.SyncWindows{{
+				Kind: "allow", Schedule: "0 22 * * *", Duration: "2h", Applications: []string{"*"}, TimeZone: "UTC",
+			}}))
+			Expect(commonApplicationSpec(pattern, nil).Project).To(Equal(applicationName(pattern)))
+
+			// Nothing changes on the next pass
+			changed, err = reconciler.reconcileMaintenanceWindows(pattern, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			pattern.Spec.GitOpsConfig.MaintenanceWindows[0].Duration = "3h"
+			_, err = reconciler.reconcileMaintenanceWindows(pattern, nil)
+			Expect(err).ToNot(HaveOccurred())
+			project, err = getProject()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(project.Spec.SyncWindows[0].Duration).To(Equal("3h"))
+		})
+
+		It("should report when the windows open and close", func() {
+			recorder := reconciler.recorder.(*record.FakeRecorder)
+			pattern.Status.MaintenanceWindow = &api.PatternMaintenanceWindowStatus{Open: true}
+			pattern.Spec.GitOpsConfig.MaintenanceWindows = []api.MaintenanceWindow{{Kind: "deny", Schedule: "* * * * *", Duration: "2m"}}
+			_, err := reconciler.reconcileMaintenanceWindows(pattern, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pattern.Status.MaintenanceWindow.Open).To(BeFalse())
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal MaintenanceWindowClosed")))
+		})
+
+		It("should remove the project once the app of apps left it", func() {
+			_, err := reconciler.reconcileMaintenanceWindows(pattern, nil)
+			Expect(err).ToNot(HaveOccurred())
+			app := &argoapi.Application{Spec: argoapi.ApplicationSpec{Project: applicationName(pattern)}}
+
+			pattern.Spec.GitOpsConfig.MaintenanceWindows = nil
+			Expect(commonApplicationSpec(pattern, nil).Project).To(Equal(defaultProject))
+			changed, err := reconciler.reconcileMaintenanceWindows(pattern, app)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+			_, err = getProject()
+			Expect(err).ToNot(HaveOccurred())
+
+			app.Spec.Project = defaultProject
+			changed, err = reconciler.reconcileMaintenanceWindows(pattern, app)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(pattern.Status.MaintenanceWindow).To(BeNil())
+			_, err = getProject()
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should gate the child applications through the project of the windows", func() {
+			name := applicationName(pattern)
+			app := &argoapi.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: getClusterWideArgoNamespace()},
+				Spec:       argoapi.ApplicationSpec{Project: name},
+			}
+			newChild := func(childName, namespace string) *argoapi.Application {
+				return &argoapi.Application{
+					ObjectMeta: metav1.ObjectMeta{Name: childName, Namespace: namespace, Annotations: map[string]string{
+						"argocd.argoproj.io/tracking-id": name + ":argoproj.io/Application:" + name + "/" + childName,
+					}},
+					Spec: argoapi.ApplicationSpec{Project: "hub"},
+				}
+			}
+			reconciler.argoClient = argofake.NewSimpleClientset(app, newChild("vault", getClusterWideArgoNamespace()),
+				newChild("elsewhere", "other-argo"))
+			getChild := func(childName, namespace string) *argoapi.Application {
+				child, err := reconciler.argoClient.ArgoprojV1alpha1().Applications(namespace).Get(context.Background(), childName, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				return child
+			}
+
+			// The syncs of the app of apps leave the project of the children alone
+			Expect(commonIgnoreDifferences(pattern)).To(ContainElement(argoapi.ResourceIgnoreDifferences{
+				Group: "argoproj.io", Kind: "Application", JSONPointers: []string{"/spec/project"},
+			}))
+			Expect(commonSyncPolicy(pattern).SyncOptions).To(ContainElement("RespectIgnoreDifferences=true"))
+
+			_, err := reconciler.reconcileMaintenanceWindows(pattern, app)
+			Expect(err).ToNot(HaveOccurred())
+			vault := getChild("vault", getClusterWideArgoNamespace())
+			Expect(vault.Spec.Project).To(Equal(name))
+			Expect(vault.Annotations).To(HaveKeyWithValue(originalProjectAnnotation, "hub"))
+			// Not in the namespace of the project, it cannot use it
+			Expect(getChild("elsewhere", "other-argo").Spec.Project).To(Equal("hub"))
+
+			pattern.Spec.GitOpsConfig.MaintenanceWindows = nil
+			Expect(commonIgnoreDifferences(pattern)).To(BeEmpty())
+			app.Spec.Project = defaultProject
+			_, err = reconciler.reconcileMaintenanceWindows(pattern, app)
+			Expect(err).ToNot(HaveOccurred())
+			vault = getChild("vault", getClusterWideArgoNamespace())
+			Expect(vault.Spec.Project).To(Equal("hub"))
+			Expect(vault.Annotations).ToNot(HaveKey(originalProjectAnnotation))
+			_, err = getProject()
+			Expect(kerrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should drop the windows while the pattern is deleted", func() {
+			deleted := metav1.Now()
+			pattern.DeletionTimestamp = &deleted
+			Expect(applicationProject(pattern)).To(Equal(defaultProject))
+		})
+	})
+})